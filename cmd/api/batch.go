@@ -0,0 +1,261 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+//maxBatchOperations is the most operations a single batch request can contain.
+const maxBatchOperations = 1000
+
+//The operations a batch can contain.
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+)
+
+//errBatchFailed is returned from inside a batch transaction when an operation fails, so the transaction is rolled back.
+var errBatchFailed = errors.New("batch operation failed")
+
+//batchOperation is one operation in a batch request. Creates need a movie, updates need an ID and the fields to change,
+//and deletes need an ID. Updates and deletes may also give the version they expect the movie to be at.
+type batchOperation struct {
+	Op      string           `json:"op"`
+	ID      int64            `json:"id"`
+	Version *int32           `json:"version"`
+	Movie   *batchMovieInput `json:"movie"`
+}
+
+//batchMovieInput holds the movie fields for a create or update. As with PATCH, fields which are left out aren't changed.
+type batchMovieInput struct {
+	Title   *string       `json:"title"`
+	Year    *int32        `json:"year"`
+	Runtime *data.Runtime `json:"runtime"`
+	Genres  []string      `json:"genres"`
+}
+
+//apply copies the fields which are present onto the movie.
+func (input *batchMovieInput) apply(movie *data.Movie) {
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+	if input.Genres != nil {
+		movie.Genres = input.Genres
+	}
+}
+
+//batchResult reports the outcome of one operation, in the same position as the operation in the request. Status is the
+//HTTP status the operation would have had as a single request.
+type batchResult struct {
+	Index   int               `json:"index"`
+	Op      string            `json:"op"`
+	Status  int               `json:"status"`
+	ID      int64             `json:"id,omitempty"`
+	Version int32             `json:"version,omitempty"`
+	Movie   *movieResponse    `json:"movie,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+//batchMoviesHandler applies a list of create, update and delete operations for the "POST /v1/movies:batch" endpoint.
+//By default each operation is applied on its own, so some can succeed while others fail, and the response is always
+//200 OK with a result for each one. With ?atomic=true they are applied in one transaction which is rolled back at the
+//first failure. In that case the response is 422 Unprocessable Entity, and the other operations are reported as
+//424 Failed Dependency, as none of them were saved.
+func (app *application) batchMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Operations []batchOperation `json:"operations"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	atomic := app.readBool(r.URL.Query(), "atomic", false, v)
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	v.Check(len(input.Operations) > 0, "operations", "validation.required")
+	v.Check(len(input.Operations) <= maxBatchOperations, "operations", i18n.Key("validation.max_items", strconv.Itoa(maxBatchOperations)))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	lang := app.language(r)
+	audit := app.audit(r)
+	results := make([]*batchResult, len(input.Operations))
+
+	//apply runs one operation in the transaction, returning errBatchFailed if it didn't succeed
+	apply := func(tx *sql.Tx, i int) error {
+		result, err := app.applyBatchOperation(tx, i, input.Operations[i], audit, lang, runtimeFormat)
+		if err != nil {
+			return err
+		}
+
+		results[i] = result
+		if result.Status >= http.StatusBadRequest {
+			return errBatchFailed
+		}
+
+		return nil
+	}
+
+	status := http.StatusOK
+
+	if atomic {
+		err = app.models.Movies.InTx(func(tx *sql.Tx) error {
+			for i := range input.Operations {
+				if err := apply(tx, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		switch {
+		case errors.Is(err, errBatchFailed):
+			//Keep the result of the operation which failed, and report every other one as not applied
+			for i, op := range input.Operations {
+				if results[i] == nil || results[i].Status < http.StatusBadRequest {
+					results[i] = &batchResult{
+						Index:  i,
+						Op:     op.Op,
+						Status: http.StatusFailedDependency,
+						Error:  i18n.T(lang, "error.batch_rolled_back"),
+					}
+				}
+			}
+			status = http.StatusUnprocessableEntity
+
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		//Each operation gets its own transaction, so a failure only affects that operation
+		for i, op := range input.Operations {
+			err = app.models.Movies.InTx(func(tx *sql.Tx) error {
+				return apply(tx, i)
+			})
+			if err != nil && !errors.Is(err, errBatchFailed) {
+				app.logError(r, err)
+				results[i] = &batchResult{Index: i, Op: op.Op, Status: http.StatusInternalServerError, Error: i18n.T(lang, "error.server")}
+			}
+		}
+	}
+
+	err = app.writeResponse(w, r, status, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//applyBatchOperation validates and applies one operation in the given transaction. Failures which are the client's
+//fault are reported in the result, while any other error is returned.
+func (app *application) applyBatchOperation(tx *sql.Tx, index int, op batchOperation, audit data.Audit, lang string, format data.RuntimeFormat) (*batchResult, error) {
+	result := &batchResult{Index: index, Op: op.Op, ID: op.ID}
+
+	v := validator.New()
+
+	//Which other fields are needed depends on the operation, so only check them once we know it's a valid one
+	if v.Check(validator.In(op.Op, batchCreate, batchUpdate, batchDelete), "op", i18n.Key("validation.oneof", "create, update, delete")); v.Valid() {
+		v.Check(op.Op == batchCreate || op.ID >= 1, "id", i18n.Key("validation.min", "1"))
+		v.Check(op.Op == batchDelete || op.Movie != nil, "movie", "validation.required")
+	}
+
+	if !v.Valid() {
+		return result.failedValidation(lang, v), nil
+	}
+
+	var movie *data.Movie
+	var err error
+
+	switch op.Op {
+	case batchCreate:
+		movie = &data.Movie{}
+		op.Movie.apply(movie)
+
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			return result.failedValidation(lang, v), nil
+		}
+
+		err = app.models.Movies.InsertTx(tx, movie, audit)
+		result.Status = http.StatusCreated
+
+	case batchUpdate:
+		//The movie is locked by GetTx(), so the version we check here can't change before the update
+		movie, err = app.models.Movies.GetTx(tx, op.ID)
+		if err == nil && op.Version != nil && *op.Version != movie.Version {
+			err = data.ErrEditConflict
+		}
+		if err != nil {
+			break
+		}
+
+		op.Movie.apply(movie)
+
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			return result.failedValidation(lang, v), nil
+		}
+
+		err = app.models.Movies.UpdateTx(tx, movie, audit)
+		result.Status = http.StatusOK
+
+	case batchDelete:
+		err = app.models.Movies.DeleteTx(tx, op.ID, op.Version, audit)
+		result.Status = http.StatusOK
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			result.Status = http.StatusNotFound
+			result.Error = i18n.T(lang, "error.movie_not_found")
+		case errors.Is(err, data.ErrEditConflict):
+			result.Status = http.StatusConflict
+			result.Error = i18n.T(lang, "error.edit_conflict")
+		default:
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if movie != nil {
+		result.ID = movie.ID
+		result.Version = movie.Version
+		result.Movie = newMovieResponse(movie, format)
+	}
+
+	return result, nil
+}
+
+//failedValidation marks the result as failing validation, with the translated message for each field.
+func (result *batchResult) failedValidation(lang string, v *validator.Validator) *batchResult {
+	result.Status = http.StatusUnprocessableEntity
+	result.Error = i18n.T(lang, "error.validation_failed")
+	result.Errors = make(map[string]string, len(v.Errors))
+
+	for field, key := range v.Errors {
+		result.Errors[field] = i18n.T(lang, key)
+	}
+
+	return result
+}
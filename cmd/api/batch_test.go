@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/codec"
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//batchTestMovies keeps movies in memory. InTx() saves them before running its function, and puts them back if it fails,
+//as rolling back a transaction would.
+type batchTestMovies struct {
+	data.MockMovieModel
+
+	movies map[int64]data.Movie
+	nextID int64
+}
+
+func (m *batchTestMovies) InTx(fn func(tx *sql.Tx) error) error {
+	saved := make(map[int64]data.Movie, len(m.movies))
+	for id, movie := range m.movies {
+		saved[id] = movie
+	}
+	savedID := m.nextID
+
+	err := fn(nil)
+	if err != nil {
+		m.movies, m.nextID = saved, savedID
+	}
+
+	return err
+}
+
+func (m *batchTestMovies) InsertTx(tx *sql.Tx, movie *data.Movie, audit data.Audit) error {
+	m.nextID++
+	movie.ID = m.nextID
+	movie.Version = 1
+	m.movies[movie.ID] = *movie
+
+	return nil
+}
+
+func (m *batchTestMovies) GetTx(tx *sql.Tx, id int64) (*data.Movie, error) {
+	movie, ok := m.movies[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return &movie, nil
+}
+
+func (m *batchTestMovies) UpdateTx(tx *sql.Tx, movie *data.Movie, audit data.Audit) error {
+	movie.Version++
+	m.movies[movie.ID] = *movie
+
+	return nil
+}
+
+func (m *batchTestMovies) DeleteTx(tx *sql.Tx, id int64, version *int32, audit data.Audit) error {
+	movie, ok := m.movies[id]
+	switch {
+	case !ok:
+		return data.ErrRecordNotFound
+	case version != nil && *version != movie.Version:
+		return data.ErrEditConflict
+	}
+
+	delete(m.movies, id)
+
+	return nil
+}
+
+func TestBatchMovies(t *testing.T) {
+	//The create and update succeed, but the delete and the unknown operation fail
+	mixed := `{"operations":[
+		{"op":"create","movie":{"title":"Heat","year":1995,"runtime":"170 mins","genres":["crime"]}},
+		{"op":"update","id":1,"version":2,"movie":{"year":1980}},
+		{"op":"delete","id":99},
+		{"op":"rename","id":1}
+	]}`
+
+	succeeds := `{"operations":[
+		{"op":"create","movie":{"title":"Heat","year":1995,"runtime":"170 mins","genres":["crime"]}},
+		{"op":"delete","id":1,"version":2}
+	]}`
+
+	tests := []struct {
+		name         string
+		query        string
+		body         string
+		wantStatus   int
+		wantStatuses []int
+		wantMovies   []int64
+	}{
+		{"each on its own", "", mixed, http.StatusOK, []int{201, 200, 404, 422}, []int64{1, 2}},
+		{"atomic", "?atomic=true", mixed, http.StatusUnprocessableEntity, []int{424, 424, 404, 424}, []int64{1}},
+		{"atomic success", "?atomic=true", succeeds, http.StatusOK, []int{201, 200}, []int64{2}},
+		{"stale version", "", `{"operations":[{"op":"delete","id":1,"version":1}]}`, http.StatusOK, []int{409}, []int64{1}},
+		{"invalid movie", "", `{"operations":[{"op":"update","id":1,"movie":{"title":""}}]}`, http.StatusOK, []int{422}, []int64{1}},
+		{"no operations", "", `{"operations":[]}`, http.StatusUnprocessableEntity, nil, []int64{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movies := &batchTestMovies{
+				movies: map[int64]data.Movie{1: {ID: 1, Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Version: 2}},
+				nextID: 1,
+			}
+
+			app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+			app.models.Movies = movies
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, httptest.NewRequest(http.MethodPost, pathMoviesBatch+tt.query, strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			var body struct {
+				Results []struct {
+					Index  int `json:"index"`
+					Status int `json:"status"`
+				} `json:"results"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			var statuses []int
+			for i, result := range body.Results {
+				if result.Index != i {
+					t.Errorf("got index %d for result %d", result.Index, i)
+				}
+				statuses = append(statuses, result.Status)
+			}
+
+			if !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("got statuses %v, want %v", statuses, tt.wantStatuses)
+			}
+
+			var ids []int64
+			for id := int64(1); id <= movies.nextID; id++ {
+				if _, ok := movies.movies[id]; ok {
+					ids = append(ids, id)
+				}
+			}
+
+			if !reflect.DeepEqual(ids, tt.wantMovies) {
+				t.Errorf("got movies %v saved, want %v", ids, tt.wantMovies)
+			}
+		})
+	}
+}
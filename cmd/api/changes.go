@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/changefeed"
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+//movieChangesChannel is the PostgreSQL notification channel the movies table's trigger sends changes on.
+const movieChangesChannel = "movie_changes"
+
+//changeSubscriberBuffer is how many events a /v1/movies/changes client can fall behind by before it's disconnected.
+//It can then reconnect and catch up from the replay log.
+const changeSubscriberBuffer = 64
+
+//listenForMovieChanges listens for the notifications the movies table's trigger sends whenever a movie is created,
+//updated or deleted, and publishes them to the change feed. It runs until the application starts shutting down.
+func (app *application) listenForMovieChanges() {
+	listener := pq.NewListener(app.config.db.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.Printf("movie changes listener: %v", err)
+		}
+	})
+
+	//Closing the listener ends the loop below, and also stops Listen() waiting for a connection
+	go func() {
+		<-app.done
+		listener.Close()
+	}()
+
+	err := listener.Listen(movieChangesChannel)
+	if err != nil {
+		app.logger.Printf("movie changes listener: %v", err)
+		return
+	}
+
+	//Ping the connection now and then, so that if it has quietly died, the listener notices and reconnects
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+
+			//A nil notification means the connection was lost and has been made again. Anything sent in between is
+			//gone, so the replay log can't be trusted to be complete any more
+			if n == nil {
+				app.changes.Reset()
+				continue
+			}
+
+			app.publishMovieChange(n.Extra)
+
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+//publishMovieChange decodes a notification from the movies table's trigger and publishes it to the change feed.
+func (app *application) publishMovieChange(payload string) {
+	var change struct {
+		ID    int64      `json:"id"`
+		Type  string     `json:"type"`
+		Movie data.Movie `json:"movie"`
+	}
+
+	err := json.Unmarshal([]byte(payload), &change)
+	if err != nil {
+		app.logger.Printf("movie changes listener: decoding %q: %v", payload, err)
+		return
+	}
+
+	//Notifications are limited in size, so the trigger leaves out the fields of a very large movie, and it's fetched
+	//instead. The movie may have changed again since, so it's only used if it's still at the event's version. Otherwise,
+	//and for a deleted movie, which can't be fetched, the event only has the movie's ID and version
+	if change.Movie.Title == "" && change.Type != "deleted" {
+		movie, err := app.models.Movies.Get(change.Movie.ID)
+		switch {
+		case err == nil:
+			if movie.Version == change.Movie.Version {
+				change.Movie = *movie
+			}
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.logger.Println(err)
+		}
+	}
+
+	app.changes.Publish(changefeed.Event{
+		ID:   strconv.FormatInt(change.ID, 10),
+		Type: change.Type,
+		Data: &change.Movie,
+	})
+}
+
+//movieChangesHandler streams movie changes as Server-Sent Events, so caches can keep up without polling. Each event is
+//named "created", "updated" or "deleted" and carries the movie's ID, version and the movie itself, or just the ID and
+//version of a movie too large to send which has changed again since. A client which reconnects with a Last-Event-ID
+//header is sent the changes it missed, if they are still in the replay log. If they aren't, it's sent a "reset" event
+//first, meaning it should fetch the movies again, then everything in the log.
+func (app *application) movieChangesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverErrorResponse(w, r, errors.New("streaming is not supported by the response writer"))
+		return
+	}
+
+	//The stream is meant to stay open, so it mustn't be cut off by the server's write timeout
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	sub, replay, complete := app.changes.Subscribe(r.Header.Get("Last-Event-ID"))
+	defer app.changes.Unsubscribe(sub)
+
+	//X-Accel-Buffering stops nginx holding events back in its buffer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	//Ask clients to reconnect quickly, as the stream ends whenever the server shuts down or the client falls behind
+	fmt.Fprint(w, "retry: 2000\n\n")
+
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+
+	for _, e := range replay {
+		if err := writeMovieChange(w, e, runtimeFormat); err != nil {
+			app.logError(r, err)
+			return
+		}
+	}
+
+	flusher.Flush()
+
+	//Heartbeats are comments, which clients ignore. They stop proxies closing the connection for being idle
+	heartbeat := time.NewTicker(app.config.changes.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+
+			if err := writeMovieChange(w, e, runtimeFormat); err != nil {
+				app.logError(r, err)
+				return
+			}
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+//writeMovieChange writes a change feed event in the text/event-stream format.
+func writeMovieChange(w io.Writer, e changefeed.Event, format data.RuntimeFormat) error {
+	movie := e.Data.(*data.Movie)
+
+	js, err := json.Marshal(envelope{
+		"id":      movie.ID,
+		"version": movie.Version,
+		"movie":   newMovieResponse(movie, format),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, js)
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/changefeed"
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//changesTestMovies holds a single movie, at whatever version the test sets.
+type changesTestMovies struct {
+	data.MockMovieModel
+	movie *data.Movie
+}
+
+func (m changesTestMovies) Get(id int64, fields ...string) (*data.Movie, error) {
+	if m.movie == nil || m.movie.ID != id {
+		return nil, data.ErrRecordNotFound
+	}
+	return m.movie, nil
+}
+
+func TestPublishMovieChange(t *testing.T) {
+	stored := &data.Movie{ID: 7, Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Version: 3}
+
+	tests := []struct {
+		name      string
+		payload   string
+		stored    *data.Movie
+		wantTitle string
+		version   int32
+	}{
+		{"full payload", `{"id":1,"type":"updated","movie":{"id":7,"title":"Aliens","version":2}}`, stored, "Aliens", 2},
+		{"large movie at the same version", `{"id":2,"type":"updated","movie":{"id":7,"version":3}}`, stored, "Alien", 3},
+		{"large movie changed since", `{"id":3,"type":"updated","movie":{"id":7,"version":2}}`, stored, "", 2},
+		{"large movie deleted since", `{"id":4,"type":"created","movie":{"id":7,"version":1}}`, nil, "", 1},
+		{"deleted", `{"id":5,"type":"deleted","movie":{"id":7,"version":4}}`, stored, "", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), changes: changefeed.New(8, 8)}
+			app.models.Movies = changesTestMovies{movie: tt.stored}
+
+			sub, _, _ := app.changes.Subscribe("")
+			app.publishMovieChange(tt.payload)
+
+			event := <-sub.C
+			movie := event.Data.(*data.Movie)
+
+			if movie.ID != 7 || movie.Version != tt.version || movie.Title != tt.wantTitle {
+				t.Errorf("got movie %+v, want version %d and title %q", movie, tt.version, tt.wantTitle)
+			}
+		})
+	}
+}
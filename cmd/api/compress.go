@@ -0,0 +1,355 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"firstAPI.jweaver11.net/internal/accept"
+)
+
+//compressor is the interface shared by gzip.Writer and flate.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+//contentEncoder compresses responses with one content coding, such as gzip. Compressors are expensive to create, so
+//they are kept in a pool and reused. Other codings, like brotli, can be supported by adding an encoder for them.
+type contentEncoder struct {
+	name string
+	pool sync.Pool
+}
+
+//newContentEncoders returns the encoders for every content coding we support, in order of preference, compressing at
+//the given level. It returns an error if the level isn't valid.
+func newContentEncoders(level int) ([]*contentEncoder, error) {
+	//Check the level up front, so the pools never have to deal with an error
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		return nil, err
+	}
+
+	gz := &contentEncoder{name: "gzip"}
+	gz.pool.New = func() interface{} {
+		zw, _ := gzip.NewWriterLevel(io.Discard, level)
+		return zw
+	}
+
+	deflate := &contentEncoder{name: "deflate"}
+	deflate.pool.New = func() interface{} {
+		zw, _ := flate.NewWriter(io.Discard, level)
+		return zw
+	}
+
+	return []*contentEncoder{gz, deflate}, nil
+}
+
+//negotiateEncoding picks the encoder which best matches an Accept-Encoding header. The coding with the highest
+//q-value wins, with ties going to our order of preference, and "*" stands for any coding not listed by name. It
+//returns nil if the client doesn't accept any of them, in which case the response isn't compressed.
+func negotiateEncoding(header string, encoders []*contentEncoder) *contentEncoder {
+	if header == "" {
+		return nil
+	}
+
+	qValues := make(map[string]float64)
+	for _, pref := range accept.Parse(header) {
+		qValues[pref.Value] = pref.Q
+	}
+
+	var best *contentEncoder
+	bestQ := 0.0
+
+	for _, encoder := range encoders {
+		q, ok := qValues[encoder.name]
+		if !ok {
+			q = qValues["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = encoder, q
+		}
+	}
+
+	return best
+}
+
+//compress compresses responses with the best content coding the client accepts in its Accept-Encoding header.
+//Responses smaller than the configured minimum size aren't worth compressing and are sent as they are, with a
+//Content-Length. Every response gets "Vary: Accept-Encoding", as whether it's compressed depends on that header.
+//
+//A compressed response is a different representation, so its ETag gets the coding's name added, like "1-2-gzip". The
+//suffix is removed from the tags in If-None-Match and If-Match before the handler sees them, so handlers only ever
+//deal in their own tags.
+func (app *application) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var encoder *contentEncoder
+		if r.Method != http.MethodHead {
+			encoder = negotiateEncoding(r.Header.Get("Accept-Encoding"), app.encoders)
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoder: encoder, minSize: app.config.compression.minSize}
+
+		if encoder != nil {
+			cw.ifNoneMatch = r.Header.Get("If-None-Match")
+			r = trimEncodingETags(r, encoder.name)
+		}
+
+		next.ServeHTTP(cw, r)
+
+		err := cw.close()
+		if err != nil {
+			app.logError(r, err)
+		}
+	})
+}
+
+//trimEncodingETags removes the content coding's suffix from the tags in the request's If-None-Match and If-Match
+//headers. The request is only copied if there are any to remove.
+func trimEncodingETags(r *http.Request, coding string) *http.Request {
+	suffix := "-" + coding + `"`
+	cloned := false
+
+	for _, key := range []string{"If-None-Match", "If-Match"} {
+		header := r.Header.Get(key)
+		if !strings.Contains(header, suffix) {
+			continue
+		}
+
+		if !cloned {
+			r = r.Clone(r.Context())
+			cloned = true
+		}
+
+		tags := splitETags(header)
+		for i, tag := range tags {
+			if strings.HasSuffix(tag, suffix) {
+				tags[i] = strings.TrimSuffix(tag, suffix) + `"`
+			}
+		}
+
+		r.Header.Set(key, strings.Join(tags, ", "))
+	}
+
+	return r
+}
+
+//compressWriter is the http.ResponseWriter the compress middleware passes to handlers. It holds back the status and
+//the start of the body until it knows whether the response is worth compressing: as soon as the body reaches the
+//minimum size it starts compressing, and if the handler finishes first, the body is sent uncompressed.
+type compressWriter struct {
+	http.ResponseWriter
+	encoder     *contentEncoder
+	minSize     int
+	ifNoneMatch string //the request's If-None-Match header, before any suffixes were removed
+
+	status    int        //the status the handler sent, or 0 if it hasn't sent one yet
+	committed bool       //whether the status and headers have been sent to the client
+	buf       []byte     //the start of the body, until the response is committed
+	zw        compressor //the compressor, once the response is being compressed
+}
+
+//WriteHeader records the status. Responses which won't be compressed are sent straight away, without buffering.
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+
+	if !cw.compressible() {
+		cw.commit(false)
+	}
+}
+
+//Write buffers the body until it reaches the minimum size, then starts compressing.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.committed {
+		cw.buf = append(cw.buf, p...)
+
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+
+		err := cw.commit(true)
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+//Flush sends everything written so far to the client. A handler which flushes is streaming its response, so we can't
+//wait to see how big it is, and it's compressed if it can be.
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.committed {
+		if err := cw.commit(true); err != nil {
+			return
+		}
+	}
+
+	if cw.zw != nil {
+		cw.zw.Flush()
+	}
+
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Unwrap returns the underlying http.ResponseWriter, for http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+//compressible reports whether the response could be compressed, based on the request and the headers the handler
+//has set so far.
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+
+	switch {
+	case cw.encoder == nil:
+		return false
+	case cw.status < 200, cw.status == http.StatusNoContent, cw.status == http.StatusNotModified:
+		return false
+	case h.Get("Content-Encoding") != "":
+		return false
+	}
+
+	if length, err := strconv.Atoi(h.Get("Content-Length")); err == nil && length < cw.minSize {
+		return false
+	}
+
+	return compressibleType(h.Get("Content-Type"))
+}
+
+//compressibleType reports whether a media type is worth compressing. Text-based formats compress well, while
+//formats like images are compressed already. Event streams are left alone, as they are long-lived and flushed after
+//every event.
+func compressibleType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	switch {
+	case mediaType == "":
+		return true
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	}
+
+	for _, kind := range []string{"json", "xml", "msgpack", "ndjson", "jsonl"} {
+		if strings.Contains(mediaType, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//commit sends the status and headers, then any buffered body. If compressed is true the body is compressed from
+//here on, and Content-Length is removed because the compressed length isn't known until the end.
+func (cw *compressWriter) commit(compressed bool) error {
+	cw.committed = true
+
+	h := cw.Header()
+	addVary(h, "Accept-Encoding")
+
+	if compressed {
+		if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+			h.Set("Content-Type", http.DetectContentType(cw.buf))
+		}
+
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoder.name)
+
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+cw.encoder.name+`"`)
+		}
+
+		cw.zw = cw.encoder.pool.Get().(compressor)
+		cw.zw.Reset(cw.ResponseWriter)
+	} else if cw.status == http.StatusNotModified && cw.encoder != nil {
+		//If the client's cached copy is the compressed representation, a 304 has to confirm the compressed tag
+		etag := h.Get("ETag")
+		encoded := strings.TrimSuffix(etag, `"`) + "-" + cw.encoder.name + `"`
+
+		if etag != "" && strings.Contains(cw.ifNoneMatch, encoded) {
+			h.Set("ETag", encoded)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+//close finishes the response once the handler has returned. A response still being buffered is smaller than the
+//minimum size, so it's sent uncompressed with its exact Content-Length.
+func (cw *compressWriter) close() error {
+	if cw.status == 0 {
+		return nil
+	}
+
+	if !cw.committed {
+		if cw.status != http.StatusNotModified && cw.status != http.StatusNoContent {
+			cw.Header().Set("Content-Length", strconv.Itoa(len(cw.buf)))
+		}
+		return cw.commit(false)
+	}
+
+	if cw.zw == nil {
+		return nil
+	}
+
+	err := cw.zw.Close()
+	cw.zw.Reset(io.Discard)
+	cw.encoder.pool.Put(cw.zw)
+	cw.zw = nil
+
+	return err
+}
+
+//addVary adds a header name to the Vary header, unless it's already listed.
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, name) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", name)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	encoders, err := newContentEncoders(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"GZIP", "gzip"},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate;q=1, gzip;q=0.5", "deflate"},
+		{"*", "gzip"},
+		{"gzip;q=0, *", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"identity", ""},
+		{"br", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got := ""
+			if encoder := negotiateEncoding(tt.header, encoders); encoder != nil {
+				got = encoder.name
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewContentEncodersLevel(t *testing.T) {
+	if _, err := newContentEncoders(10); err == nil {
+		t.Error("level 10 gave no error")
+	}
+}
+
+//newCompressTestApp returns an application which compresses bodies of 100 bytes or more.
+func newCompressTestApp(t *testing.T) *application {
+	t.Helper()
+
+	encoders, err := newContentEncoders(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{logger: log.New(io.Discard, "", 0), encoders: encoders}
+	app.config.compression.minSize = 100
+
+	return app
+}
+
+//decodeBody returns the response body, decompressing it according to its Content-Encoding.
+func decodeBody(t *testing.T, res *http.Response) string {
+	t.Helper()
+
+	var r io.Reader = res.Body
+
+	switch res.Header.Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case "deflate":
+		r = flate.NewReader(res.Body)
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestCompress(t *testing.T) {
+	app := newCompressTestApp(t)
+
+	large := `{"movies":[` + strings.Repeat(`{"title":"Alien"},`, 20) + `{}]}`
+	small := `{"title":"Alien"}`
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantEncoding   string
+	}{
+		{"gzip", http.MethodGet, "gzip, deflate", "application/json", large, "gzip"},
+		{"deflate", http.MethodGet, "deflate", "application/json", large, "deflate"},
+		{"no Accept-Encoding", http.MethodGet, "", "application/json", large, ""},
+		{"smaller than the minimum", http.MethodGet, "gzip", "application/json", small, ""},
+		{"already compressed type", http.MethodGet, "gzip", "image/png", large, ""},
+		{"event stream", http.MethodGet, "gzip", "text/event-stream", large, ""},
+		{"no Content-Type", http.MethodGet, "gzip", "", large, "gzip"},
+		{"HEAD", http.MethodHead, "gzip", "application/json", large, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Header().Set("ETag", `"1-2"`)
+
+				//Write the body in pieces, so the buffering has to join them up
+				io.WriteString(w, tt.body[:10])
+				io.WriteString(w, tt.body[10:])
+			}))
+
+			r := httptest.NewRequest(tt.method, "/v1/movies", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			res := w.Result()
+
+			if got := res.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("got Content-Encoding %q, want %q", got, tt.wantEncoding)
+			}
+			if res.Header.Get("Vary") != "Accept-Encoding" {
+				t.Errorf("got Vary %q, want Accept-Encoding", res.Header.Get("Vary"))
+			}
+			if got := decodeBody(t, res); got != tt.body {
+				t.Errorf("got body %q, want %q", got, tt.body)
+			}
+
+			if tt.wantEncoding == "" {
+				if res.Header.Get("ETag") != `"1-2"` {
+					t.Errorf("got ETag %s, want it unchanged", res.Header.Get("ETag"))
+				}
+				return
+			}
+
+			if res.Header.Get("Content-Length") != "" {
+				t.Errorf("got Content-Length %s on a compressed response", res.Header.Get("Content-Length"))
+			}
+			if want := `"1-2-` + tt.wantEncoding + `"`; res.Header.Get("ETag") != want {
+				t.Errorf("got ETag %s, want %s", res.Header.Get("ETag"), want)
+			}
+		})
+	}
+}
+
+func TestCompressSmallResponseLength(t *testing.T) {
+	app := newCompressTestApp(t)
+
+	handler := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "created")
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/movies", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Length") != "7" || w.Body.String() != "created" {
+		t.Errorf("got status %d, Content-Length %q and body %q", w.Code, w.Header().Get("Content-Length"), w.Body.String())
+	}
+}
+
+func TestCompressETags(t *testing.T) {
+	app := newCompressTestApp(t)
+
+	var seen string
+
+	handler := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("If-None-Match")
+
+		w.Header().Set("ETag", `"1-2"`)
+		if seen == `"1-2"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		io.WriteString(w, strings.Repeat("a", 200))
+	}))
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantSeen    string
+		wantStatus  int
+		wantETag    string
+	}{
+		{"compressed tag", `"1-2-gzip"`, `"1-2"`, http.StatusNotModified, `"1-2-gzip"`},
+		{"uncompressed tag", `"1-2"`, `"1-2"`, http.StatusNotModified, `"1-2"`},
+		{"several tags", `"0-1-gzip", "1-2-gzip"`, `"0-1", "1-2"`, http.StatusOK, `"1-2-gzip"`},
+		{"stale tag", `"1-1-gzip"`, `"1-1"`, http.StatusOK, `"1-2-gzip"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if seen != tt.wantSeen {
+				t.Errorf("the handler saw If-None-Match %s, want %s", seen, tt.wantSeen)
+			}
+			if w.Code != tt.wantStatus || w.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("got status %d and ETag %s, want %d and %s", w.Code, w.Header().Get("ETag"), tt.wantStatus, tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	app := newCompressTestApp(t)
+
+	handler := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		//A streaming handler is compressed even though each flush is smaller than the minimum size
+		for i := 0; i < 3; i++ {
+			io.WriteString(w, `{"id":1}`+"\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/export", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	res := w.Result()
+
+	if !w.Flushed || res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got flushed %t and Content-Encoding %q", w.Flushed, res.Header.Get("Content-Encoding"))
+	}
+	if got := decodeBody(t, res); got != strings.Repeat(`{"id":1}`+"\n", 3) {
+		t.Errorf("got body %q", got)
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	tests := []struct {
+		existing []string
+		want     []string
+	}{
+		{nil, []string{"Accept-Encoding"}},
+		{[]string{"Accept"}, []string{"Accept", "Accept-Encoding"}},
+		{[]string{"Accept, accept-encoding"}, []string{"Accept, accept-encoding"}},
+		{[]string{"*"}, []string{"*"}},
+	}
+
+	for _, tt := range tests {
+		h := http.Header{"Vary": tt.existing}
+		addVary(h, "Accept-Encoding")
+
+		if got := strings.Join(h.Values("Vary"), "; "); got != strings.Join(tt.want, "; ") {
+			t.Errorf("with Vary %q, got %q, want %q", tt.existing, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+//Define a custom contextKey type, so our keys can't collide with keys set by other packages.
+type contextKey string
+
+//requestIDContextKey is the key for the current request's ID in the request context.
+const requestIDContextKey = contextKey("request_id")
+
+//contextSetRequestID returns a new copy of the request with the request ID added to the context.
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+//contextGetRequestID retrieves the request ID from the request context. Requests which didn't pass through the
+//requestID middleware have an empty ID.
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
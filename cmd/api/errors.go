@@ -1,50 +1,225 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+//Stable, machine-readable error codes. Clients should match on these rather than on the human-readable messages,
+//which are free to change.
+const (
+	codeInternalError      = "internal_error"
+	codeNotFound           = "not_found"
+	codeMovieNotFound      = "movie_not_found"
+	codeMethodNotAllowed   = "method_not_allowed"
+	codeBadRequest         = "bad_request"
+	codeValidationFailed   = "validation_failed"
+	codeEditConflict       = "edit_conflict"
+	codePreconditionFailed = "precondition_failed"
+	codeUnsupportedMedia   = "unsupported_media_type"
+	codeNotAcceptable      = "not_acceptable"
+	codePatchTestFailed    = "patch_test_failed"
+	codeInvalidPatch       = "invalid_patch"
+	codeIdempotencyReused  = "idempotency_key_reused"
+	codeIdempotencyPending = "idempotency_key_in_flight"
+	codeForbidden          = "forbidden"
 )
 
+//invalidParam describes a single failed validation check in a problem+json response.
+//Pointer is the RFC 6901 JSON pointer to the field, which is more precise than the name for nested and indexed fields.
+type invalidParam struct {
+	Name    string `json:"name"`
+	Pointer string `json:"pointer"`
+	Reason  string `json:"reason"`
+}
+
 func (app *application) logError(r *http.Request, err error) {
 	app.logger.Println(err)
 }
 
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
-	env := envelope{"error": message}
+//language returns the best supported language for the request, negotiated from its Accept-Language header.
+func (app *application) language(r *http.Request) string {
+	return i18n.Negotiate(r.Header.Get("Accept-Language"))
+}
+
+//errorResponse sends an error to the client. By default we use our {"error": ...} envelope, but if the client asks for
+//application/problem+json in the Accept header we send an RFC 7807 problem details document with a stable code instead.
+//Messages are expected to already be translated into the language from Accept-Language.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, code string, message interface{}) {
+	app.writeError(w, r, status, code, message, nil)
+}
+
+//writeError does the work for errorResponse(). If params is non-nil, problem+json responses list them as
+//"invalid_params" in place of the message.
+func (app *application) writeError(w http.ResponseWriter, r *http.Request, status int, code string, message interface{}, params []invalidParam) {
+	var env envelope
+
+	lang := app.language(r)
+	headers := http.Header{
+		"Content-Language": []string{lang},
+		"Vary":             []string{"Accept, Accept-Language"},
+	}
+
+	if acceptsMediaType(r, "application/problem+json", "application/json") {
+		env = problemDetails(r, lang, status, code, message, params)
+		headers.Set("Content-Type", "application/problem+json")
+	} else {
+		env = envelope{"error": message}
+	}
 
-	err := app.writeJSON(w, status, env, nil)
+	err := app.writeJSON(w, r, status, env, headers)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
 	}
 }
 
+//problemDetails builds the RFC 7807 representation of an error.
+func problemDetails(r *http.Request, lang string, status int, code string, message interface{}, params []invalidParam) envelope {
+	env := envelope{
+		"type":     "/problems/" + code,
+		"title":    http.StatusText(status),
+		"status":   status,
+		"instance": r.URL.RequestURI(),
+		"code":     code,
+	}
+
+	switch {
+	case params != nil:
+		env["detail"] = i18n.T(lang, "error.validation_failed")
+		env["invalid_params"] = params
+	default:
+		env["detail"] = fmt.Sprint(message)
+	}
+
+	return env
+}
+
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	message := i18n.T(app.language(r), "error.server")
+	app.errorResponse(w, r, http.StatusInternalServerError, codeInternalError, message)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "The requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	message := i18n.T(app.language(r), "error.not_found")
+	app.errorResponse(w, r, http.StatusNotFound, codeNotFound, message)
+}
+
+//movieNotFoundResponse is a more specific 404 for when the URL is valid but no matching movie exists.
+func (app *application) movieNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := i18n.T(app.language(r), "error.movie_not_found")
+	app.errorResponse(w, r, http.StatusNotFound, codeMovieNotFound, message)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	message := i18n.T(app.language(r), "error.method_not_allowed", r.Method)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, message)
 }
 
+//badRequestResponse translates the error if it is an i18n message, such as those returned by readJSON().
+//Other errors are sent as they are.
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	message := err.Error()
+
+	var msg *i18n.Message
+	if errors.As(err, &msg) {
+		message = msg.Translate(app.language(r))
+	}
+
+	app.errorResponse(w, r, http.StatusBadRequest, codeBadRequest, message)
 }
 
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+//failedValidationResponse translates the validation message keys before sending them. The default response keeps
+//the first message for each field, while problem+json responses list every error with its JSON pointer.
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	lang := app.language(r)
+
+	translated := make(map[string]string, len(v.Errors))
+	for field, key := range v.Errors {
+		translated[field] = i18n.T(lang, key)
+	}
+
+	params := []invalidParam{}
+	for _, fe := range v.FieldErrors() {
+		params = append(params, invalidParam{
+			Name:    fe.Field(),
+			Pointer: fe.Pointer,
+			Reason:  i18n.T(lang, fe.Message),
+		})
+	}
+
+	//Sort by pointer so the output is stable, keeping the order checks were made in for the same field
+	sort.SliceStable(params, func(i, j int) bool { return params[i].Pointer < params[j].Pointer })
+
+	app.writeError(w, r, http.StatusUnprocessableEntity, codeValidationFailed, translated, params)
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+	message := i18n.T(app.language(r), "error.edit_conflict")
+	app.errorResponse(w, r, http.StatusConflict, codeEditConflict, message)
+}
+
+//preconditionFailedResponse is sent when an If-Match header doesn't match the movie's current version.
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := i18n.T(app.language(r), "error.precondition_failed")
+	app.errorResponse(w, r, http.StatusPreconditionFailed, codePreconditionFailed, message)
+}
+
+//unsupportedMediaTypeResponse is sent when the request body's Content-Type isn't one the endpoint accepts.
+//The accepted types are listed in the Accept-Patch header, as RFC 5789 recommends for PATCH, or in Accept-Post for POST.
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, accepted []string) {
+	header := "Accept-Post"
+	if r.Method == http.MethodPatch {
+		header = "Accept-Patch"
+	}
+	w.Header().Set(header, strings.Join(accepted, ", "))
+
+	message := i18n.T(app.language(r), "error.unsupported_media_type", r.Header.Get("Content-Type"))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMedia, message)
+}
+
+//notAcceptableResponse is sent when the client doesn't accept any of the formats we can send the response in. The error
+//itself is sent as JSON, and the formats we do support are listed in the message.
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+	message := i18n.T(app.language(r), "error.not_acceptable", strings.Join(app.codecs.MediaTypes(), ", "))
+	app.errorResponse(w, r, http.StatusNotAcceptable, codeNotAcceptable, message)
+}
+
+//patchTestFailedResponse is sent when a "test" operation in a JSON Patch doesn't match the movie.
+func (app *application) patchTestFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := i18n.T(app.language(r), "error.patch_test_failed")
+	app.errorResponse(w, r, http.StatusConflict, codePatchTestFailed, message)
+}
+
+//invalidPatchResponse is sent when a well-formed patch can't be applied to the movie.
+func (app *application) invalidPatchResponse(w http.ResponseWriter, r *http.Request, err error) {
+	message := i18n.T(app.language(r), "error.invalid_patch", err.Error())
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeInvalidPatch, message)
+}
+
+//idempotencyKeyReusedResponse is sent when an Idempotency-Key is sent again with a different request.
+func (app *application) idempotencyKeyReusedResponse(w http.ResponseWriter, r *http.Request) {
+	message := i18n.T(app.language(r), "error.idempotency_key_reused")
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeIdempotencyReused, message)
+}
+
+//idempotencyKeyInFlightResponse is sent when a request is retried before the original request with the same
+//Idempotency-Key has finished.
+func (app *application) idempotencyKeyInFlightResponse(w http.ResponseWriter, r *http.Request) {
+	message := i18n.T(app.language(r), "error.idempotency_key_in_flight")
+	app.errorResponse(w, r, http.StatusConflict, codeIdempotencyPending, message)
+}
+
+//forbiddenResponse is sent when a request needs an administrator, and the client didn't send the admin token.
+func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
+	message := i18n.T(app.language(r), "error.forbidden")
+	app.errorResponse(w, r, http.StatusForbidden, codeForbidden, message)
 }
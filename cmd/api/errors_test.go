@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+func TestErrorResponse(t *testing.T) {
+	app := &application{logger: log.New(io.Discard, "", 0)}
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		want            map[string]interface{}
+	}{
+		{"no Accept", "", "application/json", map[string]interface{}{
+			"error": "the requested movie could not be found",
+		}},
+		{"JSON", "application/json", "application/json", map[string]interface{}{
+			"error": "the requested movie could not be found",
+		}},
+		{"JSON preferred", "application/json, application/problem+json;q=0.5", "application/json", map[string]interface{}{
+			"error": "the requested movie could not be found",
+		}},
+		{"problem+json", "application/problem+json", "application/problem+json", map[string]interface{}{
+			"type":     "/problems/movie_not_found",
+			"title":    "Not Found",
+			"status":   float64(http.StatusNotFound),
+			"detail":   "the requested movie could not be found",
+			"instance": "/v1/movies/7?fields=title",
+			"code":     "movie_not_found",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/7?fields=title", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			app.movieNotFoundResponse(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusNotFound)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got Content-Type %q, want %q", got, tt.wantContentType)
+			}
+
+			var got map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("got %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+//TestErrorCodes checks each response helper sends the status and stable code clients match on.
+func TestErrorCodes(t *testing.T) {
+	app := &application{logger: log.New(io.Discard, "", 0)}
+
+	tests := []struct {
+		name       string
+		respond    func(w http.ResponseWriter, r *http.Request)
+		wantStatus int
+		wantCode   string
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) { app.serverErrorResponse(w, r, io.ErrUnexpectedEOF) }, http.StatusInternalServerError, codeInternalError},
+		{"not found", app.notFoundResponse, http.StatusNotFound, codeNotFound},
+		{"method not allowed", app.methodNotAllowedResponse, http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"bad request", func(w http.ResponseWriter, r *http.Request) { app.badRequestResponse(w, r, io.ErrUnexpectedEOF) }, http.StatusBadRequest, codeBadRequest},
+		{"edit conflict", app.editConflictResponse, http.StatusConflict, codeEditConflict},
+		{"precondition failed", app.preconditionFailedResponse, http.StatusPreconditionFailed, codePreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/7", nil)
+			r.Header.Set("Accept", "application/problem+json")
+
+			w := httptest.NewRecorder()
+			tt.respond(w, r)
+
+			var got struct {
+				Status int    `json:"status"`
+				Code   string `json:"code"`
+				Type   string `json:"type"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if w.Code != tt.wantStatus || got.Status != tt.wantStatus {
+				t.Errorf("got status %d and %d in the body, want %d", w.Code, got.Status, tt.wantStatus)
+			}
+			if got.Code != tt.wantCode || got.Type != "/problems/"+tt.wantCode {
+				t.Errorf("got code %q and type %q, want %q", got.Code, got.Type, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestFailedValidationResponse(t *testing.T) {
+	app := &application{logger: log.New(io.Discard, "", 0)}
+
+	v := validator.New()
+	v.AddError("title", "validation.required")
+	v.AddError("/genres/1", "validation.required")
+	v.AddError("genres", i18n.Key("validation.max_items", "5"))
+	v.AddError("/genres/0", "validation.required")
+
+	tests := []struct {
+		name           string
+		accept         string
+		acceptLanguage string
+		want           string
+	}{
+		{"default", "", "", `{"error":{"genres":"must be provided","title":"must be provided"}}`},
+		{"problem+json", "application/problem+json", "", `{"code":"validation_failed","detail":"one or more fields failed validation",` +
+			`"instance":"/v1/movies","invalid_params":[` +
+			`{"name":"genres","pointer":"/genres","reason":"must not contain more than 5 items"},` +
+			`{"name":"genres","pointer":"/genres/0","reason":"must be provided"},` +
+			`{"name":"genres","pointer":"/genres/1","reason":"must be provided"},` +
+			`{"name":"title","pointer":"/title","reason":"must be provided"}],` +
+			`"status":422,"title":"Unprocessable Entity","type":"/problems/validation_failed"}`},
+		{"Spanish", "", "es", `{"error":{"genres":"es obligatorio","title":"es obligatorio"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+
+			w := httptest.NewRecorder()
+			app.failedValidationResponse(w, r, v)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
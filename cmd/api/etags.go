@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//movieETag returns a strong entity tag for a movie, built from its ID and version so it changes whenever the movie does.
+//The variant describes anything else which changes the representation, such as the runtime format, so that different
+//representations of the same version get different tags.
+func movieETag(movie *data.Movie, variant string) string {
+	if variant == "" {
+		return fmt.Sprintf(`"%d-%d"`, movie.ID, movie.Version)
+	}
+	return fmt.Sprintf(`"%d-%d-%s"`, movie.ID, movie.Version, variant)
+}
+
+//movieListETag returns a strong entity tag for a list of movies, which is a hash of each movie's ID and version
+//along with the total number of movies, which the pagination links depend on, and the representation variant.
+func movieListETag(movies []*data.Movie, metadata data.Metadata, variant string) string {
+	h := sha256.New()
+
+	for _, movie := range movies {
+		fmt.Fprintf(h, "%d-%d,", movie.ID, movie.Version)
+	}
+	fmt.Fprintf(h, "%d,", metadata.TotalRecords)
+	fmt.Fprint(h, variant)
+
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+//runtimeVariant returns the ETag variant for a runtime format. The default format has no variant.
+func runtimeVariant(format data.RuntimeFormat) string {
+	if format == data.RuntimeFormatMins {
+		return ""
+	}
+	return string(format)
+}
+
+//movieVariant returns the ETag variant for a movie's runtime format and sparse fieldset, so that each selection of
+//fields gets its own tags. The fields must be in the order data.SelectFields() puts them in.
+func movieVariant(format data.RuntimeFormat, fields []string) string {
+	var parts []string
+
+	if variant := runtimeVariant(format); variant != "" {
+		parts = append(parts, variant)
+	}
+	if len(fields) > 0 {
+		parts = append(parts, "fields."+strings.Join(fields, "."))
+	}
+
+	return strings.Join(parts, "-")
+}
+
+//notModified reports whether the request's If-None-Match header matches the current ETag, in which case the client's
+//cached copy is still good. If-None-Match uses the weak comparison, so W/ prefixes are ignored.
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range splitETags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+//ifMatchVersions parses the If-Match header for a movie. It returns ok=false if there is no header. Otherwise it returns
+//the movie versions the client will accept, or any=true for "*". Because If-Match uses the strong comparison,
+//weak tags and tags for other movies never match, so they are left out.
+func ifMatchVersions(r *http.Request, id int64) (versions []int32, any bool, ok bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil, false, false
+	}
+
+	for _, tag := range splitETags(header) {
+		if tag == "*" {
+			return nil, true, true
+		}
+
+		tagID, version, valid := parseMovieETag(tag)
+		if valid && tagID == id {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, false, true
+}
+
+//ifMatchAllows reports whether the If-Match header (if any) accepts the movie's current version.
+func ifMatchAllows(r *http.Request, movie *data.Movie) bool {
+	versions, any, ok := ifMatchVersions(r, movie.ID)
+	if !ok || any {
+		return true
+	}
+
+	for _, version := range versions {
+		if version == movie.Version {
+			return true
+		}
+	}
+
+	return false
+}
+
+//parseMovieETag extracts the movie ID and version from a tag created by movieETag(), ignoring any variant.
+func parseMovieETag(tag string) (int64, int32, bool) {
+	if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+		return 0, 0, false
+	}
+
+	parts := strings.SplitN(strings.Trim(tag, `"`), "-", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	version, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return id, int32(version), true
+}
+
+//splitETags splits a comma-separated list of entity tags from an If-Match or If-None-Match header.
+func splitETags(header string) []string {
+	var tags []string
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+//notModifiedResponse sends a 304 Not Modified response with the current ETag and no body.
+func (app *application) notModifiedResponse(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+}
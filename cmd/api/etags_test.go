@@ -0,0 +1,187 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/codec"
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//etagTestMovies holds movie 1 at version 2. Updates and deletes only succeed at that version.
+type etagTestMovies struct {
+	data.MockMovieModel
+	deleted bool
+}
+
+func (m *etagTestMovies) Get(id int64, fields ...string) (*data.Movie, error) {
+	if id != 1 || m.deleted {
+		return nil, data.ErrRecordNotFound
+	}
+	return &data.Movie{ID: 1, Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama"}, Version: 2}, nil
+}
+
+func (m *etagTestMovies) Update(movie *data.Movie, audit data.Audit) error {
+	if movie.Version != 2 {
+		return data.ErrEditConflict
+	}
+	movie.Version++
+	return nil
+}
+
+func (m *etagTestMovies) Delete(id int64, audit data.Audit) error {
+	m.deleted = true
+	return nil
+}
+
+func (m *etagTestMovies) DeleteVersion(id int64, version int32, audit data.Audit) error {
+	if version != 2 {
+		return data.ErrEditConflict
+	}
+	m.deleted = true
+	return nil
+}
+
+func TestMovieETag(t *testing.T) {
+	movie := &data.Movie{ID: 7, Version: 3}
+
+	tests := []struct {
+		format data.RuntimeFormat
+		fields []string
+		want   string
+	}{
+		{data.RuntimeFormatMins, nil, `"7-3"`},
+		{data.RuntimeFormatISO8601, nil, `"7-3-iso8601"`},
+		{data.RuntimeFormatMins, []string{"id", "title"}, `"7-3-fields.id.title"`},
+		{data.RuntimeFormatISO8601, []string{"title"}, `"7-3-iso8601-fields.title"`},
+	}
+
+	for _, tt := range tests {
+		if got := movieETag(movie, movieVariant(tt.format, tt.fields)); got != tt.want {
+			t.Errorf("with %s and %v, got %s, want %s", tt.format, tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestMovieListETag(t *testing.T) {
+	movies := []*data.Movie{{ID: 1, Version: 1}, {ID: 2, Version: 4}}
+	etag := movieListETag(movies, data.Metadata{TotalRecords: 2}, "")
+
+	changed := []*data.Movie{{ID: 1, Version: 1}, {ID: 2, Version: 5}}
+
+	if etag != movieListETag(movies, data.Metadata{TotalRecords: 2}, "") {
+		t.Error("the same page got a different tag")
+	}
+	if etag == movieListETag(changed, data.Metadata{TotalRecords: 2}, "") {
+		t.Error("a changed movie didn't change the tag")
+	}
+	if etag == movieListETag(movies, data.Metadata{TotalRecords: 3}, "") {
+		t.Error("a changed total didn't change the tag")
+	}
+	if etag == movieListETag(movies, data.Metadata{TotalRecords: 2}, "iso8601") {
+		t.Error("a different variant didn't change the tag")
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"1-2"`, true},
+		{`W/"1-2"`, true},
+		{`"1-1", "1-2"`, true},
+		{`"1-1"`, false},
+		{`"1-2-iso8601"`, false},
+		{"*", true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+		r.Header.Set("If-None-Match", tt.header)
+
+		if got := notModified(r, `"1-2"`); got != tt.want {
+			t.Errorf("If-None-Match %s: got %t, want %t", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestIfMatchAllows(t *testing.T) {
+	movie := &data.Movie{ID: 1, Version: 2}
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", true},
+		{"*", true},
+		{`"1-2"`, true},
+		{`"1-2-iso8601"`, true},
+		{`"1-1", "1-2"`, true},
+		{`"1-1"`, false},
+		{`W/"1-2"`, false},
+		{`"2-2"`, false},
+		{`"nonsense"`, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPatch, "/v1/movies/1", nil)
+		r.Header.Set("If-Match", tt.header)
+
+		if got := ifMatchAllows(r, movie); got != tt.want {
+			t.Errorf("If-Match %s: got %t, want %t", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		header     string
+		value      string
+		body       string
+		wantStatus int
+		wantETag   string
+	}{
+		{"GET", http.MethodGet, "/v1/movies/1", "", "", "", http.StatusOK, `"1-2"`},
+		{"GET cached", http.MethodGet, "/v1/movies/1", "If-None-Match", `"1-2"`, "", http.StatusNotModified, `"1-2"`},
+		{"GET stale", http.MethodGet, "/v1/movies/1", "If-None-Match", `"1-1"`, "", http.StatusOK, `"1-2"`},
+		{"GET other fields", http.MethodGet, "/v1/movies/1?fields=title", "If-None-Match", `"1-2"`, "", http.StatusOK, `"1-2-fields.title"`},
+		{"PATCH", http.MethodPatch, "/v1/movies/1", "If-Match", `"1-2"`, `{"year":1943}`, http.StatusOK, `"1-3"`},
+		{"PATCH stale", http.MethodPatch, "/v1/movies/1", "If-Match", `"1-1"`, `{"year":1943}`, http.StatusPreconditionFailed, ""},
+		{"DELETE", http.MethodDelete, "/v1/movies/1", "If-Match", `"1-1", "1-2"`, "", http.StatusOK, ""},
+		{"DELETE stale", http.MethodDelete, "/v1/movies/1", "If-Match", `"1-1"`, "", http.StatusPreconditionFailed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+			app.models.Movies = &etagTestMovies{}
+
+			r := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("got ETag %s, want %s", got, tt.wantETag)
+			}
+			if tt.wantStatus == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("got a body with a 304: %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/movieio"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+//exportFlushRows is how often, in movies, an export is flushed to the client, and exportWriteTimeout is how long the
+//client has to read each flushed chunk.
+const (
+	exportFlushRows    = 1000
+	exportWriteTimeout = 30 * time.Second
+)
+
+//exportMovies writes every movie which matches the filters to w, calling flush (if it isn't nil) every exportFlushRows
+//movies. Paging in the filters is ignored. The export stops, closing its transaction, if ctx is cancelled.
+func (app *application) exportMovies(ctx context.Context, w io.Writer, format movieio.Format, title string, genres []string, filters data.Filters, deleted bool, flush func()) error {
+	mw, err := movieio.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+
+	err = app.models.Movies.Export(ctx, title, genres, filters, deleted, func(movie *data.Movie) error {
+		err := mw.Write(movie)
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushRows == 0 {
+			if err := mw.Flush(); err != nil {
+				return err
+			}
+			if flush != nil {
+				flush()
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return mw.Flush()
+}
+
+//exportMoviesHandler streams the movie catalogue for the "GET /v1/movies/export?format=csv|ndjson" endpoint. It takes
+//the same title, genres, sort and deleted parameters as listing movies, but returns every match rather than a page.
+//The response is flushed as it goes, so it starts straight away and the server's memory use doesn't grow with its size.
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	format := movieio.Format(app.readString(qs, "format", string(movieio.CSV)))
+	v.Check(validator.In(string(format), movieio.Formats...), "format", i18n.Key("validation.oneof", "csv, ndjson"))
+
+	title := app.readString(qs, "title", "")
+	genres := app.readCSV(qs, "genres", []string{})
+
+	filters := data.Filters{
+		Sort:         app.readString(qs, "sort", "id"),
+		SortSafelist: movieSortSafelist,
+	}
+	v.Check(validator.In(filters.Sort, filters.SortSafelist...), "sort", "validation.sort.invalid")
+
+	//Only administrators can export the soft deleted movies
+	deleted := app.readBool(qs, "deleted", false, v)
+	if deleted && !app.isAdmin(r) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.MediaType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="movies.%s"`, format))
+
+	//A full export can take much longer than the server's write timeout, so the write deadline is pushed back each time
+	//a chunk is flushed instead. A client which stops reading is still cut off
+	rc := http.NewResponseController(w)
+
+	extendDeadline := func() {
+		err := rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			app.logError(r, err)
+		}
+	}
+
+	flush := func() {
+		extendDeadline()
+		rc.Flush()
+	}
+
+	extendDeadline()
+
+	//The export runs on the request's context, so a client which goes away doesn't hold the cursor open
+	sw := &sentWriter{Writer: w}
+
+	err := app.exportMovies(r.Context(), sw, format, title, genres, filters, deleted, flush)
+	if err != nil {
+		//If nothing has been sent yet, such as when the database can't be reached, we can still send an error response
+		if !sw.sent {
+			w.Header().Del("Content-Disposition")
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		//Otherwise the status and some of the body have already been sent. Aborting the handler closes the connection,
+		//so the client can tell the export is incomplete
+		app.logError(r, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+//sentWriter records whether anything has been written through it.
+type sentWriter struct {
+	io.Writer
+	sent bool
+}
+
+func (sw *sentWriter) Write(p []byte) (int, error) {
+	sw.sent = true
+	return sw.Writer.Write(p)
+}
+
+//exportCommand runs "api export [flags] FILE", writing the movie catalogue to a CSV or NDJSON file. The file is written
+//under a temporary name and renamed once it's complete, so a failed export never leaves a partial file behind. A FILE of
+//"-" writes to standard output. It returns the exit status.
+func exportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+
+	dsn := fs.String("db-dsn", os.Getenv("FIRSTAPIDB_DB_DSN"), "PostgreSQL DSN")
+	format := fs.String("format", "", "File format (csv|ndjson), guessed from the file name if not set")
+	title := fs.String("title", "", "Only export movies with all of these words in the title")
+	genres := fs.String("genres", "", "Only export movies with all of these comma-separated genres")
+	sort := fs.String("sort", "id", "Sort order, as for GET /v1/movies")
+	deleted := fs.Bool("deleted", false, "Export the soft deleted movies instead of the live ones")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api export [flags] FILE")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	name := fs.Arg(0)
+	if *format == "" {
+		*format = string(movieio.FormatForFilename(name))
+	}
+
+	if fs.NArg() != 1 || !validator.In(*format, movieio.Formats...) || !validator.In(*sort, movieSortSafelist...) {
+		fs.Usage()
+		return 2
+	}
+
+	var genreList []string
+	if *genres != "" {
+		genreList = strings.Split(*genres, ",")
+	}
+
+	logger := log.New(os.Stderr, "", log.Ldate|log.Ltime)
+
+	app, err := newCommandApplication(*dsn, logger)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	defer app.db.Close()
+
+	filters := data.Filters{Sort: *sort, SortSafelist: movieSortSafelist}
+
+	if name == "-" {
+		err = app.exportMovies(context.Background(), os.Stdout, movieio.Format(*format), *title, genreList, filters, *deleted, nil)
+		if err != nil {
+			logger.Println(err)
+			return 1
+		}
+		return 0
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	defer os.Remove(file.Name())
+
+	err = app.exportMovies(context.Background(), file, movieio.Format(*format), *title, genreList, filters, *deleted, nil)
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	return 0
+}
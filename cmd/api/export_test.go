@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/codec"
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//slowExportMovies exports a fixed number of movies, pausing after every flush's worth of them.
+type slowExportMovies struct {
+	data.MockMovieModel
+	count int
+	pause time.Duration
+}
+
+func (m slowExportMovies) Export(ctx context.Context, title string, genres []string, filters data.Filters, deleted bool, fn func(movie *data.Movie) error) error {
+	for i := 1; i <= m.count; i++ {
+		err := fn(&data.Movie{ID: int64(i), Title: "Movie", Year: 2000, Runtime: 90, Genres: []string{"drama"}, Version: 1})
+		if err != nil {
+			return err
+		}
+
+		if i%exportFlushRows == 0 {
+			time.Sleep(m.pause)
+		}
+	}
+
+	return nil
+}
+
+//failingExportMovies exports movies until it has exported failAfter of them, then fails. It fails straight away if
+//its context has been cancelled.
+type failingExportMovies struct {
+	data.MockMovieModel
+	failAfter int
+}
+
+func (m failingExportMovies) Export(ctx context.Context, title string, genres []string, filters data.Filters, deleted bool, fn func(movie *data.Movie) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i := 1; i <= m.failAfter; i++ {
+		err := fn(&data.Movie{ID: int64(i), Title: "Movie", Year: 2000, Runtime: 90, Genres: []string{"drama"}, Version: 1})
+		if err != nil {
+			return err
+		}
+	}
+
+	return errors.New("connection reset by peer")
+}
+
+func TestExportErrors(t *testing.T) {
+	app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+
+	t.Run("before anything is sent", func(t *testing.T) {
+		app.models.Movies = failingExportMovies{}
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/movies/export?format=ndjson", nil)
+		r.Header.Set("Accept", "application/problem+json")
+
+		w := httptest.NewRecorder()
+		app.exportMoviesHandler(w, r)
+
+		if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), codeInternalError) {
+			t.Errorf("got %d with body %s, want a 500 error response", w.Code, w.Body.String())
+		}
+		if w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("the error response was sent as an attachment")
+		}
+	})
+
+	t.Run("cancelled request", func(t *testing.T) {
+		app.models.Movies = failingExportMovies{failAfter: 10}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		w := httptest.NewRecorder()
+		app.exportMoviesHandler(w, httptest.NewRequest(http.MethodGet, "/v1/movies/export", nil).WithContext(ctx))
+
+		if w.Code != http.StatusInternalServerError || w.Body.Len() == 0 || strings.Contains(w.Body.String(), "Movie") {
+			t.Errorf("got %d with body %s, want the export stopped before it started", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("after some movies are sent", func(t *testing.T) {
+		app.models.Movies = failingExportMovies{failAfter: 2 * exportFlushRows}
+
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("got panic %v, want the handler aborted", p)
+			}
+		}()
+
+		app.exportMoviesHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/movies/export", nil))
+	})
+}
+
+func TestExportOutlivesWriteTimeout(t *testing.T) {
+	models := data.NewMockModels()
+	models.Movies = slowExportMovies{count: 5 * exportFlushRows, pause: 100 * time.Millisecond}
+
+	app := &application{logger: log.New(&strings.Builder{}, "", 0), models: models}
+
+	srv := httptest.NewUnstartedServer(app.routes())
+	srv.Config.WriteTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/v1/movies/export?format=ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	lines := 0
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		lines++
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("export was cut off after %d movies: %v", lines, err)
+	}
+
+	if lines != 5*exportFlushRows {
+		t.Errorf("got %d movies, want %d", lines, 5*exportFlushRows)
+	}
+}
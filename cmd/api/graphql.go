@@ -0,0 +1,587 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/graphql"
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+//graphqlHandler serves "POST /v1/graphql". The body is a JSON {"query", "operationName", "variables"} object, and the
+//response is always 200 OK with the "data" and "errors" GraphQL clients expect, even if the query had errors.
+func (app *application) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var input graphql.Request
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if strings.TrimSpace(input.Query) == "" {
+		app.badRequestResponse(w, r, i18n.Error("graphql.query_missing"))
+		return
+	}
+
+	//Each request gets its own loaders, so nothing loaded for one client is served to another
+	gc := &graphqlContext{
+		r:       r,
+		lang:    app.language(r),
+		movies:  graphql.NewLoader(app.loadMovies),
+		history: graphql.NewLoader(app.loadMovieHistory),
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlContextKey, gc)
+
+	result := app.graphql.Execute(ctx, input)
+
+	env := envelope{}
+	if result.Data != nil {
+		env["data"] = result.Data
+	}
+	if len(result.Errors) > 0 {
+		env["errors"] = result.Errors
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//graphqlContextKey is the key for the graphqlContext in the context passed to resolvers.
+const graphqlContextKey = contextKey("graphql")
+
+//graphqlContext is what resolvers need from the HTTP request: the request itself for auditing, the client's language
+//for error messages, and the loaders which batch the queries for a request's movies and their history.
+type graphqlContext struct {
+	r       *http.Request
+	lang    string
+	movies  *graphql.Loader
+	history *graphql.Loader
+}
+
+//graphqlContextFrom returns the graphqlContext for a resolver.
+func graphqlContextFrom(ctx context.Context) *graphqlContext {
+	return ctx.Value(graphqlContextKey).(*graphqlContext)
+}
+
+//loadMovies fetches a batch of movies by ID, for the movies loader.
+func (app *application) loadMovies(keys []interface{}) (map[interface{}]interface{}, error) {
+	ids := make([]int64, len(keys))
+	for i, key := range keys {
+		ids[i] = key.(int64)
+	}
+
+	movies, err := app.models.Movies.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[interface{}]interface{}, len(movies))
+	for _, movie := range movies {
+		loaded[movie.ID] = movie
+	}
+
+	return loaded, nil
+}
+
+//loadMovieHistory fetches the history of a batch of movies by ID, for the history loader. Movies with no history get
+//an empty list rather than null.
+func (app *application) loadMovieHistory(keys []interface{}) (map[interface{}]interface{}, error) {
+	ids := make([]int64, len(keys))
+	for i, key := range keys {
+		ids[i] = key.(int64)
+	}
+
+	revisions, err := app.models.Revisions.GetAllForMovies(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[interface{}]interface{}, len(ids))
+	for _, id := range ids {
+		history := revisions[id]
+		if history == nil {
+			history = []*data.Revision{}
+		}
+		loaded[id] = history
+	}
+
+	return loaded, nil
+}
+
+//graphqlError returns a typed error for resolvers, with the same stable codes as our REST error responses in its
+//"extensions", and its message translated into the client's language.
+func graphqlError(lang, code, key string) *graphql.Error {
+	return &graphql.Error{
+		Message:    i18n.T(lang, key),
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
+
+//graphqlValidationError returns a typed error for failed validation, with the translated message for each field in
+//"extensions", as failedValidationResponse() sends them.
+func graphqlValidationError(lang string, v *validator.Validator) *graphql.Error {
+	fields := make(map[string]string, len(v.Errors))
+	for field, key := range v.Errors {
+		fields[field] = i18n.T(lang, key)
+	}
+
+	err := graphqlError(lang, codeValidationFailed, "error.validation_failed")
+	err.Extensions["fields"] = fields
+
+	return err
+}
+
+//graphqlServerError logs an unexpected error and returns a generic one for the client, as serverErrorResponse() does.
+func (app *application) graphqlServerError(gc *graphqlContext, err error) *graphql.Error {
+	app.logError(gc.r, err)
+	return graphqlError(gc.lang, codeInternalError, "error.server")
+}
+
+//graphqlMovieError returns the typed error for a movie model error, so ErrEditConflict and ErrRecordNotFound keep the
+//codes clients already handle from the REST API.
+func (app *application) graphqlMovieError(gc *graphqlContext, err error) *graphql.Error {
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		return graphqlError(gc.lang, codeEditConflict, "error.edit_conflict")
+	case errors.Is(err, data.ErrRecordNotFound):
+		return graphqlError(gc.lang, codeMovieNotFound, "error.movie_not_found")
+	default:
+		return app.graphqlServerError(gc, err)
+	}
+}
+
+//parseGraphQLID converts an ID argument into a movie ID. IDs which can't be movie IDs can't match a movie, so, like
+//readIDParam(), it returns ok as false.
+func parseGraphQLID(arg interface{}) (int64, bool) {
+	id, err := strconv.ParseInt(arg.(string), 10, 64)
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+//runtimeScalar is the GraphQL Runtime scalar. It's written as "<runtime> mins" by default, or in the format chosen with
+//the runtime field's format argument, and accepts anything ParseRuntime() does, or a whole number of minutes.
+var runtimeScalar = &graphql.Scalar{
+	Name:        "Runtime",
+	Description: `A movie's runtime. Written as "135 mins" unless another format is chosen, and accepted as "135 mins", "2h 15m", "PT2H15M" or a number of minutes.`,
+	Serialize: func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case data.Runtime:
+			return v.String(), nil
+		case data.FormattedRuntime:
+			return v.Runtime.Format(v.Format), nil
+		}
+		return nil, fmt.Errorf("Runtime cannot represent value: %v", v)
+	},
+	ParseValue: func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case string:
+			return data.ParseRuntime(v)
+		case int64:
+			return data.ParseRuntime(strconv.FormatInt(v, 10))
+		case float64:
+			if v == float64(int64(v)) {
+				return data.ParseRuntime(strconv.FormatInt(int64(v), 10))
+			}
+		}
+		return nil, data.ErrInvalidRuntimeFormat
+	},
+}
+
+//runtimeFormatEnum lists the runtime formats, as the runtime_format query string parameter does for REST responses.
+var runtimeFormatEnum = &graphql.Enum{
+	Name:   "RuntimeFormat",
+	Values: []string{"MINS", "ISO8601", "INTEGER"},
+}
+
+//movieResolver adapts a function of a movie into a resolver for one of the Movie type's fields.
+func movieResolver(fn func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error)) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*data.Movie), p)
+	}
+}
+
+//revisionResolver adapts a function of a revision into a resolver for one of the Revision type's fields.
+func revisionResolver(fn func(rev *data.Revision) interface{}) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*data.Revision)), nil
+	}
+}
+
+//movieList is the value of the movies query: a page of movies and where it sits in the whole list.
+type movieList struct {
+	movies   []*data.Movie
+	metadata data.Metadata
+}
+
+//newGraphQLSchema builds the schema served at /v1/graphql. It's built once, when the application starts, and its
+//resolvers use the application's models.
+func (app *application) newGraphQLSchema() (*graphql.Schema, error) {
+	revisionType := &graphql.Object{
+		Name:        "Revision",
+		Description: "One change in a movie's history.",
+		Fields: graphql.Fields{
+			"id":        {Type: graphql.NewNonNull(graphql.ID), Resolve: revisionResolver(func(rev *data.Revision) interface{} { return rev.ID })},
+			"version":   {Type: graphql.NewNonNull(graphql.Int), Resolve: revisionResolver(func(rev *data.Revision) interface{} { return rev.Version })},
+			"operation": {Type: graphql.NewNonNull(graphql.String), Resolve: revisionResolver(func(rev *data.Revision) interface{} { return rev.Operation })},
+			"actor":     {Type: graphql.NewNonNull(graphql.String), Resolve: revisionResolver(func(rev *data.Revision) interface{} { return rev.Actor })},
+			"requestId": {Type: graphql.String, Resolve: revisionResolver(func(rev *data.Revision) interface{} { return nullString(rev.RequestID) })},
+			"createdAt": {Type: graphql.NewNonNull(graphql.String), Resolve: revisionResolver(func(rev *data.Revision) interface{} { return rev.CreatedAt.Format(time.RFC3339) })},
+		},
+	}
+
+	movieType := &graphql.Object{
+		Name: "Movie",
+		Fields: graphql.Fields{
+			"id": {
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					return movie.ID, nil
+				}),
+			},
+			"title": {
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					return movie.Title, nil
+				}),
+			},
+			"year": {
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					return movie.Year, nil
+				}),
+			},
+			"runtime": {
+				Type: graphql.NewNonNull(runtimeScalar),
+				Args: graphql.Args{
+					"format": {Type: runtimeFormatEnum, DefaultValue: "MINS"},
+				},
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					format := data.RuntimeFormat(strings.ToLower(p.Args["format"].(string)))
+					return data.FormattedRuntime{Runtime: movie.Runtime, Format: format}, nil
+				}),
+			},
+			"genres": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					return movie.Genres, nil
+				}),
+			},
+			"version": {
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					return movie.Version, nil
+				}),
+			},
+			"externalId": {
+				Type: graphql.String,
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					return nullString(movie.ExternalID), nil
+				}),
+			},
+
+			//The history of every movie in a list is loaded with one query
+			"history": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(revisionType))),
+				Resolve: movieResolver(func(movie *data.Movie, p graphql.ResolveParams) (interface{}, error) {
+					return graphqlContextFrom(p.Context).history.Load(movie.ID), nil
+				}),
+			},
+		},
+	}
+
+	metadataType := &graphql.Object{
+		Name:        "Metadata",
+		Description: "Where a page of movies sits in the whole list. Every field is 0 if there are no results.",
+		Fields: graphql.Fields{
+			"currentPage":  {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataResolver(func(m data.Metadata) int { return m.CurrentPage })},
+			"pageSize":     {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataResolver(func(m data.Metadata) int { return m.PageSize })},
+			"firstPage":    {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataResolver(func(m data.Metadata) int { return m.FirstPage })},
+			"lastPage":     {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataResolver(func(m data.Metadata) int { return m.LastPage })},
+			"totalRecords": {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataResolver(func(m data.Metadata) int { return m.TotalRecords })},
+		},
+	}
+
+	movieListType := &graphql.Object{
+		Name: "MovieList",
+		Fields: graphql.Fields{
+			"movies": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(movieType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*movieList).movies, nil
+				},
+			},
+			"metadata": {
+				Type: graphql.NewNonNull(metadataType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*movieList).metadata, nil
+				},
+			},
+		},
+	}
+
+	//Every field of MovieInput is optional in the schema, so that missing fields are reported by ValidateMovie() with
+	//the same messages as the REST API
+	movieInputType := &graphql.InputObject{
+		Name: "MovieInput",
+		Fields: graphql.Args{
+			"title":   {Type: graphql.String},
+			"year":    {Type: graphql.Int},
+			"runtime": {Type: runtimeScalar},
+			"genres":  {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		},
+	}
+
+	queryType := &graphql.Object{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"movie": {
+				Type:        movieType,
+				Description: "A movie by ID, or null if there isn't one.",
+				Args: graphql.Args{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, ok := parseGraphQLID(p.Args["id"])
+					if !ok {
+						return nil, nil
+					}
+					return graphqlContextFrom(p.Context).movies.Load(id), nil
+				},
+			},
+
+			//movies takes the same filters, sort and paging as "GET /v1/movies"
+			"movies": {
+				Type: graphql.NewNonNull(movieListType),
+				Args: graphql.Args{
+					"title":    {Type: graphql.String, DefaultValue: ""},
+					"genres":   {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"sort":     {Type: graphql.String, DefaultValue: "id"},
+					"page":     {Type: graphql.Int, DefaultValue: 1},
+					"pageSize": {Type: graphql.Int, DefaultValue: 20},
+					"deleted":  {Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: app.resolveMovies,
+
+				//Each movie in the page costs as much as the fields selected for it
+				Complexity: func(args map[string]interface{}, childComplexity int) int {
+					pageSize, _ := args["pageSize"].(int)
+					return 1 + pageSize*childComplexity
+				},
+			},
+		},
+	}
+
+	mutationType := &graphql.Object{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createMovie": {
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.Args{
+					"input": {Type: graphql.NewNonNull(movieInputType)},
+				},
+				Resolve: app.resolveCreateMovie,
+			},
+
+			//updateMovie and deleteMovie take an optional version, which the movie must still be at, like If-Match
+			"updateMovie": {
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.Args{
+					"id":      {Type: graphql.NewNonNull(graphql.ID)},
+					"version": {Type: graphql.Int},
+					"input":   {Type: graphql.NewNonNull(movieInputType)},
+				},
+				Resolve: app.resolveUpdateMovie,
+			},
+			"deleteMovie": {
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.Args{
+					"id":      {Type: graphql.NewNonNull(graphql.ID)},
+					"version": {Type: graphql.Int},
+				},
+				Resolve: app.resolveDeleteMovie,
+			},
+		},
+	}
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:         queryType,
+		Mutation:      mutationType,
+		MaxDepth:      app.config.graphql.maxDepth,
+		MaxComplexity: app.config.graphql.maxComplexity,
+	})
+}
+
+//metadataResolver adapts a function of the pagination metadata into a resolver for one of the Metadata type's fields.
+func metadataResolver(fn func(m data.Metadata) int) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(data.Metadata)), nil
+	}
+}
+
+//nullString returns nil for an empty string, so optional text fields are null rather than "".
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+//resolveMovies resolves the movies query, validating its arguments as listMoviesHandler() does.
+func (app *application) resolveMovies(p graphql.ResolveParams) (interface{}, error) {
+	gc := graphqlContextFrom(p.Context)
+
+	var genres []string
+	if list, ok := p.Args["genres"].([]interface{}); ok {
+		for _, genre := range list {
+			genres = append(genres, genre.(string))
+		}
+	}
+
+	title, _ := p.Args["title"].(string)
+	sort, _ := p.Args["sort"].(string)
+	page, _ := p.Args["page"].(int)
+	pageSize, _ := p.Args["pageSize"].(int)
+	deleted, _ := p.Args["deleted"].(bool)
+
+	filters := data.Filters{
+		Page:         page,
+		PageSize:     pageSize,
+		Sort:         sort,
+		SortSafelist: movieSortSafelist,
+	}
+
+	v := validator.New()
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return nil, graphqlValidationError(gc.lang, v)
+	}
+
+	getAll := app.models.Movies.GetAll
+	if deleted {
+		//Only administrators can list the soft deleted movies
+		if !app.isAdmin(gc.r) {
+			return nil, graphqlError(gc.lang, codeForbidden, "error.forbidden")
+		}
+
+		getAll = app.models.Movies.GetAllDeleted
+	}
+
+	movies, metadata, err := getAll(title, genres, filters)
+	if err != nil {
+		return nil, app.graphqlServerError(gc, err)
+	}
+
+	return &movieList{movies: movies, metadata: metadata}, nil
+}
+
+//applyMovieInput sets the fields of a movie which are given in a MovieInput.
+func applyMovieInput(movie *data.Movie, input map[string]interface{}) {
+	if title, ok := input["title"].(string); ok {
+		movie.Title = title
+	}
+	if year, ok := input["year"].(int); ok {
+		movie.Year = int32(year)
+	}
+	if runtime, ok := input["runtime"].(data.Runtime); ok {
+		movie.Runtime = runtime
+	}
+	if list, ok := input["genres"].([]interface{}); ok {
+		movie.Genres = make([]string, len(list))
+		for i, genre := range list {
+			movie.Genres[i] = genre.(string)
+		}
+	}
+}
+
+//resolveCreateMovie resolves the createMovie mutation, as createMovieHandler() does.
+func (app *application) resolveCreateMovie(p graphql.ResolveParams) (interface{}, error) {
+	gc := graphqlContextFrom(p.Context)
+
+	movie := &data.Movie{}
+	applyMovieInput(movie, p.Args["input"].(map[string]interface{}))
+
+	v := validator.New()
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, graphqlValidationError(gc.lang, v)
+	}
+
+	err := app.models.Movies.Insert(movie, app.audit(gc.r))
+	if err != nil {
+		return nil, app.graphqlServerError(gc, err)
+	}
+
+	return movie, nil
+}
+
+//resolveUpdateMovie resolves the updateMovie mutation, as updateMovieHandler() does. Fields left out of the input are
+//kept, and a version which isn't the movie's current one is an edit conflict.
+func (app *application) resolveUpdateMovie(p graphql.ResolveParams) (interface{}, error) {
+	gc := graphqlContextFrom(p.Context)
+
+	id, ok := parseGraphQLID(p.Args["id"])
+	if !ok {
+		return nil, app.graphqlMovieError(gc, data.ErrRecordNotFound)
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		return nil, app.graphqlMovieError(gc, err)
+	}
+
+	if version, ok := p.Args["version"].(int); ok && int32(version) != movie.Version {
+		return nil, app.graphqlMovieError(gc, data.ErrEditConflict)
+	}
+
+	applyMovieInput(movie, p.Args["input"].(map[string]interface{}))
+
+	v := validator.New()
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, graphqlValidationError(gc.lang, v)
+	}
+
+	err = app.models.Movies.Update(movie, app.audit(gc.r))
+	if err != nil {
+		return nil, app.graphqlMovieError(gc, err)
+	}
+
+	return movie, nil
+}
+
+//resolveDeleteMovie resolves the deleteMovie mutation, as deleteMovieHandler() does, returning true once the movie is
+//deleted.
+func (app *application) resolveDeleteMovie(p graphql.ResolveParams) (interface{}, error) {
+	gc := graphqlContextFrom(p.Context)
+
+	id, ok := parseGraphQLID(p.Args["id"])
+	if !ok {
+		return nil, app.graphqlMovieError(gc, data.ErrRecordNotFound)
+	}
+
+	var err error
+
+	if version, ok := p.Args["version"].(int); ok {
+		err = app.models.Movies.DeleteVersion(id, int32(version), app.audit(gc.r))
+	} else {
+		err = app.models.Movies.Delete(id, app.audit(gc.r))
+	}
+	if err != nil {
+		return nil, app.graphqlMovieError(gc, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/moviepb"
+	"firstAPI.jweaver11.net/internal/rpc"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+//grpcServer returns the gRPC MovieService, which mirrors the REST movie endpoints over the same models. Metadata is sent
+//as HTTP headers, so "accept-language" and "authorization" work as they do for REST requests.
+func (app *application) grpcServer() *rpc.Server {
+	srv := rpc.NewServer()
+
+	srv.Handle(moviepb.MovieServiceCreateMovie, app.grpcCreateMovie)
+	srv.Handle(moviepb.MovieServiceGetMovie, app.grpcGetMovie)
+	srv.Handle(moviepb.MovieServiceUpdateMovie, app.grpcUpdateMovie)
+	srv.Handle(moviepb.MovieServiceDeleteMovie, app.grpcDeleteMovie)
+	srv.Handle(moviepb.MovieServiceListMovies, app.grpcListMovies)
+
+	return srv
+}
+
+//grpcTLSConfig returns the TLS settings for the gRPC server. gRPC needs HTTP/2, which net/http only speaks over TLS, so
+//when no certificate is configured a self-signed one for localhost is generated, which clients must be told to trust.
+func (app *application) grpcTLSConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+
+	if app.config.grpc.tlsCert != "" {
+		cert, err = tls.LoadX509KeyPair(app.config.grpc.tlsCert, app.config.grpc.tlsKey)
+	} else {
+		app.logger.Printf("no -grpc-tls-cert given, using a self-signed certificate for the gRPC server")
+		cert, err = selfSignedCert()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2"},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+//selfSignedCert generates a certificate for localhost which lasts a year.
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"FirstAPI development"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+//toMovieProto converts a movie into its protobuf message.
+func toMovieProto(movie *data.Movie) *moviepb.Movie {
+	return &moviepb.Movie{
+		ID:         movie.ID,
+		Title:      movie.Title,
+		Year:       movie.Year,
+		Runtime:    int32(movie.Runtime),
+		Genres:     movie.Genres,
+		Version:    movie.Version,
+		ExternalID: movie.ExternalID,
+	}
+}
+
+//grpcError maps an error from the models to a gRPC status: ErrRecordNotFound is NotFound and ErrEditConflict is
+//Aborted, so the client knows to fetch the movie again and retry. Anything else is logged and sent as Internal, without
+//the details, as serverErrorResponse() does.
+func (app *application) grpcError(call *rpc.Call, err error) error {
+	lang := app.language(call.Request)
+
+	var status *rpc.Status
+
+	switch {
+	case errors.As(err, &status):
+		return status
+	case errors.Is(err, data.ErrRecordNotFound):
+		return rpc.Errorf(rpc.NotFound, "%s", i18n.T(lang, "error.movie_not_found"))
+	case errors.Is(err, data.ErrEditConflict):
+		return rpc.Errorf(rpc.Aborted, "%s", i18n.T(lang, "error.edit_conflict"))
+	default:
+		app.logError(call.Request, err)
+		return rpc.Errorf(rpc.Internal, "%s", i18n.T(lang, "error.server"))
+	}
+}
+
+//grpcValidationError returns an InvalidArgument status for failed validation, with a BadRequest detail listing every
+//failed check, translated, as problem+json responses list them in "invalid_params".
+func (app *application) grpcValidationError(call *rpc.Call, v *validator.Validator) error {
+	lang := app.language(call.Request)
+
+	var violations []rpc.FieldViolation
+	for _, fe := range v.FieldErrors() {
+		violations = append(violations, rpc.FieldViolation{
+			Field:       violationField(fe.Pointer),
+			Description: i18n.T(lang, fe.Message),
+		})
+	}
+
+	//Sort by field so the output is stable, keeping the order checks were made in for the same field
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+
+	status := rpc.Errorf(rpc.InvalidArgument, "%s", i18n.T(lang, "error.validation_failed"))
+	status.Details = append(status.Details, rpc.BadRequest(violations))
+
+	return status
+}
+
+//violationField converts a JSON pointer to a field into the path syntax of a BadRequest field violation, so
+//"/genres/2" becomes "genres[2]".
+func violationField(pointer string) string {
+	var b strings.Builder
+
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		if _, err := strconv.Atoi(token); err == nil && b.Len() > 0 {
+			b.WriteString("[" + token + "]")
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(token)
+	}
+
+	return b.String()
+}
+
+//grpcCreateMovie handles the CreateMovie method, as createMovieHandler() does.
+func (app *application) grpcCreateMovie(call *rpc.Call) error {
+	var req moviepb.CreateMovieRequest
+	if err := call.Recv(&req); err != nil {
+		return err
+	}
+
+	movie := &data.Movie{
+		Title:   req.Title,
+		Year:    req.Year,
+		Runtime: data.Runtime(req.Runtime),
+		Genres:  req.Genres,
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return app.grpcValidationError(call, v)
+	}
+
+	err := app.models.Movies.Insert(movie, app.audit(call.Request))
+	if err != nil {
+		return app.grpcError(call, err)
+	}
+
+	return call.Send(toMovieProto(movie))
+}
+
+//grpcGetMovie handles the GetMovie method, as showMovieHandler() does.
+func (app *application) grpcGetMovie(call *rpc.Call) error {
+	var req moviepb.GetMovieRequest
+	if err := call.Recv(&req); err != nil {
+		return err
+	}
+
+	movie, err := app.models.Movies.Get(req.ID)
+	if err != nil {
+		return app.grpcError(call, err)
+	}
+
+	return call.Send(toMovieProto(movie))
+}
+
+//grpcUpdateMovie handles the UpdateMovie method, as updateMovieHandler() does. Only the fields which are set are
+//changed, and if the version is set the movie must still be at it.
+func (app *application) grpcUpdateMovie(call *rpc.Call) error {
+	var req moviepb.UpdateMovieRequest
+	if err := call.Recv(&req); err != nil {
+		return err
+	}
+
+	movie, err := app.models.Movies.Get(req.ID)
+	if err != nil {
+		return app.grpcError(call, err)
+	}
+
+	if req.Version != nil && *req.Version != movie.Version {
+		return app.grpcError(call, data.ErrEditConflict)
+	}
+
+	if req.Title != nil {
+		movie.Title = *req.Title
+	}
+	if req.Year != nil {
+		movie.Year = *req.Year
+	}
+	if req.Runtime != nil {
+		movie.Runtime = data.Runtime(*req.Runtime)
+	}
+	if req.Genres != nil {
+		movie.Genres = req.Genres.Values
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return app.grpcValidationError(call, v)
+	}
+
+	err = app.models.Movies.Update(movie, app.audit(call.Request))
+	if err != nil {
+		return app.grpcError(call, err)
+	}
+
+	return call.Send(toMovieProto(movie))
+}
+
+//grpcDeleteMovie handles the DeleteMovie method, as deleteMovieHandler() does.
+func (app *application) grpcDeleteMovie(call *rpc.Call) error {
+	var req moviepb.DeleteMovieRequest
+	if err := call.Recv(&req); err != nil {
+		return err
+	}
+
+	var err error
+
+	if req.Version != nil {
+		err = app.models.Movies.DeleteVersion(req.ID, *req.Version, app.audit(call.Request))
+	} else {
+		err = app.models.Movies.Delete(req.ID, app.audit(call.Request))
+	}
+	if err != nil {
+		return app.grpcError(call, err)
+	}
+
+	return call.Send(&moviepb.DeleteMovieResponse{})
+}
+
+//grpcListMovies handles the ListMovies method. Like exportMoviesHandler(), it streams every matching movie from a
+//cursor rather than sending a page, so the server's memory use doesn't grow with the number of movies.
+func (app *application) grpcListMovies(call *rpc.Call) error {
+	var req moviepb.ListMoviesRequest
+	if err := call.Recv(&req); err != nil {
+		return err
+	}
+
+	filters := data.Filters{
+		Sort:         req.Sort,
+		SortSafelist: movieSortSafelist,
+	}
+	if filters.Sort == "" {
+		filters.Sort = "id"
+	}
+
+	v := validator.New()
+
+	if v.Check(validator.In(filters.Sort, filters.SortSafelist...), "sort", "validation.sort.invalid"); !v.Valid() {
+		return app.grpcValidationError(call, v)
+	}
+
+	//Only administrators can list the soft deleted movies
+	if req.Deleted && !app.isAdmin(call.Request) {
+		return rpc.Errorf(rpc.PermissionDenied, "%s", i18n.T(app.language(call.Request), "error.forbidden"))
+	}
+
+	genres := req.Genres
+	if genres == nil {
+		genres = []string{}
+	}
+
+	err := app.models.Movies.Export(call.Request.Context(), req.Title, genres, filters, req.Deleted, func(movie *data.Movie) error {
+		return call.Send(toMovieProto(movie))
+	})
+	if err != nil {
+		return app.grpcError(call, err)
+	}
+
+	return nil
+}
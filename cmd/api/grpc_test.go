@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/moviepb"
+	"firstAPI.jweaver11.net/internal/rpc"
+)
+
+//pipeListener is a net.Listener whose connections are in-memory pipes, made by its Dial method.
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+func (l *pipeListener) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, server := net.Pipe()
+
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+//grpcTestMovies keeps movies in memory, so calls can be checked end to end.
+type grpcTestMovies struct {
+	data.MockMovieModel
+
+	mu     sync.Mutex
+	movies map[int64]data.Movie
+	nextID int64
+}
+
+func (m *grpcTestMovies) Insert(movie *data.Movie, audit data.Audit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	movie.ID = m.nextID
+	movie.Version = 1
+	m.movies[movie.ID] = *movie
+
+	return nil
+}
+
+func (m *grpcTestMovies) Get(id int64, fields ...string) (*data.Movie, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie, ok := m.movies[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return &movie, nil
+}
+
+func (m *grpcTestMovies) Update(movie *data.Movie, audit data.Audit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.movies[movie.ID]; !ok || stored.Version != movie.Version {
+		return data.ErrEditConflict
+	}
+
+	movie.Version++
+	m.movies[movie.ID] = *movie
+
+	return nil
+}
+
+func (m *grpcTestMovies) Delete(id int64, audit data.Audit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.movies[id]; !ok {
+		return data.ErrRecordNotFound
+	}
+	delete(m.movies, id)
+
+	return nil
+}
+
+func (m *grpcTestMovies) Export(ctx context.Context, title string, genres []string, filters data.Filters, deleted bool, fn func(movie *data.Movie) error) error {
+	m.mu.Lock()
+	var movies []data.Movie
+	for _, movie := range m.movies {
+		if strings.Contains(movie.Title, title) {
+			movies = append(movies, movie)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(movies, func(i, j int) bool { return movies[i].ID < movies[j].ID })
+
+	for i := range movies {
+		if err := fn(&movies[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//newGRPCTestClient serves the gRPC MovieService, with its TLS settings, over an in-memory listener, and returns a
+//client for it which speaks HTTP/2.
+func newGRPCTestClient(t *testing.T) (*http.Client, *grpcTestMovies) {
+	t.Helper()
+
+	movies := &grpcTestMovies{movies: make(map[int64]data.Movie)}
+
+	models := data.NewMockModels()
+	models.Movies = movies
+
+	app := &application{logger: log.New(io.Discard, "", 0), models: models}
+
+	tlsConfig, err := app.grpcTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln := newPipeListener()
+
+	srv := &http.Server{Handler: app.requestID(app.grpcServer()), TLSConfig: tlsConfig}
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       ln.Dial,
+			ForceAttemptHTTP2: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	t.Cleanup(client.CloseIdleConnections)
+
+	return client, movies
+}
+
+//grpcInvoke calls a method, returning the response messages and the call's status.
+func grpcInvoke(t *testing.T, client *http.Client, method string, req rpc.Message) ([][]byte, *rpc.Status) {
+	t.Helper()
+
+	body := req.MarshalProto()
+	frame := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+
+	r, err := http.NewRequest(http.MethodPost, "https://movies.test"+method, bytes.NewReader(append(frame, body...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+
+	res, err := client.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.ProtoMajor != 2 {
+		t.Fatalf("got %s, want HTTP/2", res.Proto)
+	}
+
+	var messages [][]byte
+
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(res.Body, header[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(res.Body, msg); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, msg)
+	}
+
+	code, err := strconv.ParseUint(res.Trailer.Get("Grpc-Status"), 10, 32)
+	if err != nil {
+		t.Fatalf("bad grpc-status trailer: %v", err)
+	}
+
+	status := &rpc.Status{Code: rpc.Code(code)}
+
+	if details := res.Trailer.Get("Grpc-Status-Details-Bin"); details != "" {
+		b, err := base64.RawStdEncoding.DecodeString(details)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := status.UnmarshalProto(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return messages, status
+}
+
+//grpcMovie calls a method which returns a movie, and decodes it.
+func grpcMovie(t *testing.T, client *http.Client, method string, req rpc.Message) moviepb.Movie {
+	t.Helper()
+
+	messages, status := grpcInvoke(t, client, method, req)
+	if status.Code != rpc.OK || len(messages) != 1 {
+		t.Fatalf("got %d messages with status %v, want one movie", len(messages), status.Code)
+	}
+
+	var movie moviepb.Movie
+	if err := movie.UnmarshalProto(messages[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	return movie
+}
+
+//badRequestFields returns the fields in the BadRequest detail of a status, using DecodeFields.
+func badRequestFields(t *testing.T, status *rpc.Status) []string {
+	t.Helper()
+
+	if len(status.Details) != 1 || status.Details[0].TypeURL != "type.googleapis.com/google.rpc.BadRequest" {
+		t.Fatalf("got details %+v, want one BadRequest", status.Details)
+	}
+
+	var fields []string
+
+	err := rpc.DecodeFields(status.Details[0].Value, func(f rpc.Field) error {
+		return rpc.DecodeFields(f.Bytes(), func(f rpc.Field) error {
+			if f.Num == 1 {
+				fields = append(fields, f.String())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fields
+}
+
+func TestGRPCMovieCRUD(t *testing.T) {
+	client, _ := newGRPCTestClient(t)
+
+	created := grpcMovie(t, client, moviepb.MovieServiceCreateMovie, &moviepb.CreateMovieRequest{
+		Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama", "romance"},
+	})
+	if created.ID != 1 || created.Version != 1 || created.Title != "Casablanca" {
+		t.Fatalf("created %+v", created)
+	}
+
+	got := grpcMovie(t, client, moviepb.MovieServiceGetMovie, &moviepb.GetMovieRequest{ID: 1})
+	if got.Title != "Casablanca" || got.Runtime != 102 || len(got.Genres) != 2 {
+		t.Errorf("got %+v", got)
+	}
+
+	version, title := int32(1), "Casablanca (1942)"
+	updated := grpcMovie(t, client, moviepb.MovieServiceUpdateMovie, &moviepb.UpdateMovieRequest{
+		ID: 1, Version: &version, Title: &title,
+	})
+	if updated.Title != title || updated.Version != 2 || updated.Year != 1942 {
+		t.Errorf("updated %+v", updated)
+	}
+
+	_, status := grpcInvoke(t, client, moviepb.MovieServiceUpdateMovie, &moviepb.UpdateMovieRequest{ID: 1, Version: &version, Title: &title})
+	if status.Code != rpc.Aborted {
+		t.Errorf("update at an old version got %v, want Aborted", status.Code)
+	}
+
+	messages, status := grpcInvoke(t, client, moviepb.MovieServiceDeleteMovie, &moviepb.DeleteMovieRequest{ID: 1})
+	if status.Code != rpc.OK || len(messages) != 1 {
+		t.Errorf("delete got %d messages with status %v", len(messages), status.Code)
+	}
+
+	calls := map[string]rpc.Message{
+		moviepb.MovieServiceGetMovie:    &moviepb.GetMovieRequest{ID: 1},
+		moviepb.MovieServiceDeleteMovie: &moviepb.DeleteMovieRequest{ID: 1},
+	}
+
+	for method, req := range calls {
+		_, status = grpcInvoke(t, client, method, req)
+		if status.Code != rpc.NotFound {
+			t.Errorf("%s of a deleted movie got %v, want NotFound", method, status.Code)
+		}
+	}
+}
+
+func TestGRPCValidation(t *testing.T) {
+	client, _ := newGRPCTestClient(t)
+
+	_, status := grpcInvoke(t, client, moviepb.MovieServiceCreateMovie, &moviepb.CreateMovieRequest{
+		Year: 1942, Runtime: 102, Genres: []string{"drama", "drama"},
+	})
+	if status.Code != rpc.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", status.Code)
+	}
+
+	fields := badRequestFields(t, status)
+	if strings.Join(fields, ",") != "genres,title" {
+		t.Errorf("got violations for %q, want genres and title", fields)
+	}
+
+	_, status = grpcInvoke(t, client, moviepb.MovieServiceListMovies, &moviepb.ListMoviesRequest{Sort: "budget"})
+	if status.Code != rpc.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", status.Code)
+	}
+
+	if fields := badRequestFields(t, status); strings.Join(fields, ",") != "sort" {
+		t.Errorf("got violations for %q, want sort", fields)
+	}
+}
+
+func TestGRPCListMovies(t *testing.T) {
+	client, movies := newGRPCTestClient(t)
+
+	for _, title := range []string{"Alien", "Aliens", "Heat"} {
+		movies.Insert(&data.Movie{Title: title, Year: 1986, Runtime: 120, Genres: []string{"action"}}, data.Audit{})
+	}
+
+	messages, status := grpcInvoke(t, client, moviepb.MovieServiceListMovies, &moviepb.ListMoviesRequest{Title: "Alien"})
+	if status.Code != rpc.OK {
+		t.Fatalf("got %v, want OK", status.Code)
+	}
+
+	var titles []string
+	for _, msg := range messages {
+		var movie moviepb.Movie
+		if err := movie.UnmarshalProto(msg); err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, movie.Title)
+	}
+
+	if strings.Join(titles, ",") != "Alien,Aliens" {
+		t.Errorf("got %q, want Alien and Aliens", titles)
+	}
+
+	_, status = grpcInvoke(t, client, moviepb.MovieServiceListMovies, &moviepb.ListMoviesRequest{Deleted: true})
+	if status.Code != rpc.PermissionDenied {
+		t.Errorf("listing deleted movies got %v, want PermissionDenied", status.Code)
+	}
+
+	_, status = grpcInvoke(t, client, "/movies.v1.MovieService/WatchMovies", &moviepb.ListMoviesRequest{})
+	if status.Code != rpc.Unimplemented {
+		t.Errorf("unknown method got %v, want Unimplemented", status.Code)
+	}
+}
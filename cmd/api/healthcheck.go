@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
 )
 
 //Declare a handler which writes a plain-text response with information about the application status, operating environment, and version
@@ -9,14 +14,120 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 
 	env := envelope{
 		"status": "available",
-		"system_info": map[string]string{
+		"system_info": map[string]interface{}{
 			"environment": app.config.env,
-			"version":     version,
+			"version":     buildInfo.Version,
+			"revision":    buildInfo.Revision,
+			"commit_time": buildInfo.Time,
+			"modified":    buildInfo.Modified,
 		},
 	}
 
-	err := app.writeJSON(w, http.StatusOK, env, nil)
+	err := app.writeJSON(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//healthCheck holds the outcome of a single readiness check.
+type healthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+//Declare a liveness handler which only confirms that the process is up and able to serve requests.
+//It deliberately doesn't touch the database, so a database outage never causes the process to be restarted.
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"status": "alive"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//Declare a readiness handler which checks the application can actually serve traffic. It pings the database,
+//checks the connection pool isn't saturated and confirms the schema is at the expected migration version.
+//If any check fails, or the application is draining, it returns 503 Service Unavailable with the per-check details.
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]healthCheck{
+		"draining": app.checkDraining(),
+	}
+
+	if app.db == nil {
+		checks["database"] = healthCheck{Status: "fail", Detail: "no database connection pool configured"}
+	} else {
+		checks["database"] = app.checkDatabase(r.Context())
+		checks["pool"] = app.checkPool()
+		checks["migrations"] = app.checkMigrations(r.Context())
+	}
+
+	status := http.StatusOK
+	env := envelope{"status": "ready", "checks": checks}
+
+	for _, check := range checks {
+		if check.Status != "pass" {
+			status = http.StatusServiceUnavailable
+			env["status"] = "unavailable"
+			break
+		}
+	}
+
+	err := app.writeJSON(w, r, status, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+//checkDraining fails once graceful shutdown has started, so load balancers stop sending new requests.
+func (app *application) checkDraining() healthCheck {
+	if app.isDraining() {
+		return healthCheck{Status: "fail", Detail: "server is shutting down"}
+	}
+
+	return healthCheck{Status: "pass"}
+}
+
+//checkDatabase pings the database, giving up after 2 seconds.
+func (app *application) checkDatabase(ctx context.Context) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	err := app.db.PingContext(ctx)
+	if err != nil {
+		return healthCheck{Status: "fail", Detail: err.Error()}
+	}
+
+	return healthCheck{Status: "pass"}
+}
+
+//checkPool fails when every connection allowed in the pool is in use, meaning new queries would have to wait.
+func (app *application) checkPool() healthCheck {
+	stats := app.db.Stats()
+
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		detail := fmt.Sprintf("%d of %d connections in use", stats.InUse, stats.MaxOpenConnections)
+		return healthCheck{Status: "fail", Detail: detail}
+	}
+
+	return healthCheck{Status: "pass"}
+}
+
+//checkMigrations fails if the schema version doesn't match the version this build expects, or if the last migration failed part way.
+func (app *application) checkMigrations(ctx context.Context) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	version, dirty, err := data.SchemaVersion(ctx, app.db)
+	if err != nil {
+		return healthCheck{Status: "fail", Detail: err.Error()}
+	}
+
+	switch {
+	case dirty:
+		return healthCheck{Status: "fail", Detail: fmt.Sprintf("migration %d is dirty", version)}
+	case version != app.config.db.migrationVersion:
+		detail := fmt.Sprintf("schema is at version %d, expected %d", version, app.config.db.migrationVersion)
+		return healthCheck{Status: "fail", Detail: detail}
+	}
+
+	return healthCheck{Status: "pass"}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLiveness(t *testing.T) {
+	app := &application{}
+
+	//Liveness passes even with no database and while draining
+	app.setDraining()
+
+	w := httptest.NewRecorder()
+	app.livenessHandler(w, httptest.NewRequest(http.MethodGet, pathLiveness, nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"alive"}`+"\n" {
+		t.Errorf("got status %d and body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name         string
+		draining     bool
+		wantDraining healthCheck
+	}{
+		{"serving", false, healthCheck{Status: "pass"}},
+		{"draining", true, healthCheck{Status: "fail", Detail: "server is shutting down"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{}
+			if tt.draining {
+				app.setDraining()
+			}
+
+			w := httptest.NewRecorder()
+			app.readinessHandler(w, httptest.NewRequest(http.MethodGet, pathReadiness, nil))
+
+			var body struct {
+				Status string                 `json:"status"`
+				Checks map[string]healthCheck `json:"checks"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			//With no database pool configured the application is never ready
+			if w.Code != http.StatusServiceUnavailable || body.Status != "unavailable" {
+				t.Errorf("got status %d and %q, want 503 and \"unavailable\"", w.Code, body.Status)
+			}
+			if body.Checks["database"].Status != "fail" {
+				t.Errorf("got database check %+v, want a failure", body.Checks["database"])
+			}
+			if body.Checks["draining"] != tt.wantDraining {
+				t.Errorf("got draining check %+v, want %+v", body.Checks["draining"], tt.wantDraining)
+			}
+		})
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
+	"firstAPI.jweaver11.net/internal/accept"
+	"firstAPI.jweaver11.net/internal/codec"
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
 	"firstAPI.jweaver11.net/internal/validator"
 
 	"github.com/julienschmidt/httprouter"
@@ -30,12 +35,19 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
-//Define a 'writeJSON' helper for sending responses. Takes the destination http.ResponseWriter, the HTTP status code to send, the data to encode in JSON,
-//and a header map containing any additional HTTP headers we want to include in the response.
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	//Use the json.MarshalIndent() function so that the whitespace is added to the encoded Json
-	//Here we use no line prefix ("") and tab indents ("\t") for each element
-	js, err := json.MarshalIndent(data, "", "\t")
+//Define a 'writeJSON' helper for sending responses. Takes the destination http.ResponseWriter, the request, the HTTP status code to send,
+//the data to encode in JSON, and a header map containing any additional HTTP headers we want to include in the response.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	//If the client wants pretty output, use the json.MarshalIndent() function so that the whitespace is added to the encoded Json.
+	//Here we use no line prefix ("") and tab indents ("\t") for each element. Otherwise send it compact, which is smaller
+	var js []byte
+	var err error
+
+	if app.pretty(r) {
+		js, err = json.MarshalIndent(data, "", "\t")
+	} else {
+		js, err = json.Marshal(data)
+	}
 	if err != nil {
 		return err
 	}
@@ -50,14 +62,117 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 		w.Header()[key] = value
 	}
 
-	//Add the "Content-Type: application/json" header, then write the status code and JSON response
-	w.Header().Set("Content-Type", "application/json")
+	//Add the "Content-Type: application/json" header unless the caller has already chosen a more specific JSON media type
+	//(such as application/problem+json), then write the status code and JSON response
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 	w.Write(js)
 
 	return nil
 }
 
+//pretty reports whether to indent the response body. Clients choose with the "pretty" query string parameter, and
+//without it responses are only indented in the development environment, where people are more likely to read them.
+func (app *application) pretty(r *http.Request) bool {
+	pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	if err != nil {
+		return app.config.env == "development"
+	}
+	return pretty
+}
+
+//writeResponse sends a successful response in the format the client asks for in the Accept header, picked from the
+//codecs in app.codecs, and sends a 406 Not Acceptable response if it doesn't accept any of them. Representations in
+//other formats than the default, or which are indented, get their own ETag, with the codec's name or "pretty" added
+//to any ETag in the headers. For GET
+//requests it also sends a 304 Not Modified response, with no body, when the client's cached copy is still current.
+func (app *application) writeResponse(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	c, ok := app.codecs.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		app.notAcceptableResponse(w, r)
+		return nil
+	}
+
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Add("Vary", "Accept")
+
+	pretty := app.pretty(r)
+
+	etag := headers.Get("ETag")
+	if etag != "" && c != app.codecs.Default() {
+		etag = strings.TrimSuffix(etag, `"`) + "-" + c.Name() + `"`
+	}
+	if etag != "" && pretty {
+		etag = strings.TrimSuffix(etag, `"`) + "-pretty" + `"`
+	}
+	if etag != "" {
+		headers.Set("ETag", etag)
+	}
+
+	if etag != "" && status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) && notModified(r, etag) {
+		w.Header()["Vary"] = headers["Vary"]
+		app.notModifiedResponse(w, etag)
+		return nil
+	}
+
+	//Encode into a buffer first, so that a value the format can't represent still gets a proper error response
+	var buf bytes.Buffer
+
+	err := c.Encode(&buf, data, pretty)
+	if err != nil {
+		if errors.Is(err, codec.ErrUnsupported) {
+			app.notAcceptableResponse(w, r)
+			return nil
+		}
+		return err
+	}
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+	w.Header().Set("Content-Type", c.ContentType())
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+
+	return nil
+}
+
+//'readBody()' helper reads the raw request body, limited to 1MB like readJSON(), for bodies which are processed
+//as a whole rather than decoded into a struct.
+func (app *application) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	maxBytes := 1_048_576
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return nil, i18n.Error("json.too_large", maxBytesError.Limit)
+		}
+		return nil, err
+	}
+
+	if len(body) == 0 {
+		return nil, i18n.Error("json.empty")
+	}
+
+	return body, nil
+}
+
+//'requestMediaType()' helper returns the media type from the request's Content-Type header, lower-cased and without
+//parameters such as charset. It returns an empty string if there's no Content-Type or it can't be parsed.
+func requestMediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 	//Use http.MaxBytesReader() to limit the size of the request body to 1MB.
 	maxBytes := 1_048_576
@@ -68,36 +183,39 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 
 	err := dec.Decode(dst)
 
-	//Decode the request body into the target destination
+	//Decode the request body into the target destination. The errors we return are i18n messages,
+	//so badRequestResponse() can send them in the client's language
 	if err != nil {
 		var syntaxError *json.SyntaxError
 		var unmarshalTypeError *json.UnmarshalTypeError
 		var invalidUnmarshalError *json.InvalidUnmarshalError
+		var maxBytesError *http.MaxBytesError
 
 		switch {
 		//use the errors.As() function to check if error has the type *json.SyntaxError.
 		//If it does, return plain-english error message in  location of the problem
 		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+			return i18n.Error("json.badly_formed_at", syntaxError.Offset)
 
 		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
+			return i18n.Error("json.badly_formed")
 
 		case errors.As(err, &unmarshalTypeError):
 			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+				return i18n.Error("json.incorrect_type_field", unmarshalTypeError.Field)
 			}
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+			return i18n.Error("json.incorrect_type_at", unmarshalTypeError.Offset)
 
 		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+			return i18n.Error("json.empty")
 
 		case strings.HasPrefix(err.Error(), "json: unknown field "):
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+			return i18n.Error("json.unknown_key", fieldName)
 
-		case err.Error() == "http:request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+		//MaxBytesReader() returns a *http.MaxBytesError once the body goes over the limit
+		case errors.As(err, &maxBytesError):
+			return i18n.Error("json.too_large", maxBytesError.Limit)
 
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
@@ -110,7 +228,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 
 	err = dec.Decode(&struct{}{})
 	if err != io.EOF {
-		return errors.New("body muyst only contain a sing JSON value")
+		return i18n.Error("json.multiple_values")
 	}
 
 	return nil
@@ -130,6 +248,19 @@ func (app *application) readString(qs url.Values, key string, defaultValue strin
 	return s
 }
 
+//readFields reads a sparse fieldset from the "fields" query string parameter, like "fields=id,title", checking each
+//field is one clients can ask for. It returns the fields in their usual order, or nil if the parameter isn't set.
+func (app *application) readFields(qs url.Values, v *validator.Validator) []string {
+	fields := app.readCSV(qs, "fields", nil)
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	data.ValidateFields(v, fields)
+
+	return data.SelectFields(fields)
+}
+
 //'readCSV() helper reads a string value from query string and splits it into a  slice on the comma
 //If no matching eky could be found,, it returns the provided default value
 func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
@@ -156,9 +287,73 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	//try to conver the value to int, returns error if fails
 	i, err := strconv.Atoi(s)
 	if err != nil {
-		v.AddError(key, "must be an integer value")
+		v.AddError(key, "validation.integer")
 		return defaultValue
 	}
 
 	return i
 }
+
+//'acceptsMediaType()' helper reports whether the request's Accept header prefers the given media type over the fallback.
+//The media type must be listed explicitly with a non-zero q-value, and a q-value at least as high as the fallback's.
+func acceptsMediaType(r *http.Request, mediaType, fallback string) bool {
+	wantQ, fallbackQ := -1.0, -1.0
+
+	for _, pref := range accept.Parse(r.Header.Get("Accept")) {
+		switch {
+		case strings.EqualFold(pref.Value, mediaType):
+			wantQ = pref.Q
+		case strings.EqualFold(pref.Value, fallback):
+			fallbackQ = pref.Q
+		}
+	}
+
+	return wantQ > 0 && wantQ >= fallbackQ
+}
+
+//'readBool()' helper reads a string value from the query string and converts it to a bool, recording an error
+//message if the value can't be converted
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "validation.boolean")
+		return defaultValue
+	}
+
+	return b
+}
+
+//'readRuntimeFormat()' helper returns the format the client wants runtimes written in, from the "runtime_format" query
+//string parameter or, failing that, the X-Runtime-Format header. It defaults to "mins" and records an error if the
+//value isn't one we support.
+func (app *application) readRuntimeFormat(r *http.Request, v *validator.Validator) data.RuntimeFormat {
+	format := app.readString(r.URL.Query(), "runtime_format", r.Header.Get("X-Runtime-Format"))
+	if format == "" {
+		return data.RuntimeFormatMins
+	}
+
+	if !validator.In(format, data.RuntimeFormats...) {
+		v.AddError("runtime_format", i18n.Key("validation.oneof", strings.Join(data.RuntimeFormats, ", ")))
+		return data.RuntimeFormatMins
+	}
+
+	return data.RuntimeFormat(format)
+}
+
+//'audit()' helper returns who is making the request and its request ID, for recording in the movie history. The actor
+//is the identity the request was authenticated as: "admin" for requests with the admin token, and "anonymous" for
+//everyone else. Nothing the client says about itself is recorded, so the history can't be filled with made-up names.
+func (app *application) audit(r *http.Request) data.Audit {
+	actor := "anonymous"
+	if app.isAdmin(r) {
+		actor = "admin"
+	}
+
+	return data.Audit{Actor: actor, RequestID: app.contextGetRequestID(r)}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"valid", `{"title":"Casablanca"}`, ""},
+		{"badly formed", `{"title":}`, "body contains badly-formed JSON (at character 10)"},
+		{"truncated", `{"title":"Casablanca"`, "body contains badly-formed JSON"},
+		{"wrong type", `{"title":1}`, `body contains incorrect JSON type for field "title"`},
+		{"empty", ``, "body must not be empty"},
+		{"unknown key", `{"director":"Curtiz"}`, `body contains unknown key "director"`},
+		{"multiple values", `{"title":"a"}{"title":"b"}`, "body must only contain a single JSON value"},
+		{"too large", `{"title":"` + strings.Repeat("a", 1_048_576) + `"}`, "body must not be larger than 1048576 bytes"},
+	}
+
+	app := &application{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", strings.NewReader(tt.body))
+
+			var input struct {
+				Title string `json:"title"`
+			}
+
+			err := app.readJSON(httptest.NewRecorder(), r, &input)
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+
+			if got != tt.want {
+				t.Errorf("got error %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"valid", `[{"op":"test"}]`, ""},
+		{"empty", ``, "body must not be empty"},
+		{"too large", strings.Repeat("a", 1_048_577), "body must not be larger than 1048576 bytes"},
+	}
+
+	app := &application{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/v1/movies/1", strings.NewReader(tt.body))
+
+			body, err := app.readBody(httptest.NewRecorder(), r)
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			} else if string(body) != tt.body {
+				t.Errorf("got body %q, want %q", body, tt.body)
+			}
+
+			if got != tt.want {
+				t.Errorf("got error %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		name          string
+		adminToken    string
+		authorization string
+		want          string
+	}{
+		{"admin", "admin-token", "Bearer admin-token", "admin"},
+		{"wrong token", "admin-token", "Bearer guess", "anonymous"},
+		{"no token", "admin-token", "", "anonymous"},
+		{"no admin token configured", "", "Bearer ", "anonymous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{}
+			app.config.adminToken = tt.adminToken
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", nil)
+			r.Header.Set("X-Actor", "someone-else")
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			if got := app.audit(r).Actor; got != tt.want {
+				t.Errorf("got actor %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFields(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+		valid bool
+	}{
+		{"", nil, true},
+		{"fields=title,id", []string{"id", "title"}, true},
+		{"fields=genres,+year,genres", []string{"year", "genres"}, true},
+		{"fields=title,director", nil, false},
+	}
+
+	app := &application{}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			qs, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := validator.New()
+			got := app.readFields(qs, v)
+
+			if v.Valid() != tt.valid {
+				t.Fatalf("got valid %t, want %t", v.Valid(), tt.valid)
+			}
+			if tt.valid && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got fields %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
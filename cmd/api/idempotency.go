@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
+)
+
+//maxIdempotencyKeyLength is the longest Idempotency-Key header we accept.
+const maxIdempotencyKeyLength = 255
+
+//idempotencyReplayHeaders are the response headers stored with an idempotent response and sent again when it's replayed.
+//Headers which describe the request being handled, like X-Request-ID, are left out.
+var idempotencyReplayHeaders = []string{"Content-Type", "Content-Language", "Vary", "Location", "ETag"}
+
+//idempotent makes a handler safe to retry with an Idempotency-Key header. The first request with a key is handled as
+//normal and its response is stored. A retry with the same key and body gets the stored response again, with an
+//Idempotent-Replayed header, rather than being handled a second time. Requests without the header are unaffected.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			app.badRequestResponse(w, r, i18n.Error("error.idempotency_key_too_long", maxIdempotencyKeyLength))
+			return
+		}
+
+		//Read the body so we can hash it, then put it back for the handler to read
+		body, err := app.readBody(w, r)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, err := app.models.IdempotencyKeys.Reserve(key, requestHash(r, body), app.config.idempotency.lease, app.config.idempotency.ttl)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrIdempotencyKeyReused):
+				app.idempotencyKeyReusedResponse(w, r)
+			case errors.Is(err, data.ErrIdempotencyKeyInFlight):
+				app.idempotencyKeyInFlightResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if record != nil {
+			replayResponse(w, record)
+			return
+		}
+
+		//Release the key if the handler panics, so a retry isn't stuck waiting for a request which will never finish
+		rec := &responseRecorder{ResponseWriter: w}
+		completed := false
+
+		defer func() {
+			if !completed {
+				if err := app.models.IdempotencyKeys.Release(key); err != nil {
+					app.logger.Println(err)
+				}
+			}
+		}()
+
+		next(rec, r)
+
+		//Server errors aren't stored, as a retry might succeed. Everything else, including validation errors, is
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		header := make(map[string][]string)
+		for _, name := range idempotencyReplayHeaders {
+			if values := w.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+
+		err = app.models.IdempotencyKeys.Complete(key, rec.statusCode(), header, rec.body.Bytes())
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		completed = true
+	}
+}
+
+//requestHash identifies a request by its method, path, query string and body, so a retry can be told apart from a
+//different request sent with the same key. The query string is encoded with its keys sorted, so the order the client
+//sent them in doesn't matter.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.Query().Encode())
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//replayResponse sends a stored response again.
+func replayResponse(w http.ResponseWriter, record *data.IdempotencyRecord) {
+	for name, values := range record.Header {
+		w.Header()[http.CanonicalHeaderKey(name)] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+//responseRecorder passes a response through to the client while keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+//statusCode returns the status which was sent, which is 200 if the handler never set one.
+func (rec *responseRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+func TestRequestHash(t *testing.T) {
+	hash := func(target, body string) string {
+		return requestHash(httptest.NewRequest(http.MethodPost, target, nil), []byte(body))
+	}
+
+	base := hash("/v1/movies:batch?atomic=true&pretty=false", "[]")
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		same   bool
+	}{
+		{"same request", "/v1/movies:batch?atomic=true&pretty=false", "[]", true},
+		{"query in another order", "/v1/movies:batch?pretty=false&atomic=true", "[]", true},
+		{"different query", "/v1/movies:batch?atomic=false&pretty=false", "[]", false},
+		{"no query", "/v1/movies:batch", "[]", false},
+		{"different body", "/v1/movies:batch?atomic=true&pretty=false", "[{}]", false},
+		{"different path", "/v1/movies?atomic=true&pretty=false", "[]", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hash(tt.target, tt.body) == base; got != tt.same {
+				t.Errorf("same hash = %t, want %t", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestIdempotentRejectsReusedKey(t *testing.T) {
+	models := data.NewMockModels()
+	models.IdempotencyKeys = data.NewMemoryIdempotencyStore()
+
+	app := &application{logger: log.New(&strings.Builder{}, "", 0), models: models}
+	app.config.idempotency.lease = time.Minute
+	app.config.idempotency.ttl = time.Hour
+
+	handled := 0
+	handler := app.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		handled++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.URL.RawQuery))
+	})
+
+	send := func(target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`[{"op":"create"}]`))
+		r.Header.Set("Idempotency-Key", "key-1")
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		return w
+	}
+
+	first := send("/v1/movies:batch?atomic=true")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request got %d, want %d", first.Code, http.StatusCreated)
+	}
+
+	replay := send("/v1/movies:batch?atomic=true")
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry got %d with Idempotent-Replayed %q, want a replay", replay.Code, replay.Header().Get("Idempotent-Replayed"))
+	}
+
+	reused := send("/v1/movies:batch")
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("request with another query string got %d, want %d", reused.Code, http.StatusUnprocessableEntity)
+	}
+
+	if handled != 1 {
+		t.Errorf("handler ran %d times, want 1", handled)
+	}
+}
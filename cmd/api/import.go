@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/movieio"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+//maxImportBytes is the largest file the import endpoint accepts. Bigger files can be loaded with the import command.
+const maxImportBytes = 32 << 20
+
+//errImportInvalidRows is returned by importMovies() when some rows fail validation, so nothing was imported.
+var errImportInvalidRows = errors.New("import contains invalid rows")
+
+//importReport describes the outcome of an import. Duplicates lists the row numbers of duplicate movies, which were either
+//skipped or stopped the import, and Errors lists the rows which failed validation.
+type importReport struct {
+	DryRun     bool             `json:"dry_run"`
+	Rows       int              `json:"rows"`
+	Inserted   int64            `json:"inserted"`
+	Duplicates []int            `json:"duplicates"`
+	Errors     []importRowError `json:"errors"`
+	Error      string           `json:"error,omitempty"`
+}
+
+//importRowError lists the translated validation errors for one row.
+type importRowError struct {
+	Row    int               `json:"row"`
+	Errors map[string]string `json:"errors"`
+}
+
+//importMovies reads, validates and loads a file of movies, and reports what happened in the given language. If any row
+//is invalid nothing is loaded, and errImportInvalidRows is returned along with the report. Duplicates that aren't being
+//skipped return data.ErrDuplicateMovies in the same way. Problems with the file as a whole are returned without a report.
+func (app *application) importMovies(r io.Reader, format movieio.Format, opts data.ImportOptions, audit data.Audit, lang string) (*importReport, error) {
+	rows, rowErrors, err := movieio.ReadAll(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &importReport{
+		DryRun:     opts.DryRun,
+		Rows:       len(rows) + len(rowErrors),
+		Duplicates: []int{},
+		Errors:     []importRowError{},
+	}
+
+	for _, rowError := range rowErrors {
+		translated := make(map[string]string, len(rowError.Errors))
+		for field, key := range rowError.Errors {
+			translated[field] = i18n.T(lang, key)
+		}
+
+		report.Errors = append(report.Errors, importRowError{Row: rowError.Row, Errors: translated})
+	}
+
+	if len(report.Errors) > 0 {
+		report.Error = i18n.T(lang, "error.import_invalid_rows")
+		return report, errImportInvalidRows
+	}
+
+	result, err := app.models.Movies.Import(rows, opts, audit)
+	if result != nil {
+		report.Inserted = result.Inserted
+		report.Duplicates = result.Duplicates
+	}
+	if errors.Is(err, data.ErrDuplicateMovies) {
+		report.Error = i18n.T(lang, "error.import_duplicates")
+	}
+
+	return report, err
+}
+
+//importMoviesHandler loads a CSV or NDJSON file of movies for the "POST /v1/movies/import" endpoint. The format comes from
+//the Content-Type, or the format query string parameter. With ?dry_run=true the file is checked without saving anything,
+//and ?on_duplicate=skip imports everything but the duplicates rather than nothing at all.
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	dryRun := app.readBool(qs, "dry_run", false, v)
+
+	onDuplicate := app.readString(qs, "on_duplicate", "abort")
+	v.Check(validator.In(onDuplicate, "skip", "abort"), "on_duplicate", i18n.Key("validation.oneof", "skip, abort"))
+
+	format, ok := movieio.FormatForMediaType(requestMediaType(r))
+	if qs.Has("format") {
+		format = movieio.Format(qs.Get("format"))
+		ok = validator.In(string(format), movieio.Formats...)
+		v.Check(ok, "format", i18n.Key("validation.oneof", "csv, ndjson"))
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if !ok {
+		app.unsupportedMediaTypeResponse(w, r, movieio.MediaTypes)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	opts := data.ImportOptions{SkipDuplicates: onDuplicate == "skip", DryRun: dryRun}
+
+	report, err := app.importMovies(r.Body, format, opts, app.audit(r), app.language(r))
+	if err != nil {
+		var msg *i18n.Message
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.Is(err, errImportInvalidRows):
+			app.writeImportReport(w, r, http.StatusUnprocessableEntity, report)
+		case errors.Is(err, data.ErrDuplicateMovies):
+			app.writeImportReport(w, r, http.StatusConflict, report)
+		case errors.As(err, &msg):
+			app.badRequestResponse(w, r, err)
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, i18n.Error("json.too_large", maxBytesError.Limit))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeImportReport(w, r, http.StatusOK, report)
+}
+
+//writeImportReport sends an import report.
+func (app *application) writeImportReport(w http.ResponseWriter, r *http.Request, status int, report *importReport) {
+	err := app.writeResponse(w, r, status, envelope{"import": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//importCommand runs "api import [flags] FILE", loading a CSV or NDJSON file straight into the database. It prints the
+//same report as the import endpoint, and returns the exit status. A FILE of "-" reads from standard input.
+func importCommand(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+
+	dsn := fs.String("db-dsn", os.Getenv("FIRSTAPIDB_DB_DSN"), "PostgreSQL DSN")
+	format := fs.String("format", "", "File format (csv|ndjson), guessed from the file name if not set")
+	dryRun := fs.Bool("dry-run", false, "Check the file without importing anything")
+	onDuplicate := fs.String("on-duplicate", "abort", "What to do if any movies are duplicates (skip|abort)")
+	actor := fs.String("actor", "import", "Actor to record in the movies' history")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api import [flags] FILE")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 || !validator.In(*onDuplicate, "skip", "abort") {
+		fs.Usage()
+		return 2
+	}
+
+	logger := log.New(os.Stderr, "", log.Ldate|log.Ltime)
+
+	name := fs.Arg(0)
+	if *format == "" {
+		*format = string(movieio.FormatForFilename(name))
+	}
+
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			logger.Println(err)
+			return 1
+		}
+		defer file.Close()
+
+		in = file
+	}
+
+	app, err := newCommandApplication(*dsn, logger)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	defer app.db.Close()
+
+	opts := data.ImportOptions{SkipDuplicates: *onDuplicate == "skip", DryRun: *dryRun}
+
+	report, err := app.importMovies(in, movieio.Format(*format), opts, data.Audit{Actor: *actor}, i18n.Fallback)
+	if report != nil {
+		js, jsErr := json.MarshalIndent(report, "", "\t")
+		if jsErr != nil {
+			logger.Println(jsErr)
+			return 1
+		}
+		fmt.Println(string(js))
+	}
+
+	if err != nil {
+		if report == nil {
+			logger.Println(translateError(err, i18n.Fallback))
+		}
+		return 1
+	}
+
+	return 0
+}
+
+//newCommandApplication returns an application with a small connection pool, for subcommands which work on the
+//database directly rather than serving requests.
+func newCommandApplication(dsn string, logger *log.Logger) (*application, error) {
+	var cfg config
+
+	cfg.db.dsn = dsn
+	cfg.db.maxOpenConns = 2
+	cfg.db.maxIdleConns = 2
+	cfg.db.maxIdleTime = "1m"
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &application{
+		config: cfg,
+		logger: logger,
+		models: data.NewModels(db),
+		db:     db,
+	}, nil
+}
+
+//translateError returns the error's message in the given language if it's an i18n message.
+func translateError(err error, lang string) string {
+	var msg *i18n.Message
+	if errors.As(err, &msg) {
+		return msg.Translate(lang)
+	}
+	return err.Error()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//background runs a function in a goroutine which the graceful shutdown waits for. Any panic is recovered and logged,
+//so a failing background task can't take the whole server down.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Println(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+//purgeTombstones permanently removes soft deleted movies once they are older than the retention period. It runs
+//every purge interval until the application starts shutting down.
+func (app *application) purgeTombstones() {
+	ticker := time.NewTicker(app.config.tombstones.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cutoff := time.Now().Add(-app.config.tombstones.retention)
+
+			//The purges are recorded in each movie's history as the work of this job
+			n, err := app.models.Movies.PurgeDeletedBefore(cutoff, data.Audit{Actor: "tombstone-purge"})
+			if err != nil {
+				app.logger.Println(err)
+				continue
+			}
+
+			if n > 0 {
+				app.logger.Printf("purged %d deleted movies older than %s", n, app.config.tombstones.retention)
+			}
+
+		case <-app.done:
+			return
+		}
+	}
+}
+
+//purgeIdempotencyKeys removes expired idempotency keys every purge interval until the application starts shutting down.
+func (app *application) purgeIdempotencyKeys() {
+	ticker := time.NewTicker(app.config.idempotency.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, err := app.models.IdempotencyKeys.DeleteExpired(time.Now())
+			if err != nil {
+				app.logger.Println(err)
+			}
+
+		case <-app.done:
+			return
+		}
+	}
+}
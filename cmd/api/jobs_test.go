@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//purgeCall records the arguments of a PurgeDeletedBefore() call.
+type purgeCall struct {
+	cutoff time.Time
+	audit  data.Audit
+}
+
+//purgeTestMovies sends each PurgeDeletedBefore() call on a channel.
+type purgeTestMovies struct {
+	data.MockMovieModel
+	calls chan purgeCall
+}
+
+func (m purgeTestMovies) PurgeDeletedBefore(cutoff time.Time, audit data.Audit) (int64, error) {
+	m.calls <- purgeCall{cutoff, audit}
+	return 1, nil
+}
+
+func TestPurgeTombstones(t *testing.T) {
+	movies := purgeTestMovies{calls: make(chan purgeCall)}
+
+	app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), done: make(chan struct{})}
+	app.models.Movies = movies
+	app.config.tombstones.purgeInterval = time.Millisecond
+	app.config.tombstones.retention = time.Hour
+
+	start := time.Now()
+	app.background(app.purgeTombstones)
+
+	call := <-movies.calls
+
+	if earliest := start.Add(-time.Hour); call.cutoff.Before(earliest) || call.cutoff.After(time.Now().Add(-time.Hour)) {
+		t.Errorf("got cutoff %s, want one retention period before now", call.cutoff)
+	}
+	if call.audit.Actor != "tombstone-purge" {
+		t.Errorf("got actor %q, want \"tombstone-purge\"", call.audit.Actor)
+	}
+
+	//The job stops once shutdown starts. It may be part way through another purge, so keep taking its calls until it does
+	close(app.done)
+
+	stopped := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(stopped)
+	}()
+
+	for {
+		select {
+		case <-movies.calls:
+		case <-stopped:
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//link is a HAL link object, as found in a "_links" member.
+type link struct {
+	Href string `json:"href"`
+}
+
+//links are the members of a "_links" object, keyed by link relation.
+type links map[string]link
+
+//routePath fills in the named parameters of a route's path, in order, so routePath(pathMovie, 1) is "/v1/movies/1".
+//It panics if the number of values doesn't match the number of parameters, as the link would be wrong.
+func routePath(path string, values ...interface{}) string {
+	segments := strings.Split(path, "/")
+
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+
+		if len(values) == 0 {
+			panic("missing value for parameter " + segment + " in " + path)
+		}
+
+		segments[i] = url.PathEscape(fmt.Sprint(values[0]))
+		values = values[1:]
+	}
+
+	if len(values) > 0 {
+		panic("too many values for " + path)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+//movieLinks returns the links for a movie: itself, the list of movies and its history.
+func movieLinks(id int64) links {
+	return links{
+		"self":       {Href: routePath(pathMovie, id)},
+		"collection": {Href: routePath(pathMovies)},
+		"history":    {Href: routePath(pathMovieHistory, id)},
+	}
+}
+
+//paginationRels are the link relations for paging through a list, in the order they are sent in the Link header.
+var paginationRels = []string{"first", "prev", "next", "last"}
+
+//pageLinks returns the links for a page of a list: itself, and the first, previous, next and last pages. The other
+//pages keep the request's query string, so they have the same filters, sort and page size, and only prev and next
+//pages which exist are included. With no results we don't know how many pages there are, so only first is included.
+func pageLinks(r *http.Request, metadata data.Metadata) links {
+	page := func(n int) link {
+		qs := r.URL.Query()
+		qs.Set("page", strconv.Itoa(n))
+		return link{Href: r.URL.Path + "?" + qs.Encode()}
+	}
+
+	l := links{
+		"self":  {Href: r.URL.RequestURI()},
+		"first": page(1),
+	}
+
+	if metadata.TotalRecords == 0 {
+		return l
+	}
+
+	l["last"] = page(metadata.LastPage)
+
+	if metadata.CurrentPage > metadata.FirstPage && metadata.CurrentPage <= metadata.LastPage {
+		l["prev"] = page(metadata.CurrentPage - 1)
+	}
+	if metadata.CurrentPage < metadata.LastPage {
+		l["next"] = page(metadata.CurrentPage + 1)
+	}
+
+	return l
+}
+
+//linkHeader formats the links with the given relations as an RFC 8288 Link header, like
+//`</v1/movies?page=2>; rel="next"`, skipping any which aren't there.
+func linkHeader(l links, rels ...string) string {
+	var values []string
+
+	for _, rel := range rels {
+		if lnk, ok := l[rel]; ok {
+			values = append(values, fmt.Sprintf(`<%s>; rel="%s"`, lnk.Href, rel))
+		}
+	}
+
+	return strings.Join(values, ", ")
+}
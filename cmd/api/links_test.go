@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+func TestRoutePath(t *testing.T) {
+	tests := []struct {
+		path   string
+		values []interface{}
+		want   string
+	}{
+		{pathMovies, nil, "/v1/movies"},
+		{pathMovie, []interface{}{int64(7)}, "/v1/movies/7"},
+		{pathMovieByExternal, []interface{}{"imdb/tt 1"}, "/v1/movies/by-external-id/imdb%2Ftt%201"},
+		{pathWebhookRetry, []interface{}{1, 2}, "/v1/webhooks/1/deliveries/2/retry"},
+	}
+
+	for _, tt := range tests {
+		if got := routePath(tt.path, tt.values...); got != tt.want {
+			t.Errorf("routePath(%q, %v) = %q, want %q", tt.path, tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata data.Metadata
+		want     links
+	}{
+		{
+			"no results",
+			"/v1/movies?title=zzz",
+			data.Metadata{},
+			links{
+				"self":  {Href: "/v1/movies?title=zzz"},
+				"first": {Href: "/v1/movies?page=1&title=zzz"},
+			},
+		},
+		{
+			"only page",
+			"/v1/movies",
+			data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 3},
+			links{
+				"self":  {Href: "/v1/movies"},
+				"first": {Href: "/v1/movies?page=1"},
+				"last":  {Href: "/v1/movies?page=1"},
+			},
+		},
+		{
+			"middle page",
+			"/v1/movies?page=2&page_size=5&sort=-year",
+			data.Metadata{CurrentPage: 2, PageSize: 5, FirstPage: 1, LastPage: 4, TotalRecords: 18},
+			links{
+				"self":  {Href: "/v1/movies?page=2&page_size=5&sort=-year"},
+				"first": {Href: "/v1/movies?page=1&page_size=5&sort=-year"},
+				"prev":  {Href: "/v1/movies?page=1&page_size=5&sort=-year"},
+				"next":  {Href: "/v1/movies?page=3&page_size=5&sort=-year"},
+				"last":  {Href: "/v1/movies?page=4&page_size=5&sort=-year"},
+			},
+		},
+		{
+			"past the last page",
+			"/v1/movies?page=9",
+			data.Metadata{CurrentPage: 9, PageSize: 20, FirstPage: 1, LastPage: 2, TotalRecords: 30},
+			links{
+				"self":  {Href: "/v1/movies?page=9"},
+				"first": {Href: "/v1/movies?page=1"},
+				"last":  {Href: "/v1/movies?page=2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pageLinks(httptest.NewRequest(http.MethodGet, tt.url, nil), tt.metadata)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkHeader(t *testing.T) {
+	l := links{
+		"self":  {Href: "/v1/movies?page=2"},
+		"first": {Href: "/v1/movies?page=1"},
+		"next":  {Href: "/v1/movies?page=3"},
+	}
+
+	want := `</v1/movies?page=1>; rel="first", </v1/movies?page=3>; rel="next"`
+	if got := linkHeader(l, paginationRels...); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if got := linkHeader(links{"self": {Href: "/v1/movies"}}, paginationRels...); got != "" {
+		t.Errorf("got %s, want no header", got)
+	}
+}
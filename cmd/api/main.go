@@ -6,39 +6,109 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"sync"
 	"time"
 
+	"firstAPI.jweaver11.net/internal/changefeed"
+	"firstAPI.jweaver11.net/internal/codec"
 	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/events"
+	"firstAPI.jweaver11.net/internal/graphql"
+	"firstAPI.jweaver11.net/internal/validator"
+	"firstAPI.jweaver11.net/internal/vcs"
 	//import pq driver so that it can register itself with the database/sql package.
 	_ "github.com/lib/pq" //Uses black identifier so compiler doesn't complain its not being used.
 )
 
-//Declares var 'version' as a global constant string containing the application version number. Will be dynamic later.
-const version = "1.0.0"
+//Declares 'buildInfo' as the application version and VCS metadata, read from the binary at startup
+//and overridable with -ldflags (see internal/vcs).
+var buildInfo = vcs.Get()
 
 //Defines 'config' as a struct to hold all configuration settings for our app.
 type config struct {
-	port int    //'port' is the network port for the server to listen on
-	env  string //'env' is the name of current operating environment for the app
-	db   struct {
-		dsn          string
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime  string
+	port       int           //'port' is the network port for the server to listen on
+	env        string        //'env' is the name of current operating environment for the app
+	drainDelay time.Duration //'drainDelay' is how long readiness fails before the server shuts down
+	adminToken string        //'adminToken' is the bearer token administrators send, or empty if there are none
+	db         struct {
+		dsn              string
+		maxOpenConns     int
+		maxIdleConns     int
+		maxIdleTime      string
+		migrationVersion int64
+	}
+	tombstones struct {
+		retention     time.Duration //how long soft deleted movies are kept before they are purged
+		purgeInterval time.Duration //how often the purge job runs
+	}
+	idempotency struct {
+		store         string        //where idempotency keys are kept, "postgres" or "memory"
+		lease         time.Duration //how long a key is locked for while its request is handled
+		ttl           time.Duration //how long a stored response is replayed for
+		purgeInterval time.Duration //how often expired keys are removed
+	}
+	compression struct {
+		minSize int //responses smaller than this many bytes are sent uncompressed
+		level   int //the gzip and deflate compression level, from 1 (fastest) to 9 (smallest), or -1 for the default
+	}
+	graphql struct {
+		maxDepth      int //how deeply the fields of a GraphQL query can be nested
+		maxComplexity int //the highest total cost of the fields in a GraphQL query
+	}
+	grpc struct {
+		port    int    //the port the gRPC MovieService listens on, or 0 to turn it off
+		tlsCert string //the TLS certificate file for the gRPC server, which must use HTTP/2 over TLS
+		tlsKey  string //the TLS private key file for the gRPC server
+	}
+	changes struct {
+		replaySize int           //how many recent movie changes are kept for clients resuming with Last-Event-ID
+		heartbeat  time.Duration //how often an idle change stream is sent a heartbeat
+	}
+	webhooks struct {
+		pollInterval time.Duration //how often the delivery worker looks for due deliveries
+		timeout      time.Duration //how long a receiver has to respond to a webhook
+		maxAttempts  int           //how many times a delivery is tried before it's dead lettered
+		backoffBase  time.Duration //the wait after the first failed attempt, which doubles after each one
+		backoffMax   time.Duration //the longest wait between attempts
+		retention    time.Duration //how long finished deliveries are kept for inspection
+		allowPrivate bool          //whether webhooks can be sent to loopback, link-local and private addresses
+	}
+	events struct {
+		pollInterval time.Duration //how often the relay looks for new events in the outbox
+		batchSize    int           //how many events each handler is given at a time
+		retention    time.Duration //how long events are kept after every handler has dealt with them
 	}
 }
 
 //Declares 'application' as a struct to hold dependecies for our HTTP handlers, helpers, and middleware. Will grow as we build
 type application struct {
-	config config      //copy of config struct
-	logger *log.Logger //'logger' is a logger
-	models data.Models
+	config   config      //copy of config struct
+	logger   *log.Logger //'logger' is a logger
+	models   data.Models
+	db       *sql.DB            //'db' is the connection pool, used directly by the health probes
+	draining int32              //'draining' is set to 1 once graceful shutdown has started
+	done     chan struct{}      //'done' is closed when graceful shutdown starts, to stop background jobs
+	wg       sync.WaitGroup     //'wg' tracks background goroutines so shutdown can wait for them
+	codecs   *codec.Registry    //'codecs' are the response formats clients can ask for, with the default first
+	encoders []*contentEncoder  //'encoders' are the content codings responses can be compressed with, in order of preference
+	graphql  *graphql.Schema    //'graphql' is the schema served at /v1/graphql
+	changes  *changefeed.Broker //'changes' fans movie changes out to /v1/movies/changes streams
+	events   *events.Relay      //'events' dispatches the events in the outbox to in-process handlers
 }
 
 //MAIN FUNCTION***************************************************************************************************************
 func main() {
+	//Subcommands like "api import" and "api export" have their own flags, so they are run before ours are parsed
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(importCommand(os.Args[2:]))
+		case "export":
+			os.Exit(exportCommand(os.Args[2:]))
+		}
+	}
+
 	var cfg config //Declares 'cfg' as an instance of the config struct
 
 	//Reads the value of the port and env command-line flags into the config struct. We set default port number to '4000'
@@ -55,12 +125,83 @@ func main() {
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
 
+	//Read the schema version the readiness probe expects the database to be migrated to
+	flag.Int64Var(&cfg.db.migrationVersion, "db-migration-version", 10, "Expected database migration version")
+
+	flag.DurationVar(&cfg.drainDelay, "drain-delay", 5*time.Second, "Time to report not ready before shutting down")
+
+	//Read the token administrators send in an "Authorization: Bearer" header. Without one, the admin-only endpoints,
+	//like listing and purging deleted movies, are turned off
+	flag.StringVar(&cfg.adminToken, "admin-token", os.Getenv("FIRSTAPI_ADMIN_TOKEN"), "Bearer token for admin-only requests")
+
+	//Read how long soft deleted movies are kept, and how often the purge job checks for expired ones
+	flag.DurationVar(&cfg.tombstones.retention, "tombstone-retention", 30*24*time.Hour, "How long deleted movies are kept before being purged")
+	flag.DurationVar(&cfg.tombstones.purgeInterval, "tombstone-purge-interval", time.Hour, "How often expired deleted movies are purged")
+
+	//Read where Idempotency-Key responses are stored and how long they are kept. The memory store is only suitable
+	//for running a single instance. The lease should be longer than the server's write timeout, so a key is only taken
+	//over once the request holding it can't still be running
+	flag.StringVar(&cfg.idempotency.store, "idempotency-store", "postgres", "Idempotency key store (postgres|memory)")
+	flag.DurationVar(&cfg.idempotency.lease, "idempotency-lease", time.Minute, "How long an Idempotency-Key is locked while its request is handled")
+	flag.DurationVar(&cfg.idempotency.ttl, "idempotency-ttl", 24*time.Hour, "How long responses to Idempotency-Key requests are replayed")
+	flag.DurationVar(&cfg.idempotency.purgeInterval, "idempotency-purge-interval", time.Hour, "How often expired idempotency keys are removed")
+
+	//Read the response compression settings
+	flag.IntVar(&cfg.compression.minSize, "compress-min-size", 1024, "Smallest response body, in bytes, to compress")
+	flag.IntVar(&cfg.compression.level, "compress-level", -1, "Compression level, from 1 (fastest) to 9 (smallest), or -1 for the default")
+
+	//Read the limits on GraphQL queries. A list field's cost is multiplied by the number of items it can return
+	flag.IntVar(&cfg.graphql.maxDepth, "graphql-max-depth", 10, "Deepest nesting of fields allowed in a GraphQL query")
+	flag.IntVar(&cfg.graphql.maxComplexity, "graphql-max-complexity", 1000, "Highest total field cost allowed in a GraphQL query")
+
+	//Read the gRPC server settings. Without a certificate, a self-signed one is made up at startup, which is only good
+	//enough for development
+	flag.IntVar(&cfg.grpc.port, "grpc-port", 4001, "gRPC server port, or 0 to disable it")
+	flag.StringVar(&cfg.grpc.tlsCert, "grpc-tls-cert", "", "TLS certificate file for the gRPC server")
+	flag.StringVar(&cfg.grpc.tlsKey, "grpc-tls-key", "", "TLS private key file for the gRPC server")
+
+	//Read the movie change stream settings
+	flag.IntVar(&cfg.changes.replaySize, "changes-replay-size", 1000, "How many recent movie changes are kept for resuming change streams")
+	flag.DurationVar(&cfg.changes.heartbeat, "changes-heartbeat", 15*time.Second, "How often idle movie change streams are sent a heartbeat")
+
+	//Read the webhook delivery settings. With the defaults, a delivery is tried for about a day before it's dead lettered
+	flag.DurationVar(&cfg.webhooks.pollInterval, "webhook-poll-interval", 5*time.Second, "How often due webhook deliveries are sent")
+	flag.DurationVar(&cfg.webhooks.timeout, "webhook-timeout", 10*time.Second, "How long a webhook receiver has to respond")
+	flag.IntVar(&cfg.webhooks.maxAttempts, "webhook-max-attempts", 10, "Attempts at a webhook delivery before it's dead lettered")
+	flag.DurationVar(&cfg.webhooks.backoffBase, "webhook-backoff-base", 30*time.Second, "Wait after a webhook delivery's first failure, doubling after each")
+	flag.DurationVar(&cfg.webhooks.backoffMax, "webhook-backoff-max", 6*time.Hour, "Longest wait between attempts at a webhook delivery")
+	flag.DurationVar(&cfg.webhooks.retention, "webhook-retention", 30*24*time.Hour, "How long finished webhook deliveries are kept")
+	flag.BoolVar(&cfg.webhooks.allowPrivate, "webhook-allow-private", false, "Allow webhooks to loopback, link-local and private addresses, for development")
+
+	//Read the event relay settings
+	flag.DurationVar(&cfg.events.pollInterval, "events-poll-interval", time.Second, "How often the outbox is checked for new events")
+	flag.IntVar(&cfg.events.batchSize, "events-batch-size", 100, "How many events each event handler is given at a time")
+	flag.DurationVar(&cfg.events.retention, "events-retention", 7*24*time.Hour, "How long handled events are kept in the outbox")
+
+	//Add a -version flag which prints the build information and exits
+	displayVersion := flag.Bool("version", false, "Display version and exit")
+
 	flag.Parse()
 
+	if *displayVersion {
+		fmt.Printf("Version:\t%s\n", buildInfo.Version)
+		fmt.Printf("Revision:\t%s\n", buildInfo.Revision)
+		fmt.Printf("Commit time:\t%s\n", buildInfo.Time)
+		fmt.Printf("Modified:\t%t\n", buildInfo.Modified)
+		os.Exit(0)
+	}
+
 	//Initialize 'logger' a a new logger to write messages to the standard out stream
 	//Previxed with the current date and time.
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
+	//Check the validate tags of the structs we validate, so a mistake in one stops the server starting rather than
+	//failing requests
+	err := validator.CheckTags(data.Movie{}, data.WebhookSubscription{})
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.Fatal(err)
@@ -72,26 +213,58 @@ func main() {
 
 	//Declares 'app' as an instance of application struct, containing the config struct and the logger
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:  cfg,
+		logger:  logger,
+		models:  data.NewModels(db),
+		db:      db,
+		done:    make(chan struct{}),
+		codecs:  codec.NewRegistry(codec.JSON{}, codec.XML{}, codec.CSV{}, codec.MessagePack{}),
+		changes: changefeed.New(cfg.changes.replaySize, changeSubscriberBuffer),
 	}
 
-	//Declares a HTTP server with some sensible timeout settings, which listens to provided port in the config struct
-	//uses the 'routes.go' as the server handler
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.port),
-		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+	app.encoders, err = newContentEncoders(cfg.compression.level)
+	if err != nil {
+		logger.Fatalf("invalid -compress-level: %v", err)
 	}
 
-	//Starts the HTTP server.
-	logger.Printf("starting %s server on %s", cfg.env, srv.Addr)
+	app.graphql, err = app.newGraphQLSchema()
+	if err != nil {
+		logger.Fatal(err)
+	}
 
-	err = srv.ListenAndServe()
-	logger.Fatal(err)
+	//Swap in the chosen idempotency key store
+	switch cfg.idempotency.store {
+	case "postgres":
+	case "memory":
+		app.models.IdempotencyKeys = data.NewMemoryIdempotencyStore()
+	default:
+		logger.Fatalf("invalid -idempotency-store %q", cfg.idempotency.store)
+	}
+
+	//Start the job which purges expired soft deleted movies
+	app.background(app.purgeTombstones)
+	app.background(app.purgeIdempotencyKeys)
+
+	//Start listening for movie changes to send to /v1/movies/changes streams
+	app.background(app.listenForMovieChanges)
+
+	//Register the handlers for the events in the outbox. Every instance runs its own relay, and any handler may see an
+	//event more than once, so they must all be idempotent
+	app.events = events.NewRelay(app.models.Outbox, logger, cfg.events.pollInterval, cfg.events.batchSize, cfg.events.retention)
+	app.events.Handle("webhooks", app.enqueueWebhooks)
+
+	app.background(func() {
+		app.events.Run(app.done)
+	})
+
+	//Start the worker which sends webhooks
+	app.background(app.deliverWebhooks)
+
+	//Starts the HTTP server, which blocks until it has been shut down gracefully
+	err = app.serve()
+	if err != nil {
+		logger.Fatal(err)
+	}
 }
 
 func openDB(cfg config) (*sql.DB, error) {
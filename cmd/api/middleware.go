@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+//setVersionHeader adds an X-API-Version header to every response so clients and logs can tell which build served a request.
+func (app *application) setVersionHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-API-Version", buildInfo.Version)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//requestID gives every request an ID, which is echoed in the X-Request-ID response header and recorded in the movie
+//history. A client or proxy can supply its own ID in the X-Request-ID request header, so long as it's sensibly sized.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 16)
+
+			_, err := rand.Read(b)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
+//isAdmin reports whether a request was sent by an administrator, which it was if it has the admin token in an
+//"Authorization: Bearer" header. Without an -admin-token, nobody is an administrator.
+func (app *application) isAdmin(r *http.Request) bool {
+	if app.config.adminToken == "" {
+		return false
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(app.config.adminToken)) == 1
+}
+
+//requireAdmin sends a 403 Forbidden response to requests which weren't sent by an administrator.
+func (app *application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdmin(r) {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name          string
+		adminToken    string
+		authorization string
+		wantStatus    int
+	}{
+		{"admin", "secret", "Bearer secret", http.StatusOK},
+		{"no header", "secret", "", http.StatusForbidden},
+		{"wrong token", "secret", "Bearer secre", http.StatusForbidden},
+		{"wrong scheme", "secret", "Basic secret", http.StatusForbidden},
+		{"no token configured", "", "Bearer ", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{logger: log.New(io.Discard, "", 0)}
+			app.config.adminToken = tt.adminToken
+
+			handler := app.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies/1/purge", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 
 	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
 	"firstAPI.jweaver11.net/internal/validator"
+
+	"github.com/julienschmidt/httprouter"
 )
 
 //Add a 'createMovieHandler' for the "Post /v1/movies" endpoint.
@@ -32,6 +36,10 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
 	movie := &data.Movie{
 		Title:   input.Title,
 		Year:    input.Year,
@@ -39,16 +47,14 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Genres:  input.Genres,
 	}
 
-	v := validator.New()
-
 	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	//Call the Insert() method on our movies model, passing in a pointer to the validated movie struct. This will
 	//create a record in the database and update the movie struct with the system-generated information
-	err = app.models.Movies.Insert(movie)
+	err = app.models.Movies.Insert(movie, app.audit(r))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -58,15 +64,14 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	//they can find the newly-created resource at. We make an empty http.Header map and then use the Set()
 	//method to add a new Loacation header, interpolating the system-generated ID for our new movie in the URL.
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("Location", routePath(pathMovie, movie.ID))
+	headers.Set("ETag", movieETag(movie, runtimeVariant(runtimeFormat)))
 
 	//Write a JSON response with a 201 Create status code, the movie data in the response body, and Location header
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"movie": newMovieResponse(movie, runtimeFormat)}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
-
-	fmt.Fprintf(w, "%+v\n", input)
 }
 
 //Add a 'showMovieHandler' for the "Get /v1/movies/:id" endpoint.
@@ -78,21 +83,38 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	//fields=id,title asks for only some of the movie's fields
+	fields := app.readFields(r.URL.Query(), v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
 	//Call the Get() method to fetch the data for a specific movie. We also need to use the Errors.Is() function
 	//to check if it returns a data.ErrRecordNotFound error, in which case we send a 404 Not Found response to the client
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(id, fields...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	//Encode the struct to JSON and send it as the HTTP response
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	//Send the movie's ETag. writeResponse() sends a 304 Not Modified response with no body if the client already has
+	//this version cached
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie, movieVariant(runtimeFormat, fields)))
+
+	//Encode the struct in the format the client asked for and send it as the HTTP response
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": newMovieResponse(movie, runtimeFormat, fields...)}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -111,69 +133,185 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	//Declare an input struct to hold the expected fata from client
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+	//If the client sent an If-Match header, only go ahead if it matches the version we just fetched.
+	//The version is then checked again inside Update(), so a change made after this point is still caught
+	if !ifMatchAllows(r, movie) {
+		app.preconditionFailedResponse(w, r)
+		return
 	}
 
-	//Read the JSON request body data into the iput strucdt
-	err = app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
+	//The Content-Type decides how the request body changes the movie. Plain JSON sets any fields which are present,
+	//while JSON Merge Patch and JSON Patch documents are applied to the movie's JSON representation
+	switch requestMediaType(r) {
+	case "", "application/json":
+		//Declare an input struct to hold the expected fata from client
+		var input struct {
+			Title   *string       `json:"title"`
+			Year    *int32        `json:"year"`
+			Runtime *data.Runtime `json:"runtime"`
+			Genres  []string      `json:"genres"`
+		}
+
+		//Read the JSON request body data into the iput strucdt
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		//If the input.Title value is nil then we know no corresponding "title" key value pair was provided in the JSON body request.
+		//We leave the movie record unchanged, otherwise we update the movie record with new title.
+		//Since it is a pointer now, we need to dereference the pointer using the * operator to get underlying value before
+		//assigning it to movie record
+		if input.Title != nil {
+			movie.Title = *input.Title
+		}
+
+		//We also do the same for other fileds in input struct
+		if input.Year != nil {
+			movie.Year = *input.Year
+		}
+		if input.Runtime != nil {
+			movie.Runtime = *input.Runtime
+		}
+		if input.Genres != nil {
+			movie.Genres = input.Genres //We dont deference a slice
+		}
+
+	case mergePatchMediaType, jsonPatchMediaType:
+		if !app.patchMovie(w, r, movie) {
+			return
+		}
+
+	default:
+		app.unsupportedMediaTypeResponse(w, r, patchMediaTypes)
 		return
 	}
 
-	//If the input.Title value is nil then we know no corresponding "title" key value pair was provided in the JSON body request.
-	//We leave the movie record unchanged, otherwise we update the movie record with new title.
-	//Since it is a pointer now, we need to dereference the pointer using the * operator to get underlying value before
-	//assigning it to movie record
-	if input.Title != nil {
-		movie.Title = *input.Title
+	//Validate the updated movie record, sending the client 422 uprocessable entit response if any checks fail
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
 	}
 
-	//We also do the same for other fileds in input struct
-	if input.Year != nil {
-		movie.Year = *input.Year
+	//Intercept any ErrEditConflict error to call the new editConflictResponse() helper. If the client made the
+	//update conditional with If-Match, the conflict means their precondition no longer holds, so we send 412 instead
+	err = app.models.Movies.Update(movie, app.audit(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie, runtimeVariant(runtimeFormat)))
+
+	//Write thee update movie record in a JSON responsee
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": newMovieResponse(movie, runtimeFormat)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 	}
-	if input.Genres != nil {
-		movie.Genres = input.Genres //We dont deference a slice
+}
+
+//upsertMovieHandler creates or fully replaces the movie with an external ID for the "PUT /v1/movies/by-external-id/:ext"
+//endpoint. It's idempotent, so a client can safely retry it. Every field must be sent, as nothing is kept from the old movie
+//except its ID. If-Match makes it replace only a movie at a version the client has seen, and If-None-Match: * makes it create only.
+func (app *application) upsertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	externalID := httprouter.ParamsFromContext(r.Context()).ByName("ext")
+
+	var input struct {
+		Title   string       `json:"title"`
+		Year    int32        `json:"year"`
+		Runtime data.Runtime `json:"runtime"`
+		Genres  []string     `json:"genres"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	//Validate the updated movie record, sending the client 422 uprocessable entit response if any checks fail
 	v := validator.New()
 
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	movie := &data.Movie{
+		ExternalID: externalID,
+		Title:      input.Title,
+		Year:       input.Year,
+		Runtime:    input.Runtime,
+		Genres:     input.Genres,
+	}
+
 	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	//Check the preconditions against the current movie. A missing movie never matches If-Match, and an existing one
+	//always matches If-None-Match: *
+	existing, err := app.models.Movies.GetByExternalID(externalID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	//Intercept any ErrEditConflict error to call the new editConflictResponse() helper
-	err = app.models.Movies.Update(movie)
+	var expectedVersion *int32
+
+	if existing != nil {
+		if !ifMatchAllows(r, existing) || r.Header.Get("If-None-Match") == "*" {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+
+		//Pin the version we checked, so a change made after this point is caught by Upsert()
+		if r.Header.Get("If-Match") != "" {
+			expectedVersion = &existing.Version
+		}
+	} else if r.Header.Get("If-Match") != "" {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	created, err := app.models.Movies.Upsert(movie, expectedVersion, app.audit(r))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(w, r)
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	//Write thee update movie record in a JSON responsee
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie, runtimeVariant(runtimeFormat)))
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", routePath(pathMovie, movie.ID))
+	}
+
+	err = app.writeResponse(w, r, status, envelope{"movie": newMovieResponse(movie, runtimeFormat)}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -187,12 +325,29 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	//Delte the movie from the database, sending a 404 Not Found response to the client if there isn't a matching record
-	err = app.models.Movies.Delete(id)
+	//Delte the movie from the database, sending a 404 Not Found response to the client if there isn't a matching record.
+	//If the client sent an If-Match header, the movie is only deleted if it's still at one of the versions they listed
+	versions, any, conditional := ifMatchVersions(r, id)
+
+	switch {
+	case !conditional || any:
+		err = app.models.Movies.Delete(id, app.audit(r))
+	default:
+		err = data.ErrEditConflict
+		for _, version := range versions {
+			err = app.models.Movies.DeleteVersion(id, version, app.audit(r))
+			if !errors.Is(err, data.ErrEditConflict) {
+				break
+			}
+		}
+	}
+
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.movieNotFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -200,12 +355,15 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	//Return a 200 OK status code along with a success message
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+//movieSortSafelist lists the values the sort query string parameter can take when listing or exporting movies.
+var movieSortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	//Define input struct to hold epected values from request query string.
 	var input struct {
@@ -230,24 +388,334 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 
 	//Extract the sort query string value, falling back to "id" if not provided by client
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortSafelist = movieSortSafelist
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	//deleted=true lists the soft deleted movies instead of the live ones, which only administrators can see
+	deleted := app.readBool(qs, "deleted", false, v)
+	if deleted && !app.isAdmin(r) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	//fields=id,title asks for only some of each movie's fields
+	fields := app.readFields(qs, v)
 
 	//Check the Vallidator instance for any errors and use the 'failedValidationResponse()' helper
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	//Call the 'GetAll()' method to retrieve the movies, passing in the various filter parameters
-	movies, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	var movies []*data.Movie
+	var metadata data.Metadata
+	var err error
+
+	if deleted {
+		movies, metadata, err = app.models.Movies.GetAllDeleted(input.Title, input.Genres, input.Filters, fields...)
+	} else {
+		movies, metadata, err = app.models.Movies.GetAll(input.Title, input.Genres, input.Filters, fields...)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	//Send an ETag for the page of results, which writeResponse() also uses to send a 304 Not Modified response if
+	//the client's cached copy is still current
+	headers := make(http.Header)
+	headers.Set("ETag", movieListETag(movies, metadata, movieVariant(runtimeFormat, fields)))
+
+	//Link to the other pages, both in the body and in a Link header for clients which don't read the body's links
+	pages := pageLinks(r, metadata)
+	if link := linkHeader(pages, paginationRels...); link != "" {
+		headers.Set("Link", link)
+	}
+
+	//Send a response containing the movie data
+	env := envelope{"movies": newMovieListResponse(movies, runtimeFormat, fields...), "_links": pages}
+
+	err = app.writeResponse(w, r, http.StatusOK, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//restoreMovieHandler brings back a soft deleted movie for the "POST /v1/movies/:id/restore" endpoint.
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	//Restore the movie, sending a 404 Not Found response if there's no deleted movie with this ID
+	movie, err := app.models.Movies.Restore(id, app.audit(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.movieNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie, runtimeVariant(runtimeFormat)))
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": newMovieResponse(movie, runtimeFormat)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//purgeMovieHandler permanently removes a soft deleted movie for the "POST /v1/movies/:id/purge" endpoint.
+//Live movies must be deleted first. Only administrators can purge movies.
+func (app *application) purgeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Movies.Purge(id, app.audit(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.movieNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "movie successfully purged"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//showMovieHistoryHandler returns every recorded change to a movie for the "GET /v1/movies/:id/history" endpoint.
+//The history is kept after a movie is deleted, so this works for deleted movies too.
+func (app *application) showMovieHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	revisions, err := app.models.Revisions.GetAllForMovie(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	//Every movie has at least its insert revision, so an empty history means there's no such movie
+	if len(revisions) == 0 {
+		app.movieNotFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"history": revisions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//revertMovieHandler sets a movie back to how it was at an earlier version for the "POST /v1/movies/:id/revert?version=N"
+//endpoint. The revert is saved as a new version, so it uses the same optimistic locking (and If-Match) as an update.
+func (app *application) revertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	version := app.readInt(qs, "version", 0, v)
+	v.Check(version >= 1, "version", i18n.Key("validation.min", "1"))
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	//Fetch the current movie, which must exist (and not be deleted) to be reverted
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.movieNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !ifMatchAllows(r, movie) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	//Find the revision which took the movie to the requested version
+	revision, err := app.models.Revisions.GetForVersion(id, int32(version))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("version", "validation.revision_not_found")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	snapshot, err := revision.Snapshot()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	//Copy the fields from the earlier version, keeping the current version number for the optimistic lock
+	movie.Title = snapshot.Title
+	movie.Year = snapshot.Year
+	movie.Runtime = snapshot.Runtime
+	movie.Genres = snapshot.Genres
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Movies.Revert(movie, app.audit(r))
 	if err != nil {
-		app.server.ErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	//Send a JSON response containing the movie data
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie, runtimeVariant(runtimeFormat)))
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": newMovieResponse(movie, runtimeFormat)}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+//movieResponse is how a movie is written in responses. It mirrors the JSON fields of data.Movie, but holds the runtime
+//with the format the client asked for, and the sparse fieldset, if any, to write.
+type movieResponse struct {
+	ID         int64                  `json:"id"`
+	Title      string                 `json:"title"`
+	Year       int32                  `json:"year,omitempty"`
+	Runtime    *data.FormattedRuntime `json:"runtime,omitempty"`
+	Genres     []string               `json:"genres,omitempty"`
+	Version    int32                  `json:"version"`
+	ExternalID string                 `json:"external_id,omitempty"`
+	Links      links                  `json:"_links"`
+
+	fields []string
+}
+
+//MarshalJSON writes the movie, leaving out any fields which weren't selected. The fields keep their usual order, and
+//the links are always written.
+func (resp *movieResponse) MarshalJSON() ([]byte, error) {
+	//Marshal the struct without this method, which would otherwise call itself
+	type plainMovieResponse movieResponse
+
+	js, err := json.Marshal((*plainMovieResponse)(resp))
+	if err != nil || len(resp.fields) == 0 {
+		return js, err
+	}
+
+	var members map[string]json.RawMessage
+
+	err = json.Unmarshal(js, &members)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	//Copy the fields before adding the links, as every movie in a list shares the same slice
+	keys := append(append([]string(nil), resp.fields...), "_links")
+
+	for _, field := range keys {
+		value, ok := members[field]
+		if !ok {
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(field)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+//newMovieResponse wraps a movie for a response, writing its runtime in the given format. If any fields are given, only
+//those are written.
+func newMovieResponse(movie *data.Movie, format data.RuntimeFormat, fields ...string) *movieResponse {
+	if movie == nil {
+		return nil
+	}
+
+	resp := &movieResponse{
+		ID:         movie.ID,
+		Title:      movie.Title,
+		Year:       movie.Year,
+		Genres:     movie.Genres,
+		Version:    movie.Version,
+		ExternalID: movie.ExternalID,
+		Links:      movieLinks(movie.ID),
+		fields:     fields,
+	}
+
+	if movie.Runtime != 0 {
+		resp.Runtime = &data.FormattedRuntime{Runtime: movie.Runtime, Format: format}
+	}
+
+	return resp
+}
+
+//newMovieListResponse wraps each movie in a list for a response.
+func newMovieListResponse(movies []*data.Movie, format data.RuntimeFormat, fields ...string) []*movieResponse {
+	resp := make([]*movieResponse, 0, len(movies))
+
+	for _, movie := range movies {
+		resp = append(resp, newMovieResponse(movie, format, fields...))
+	}
+
+	return resp
+}
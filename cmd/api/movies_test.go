@@ -0,0 +1,460 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/codec"
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+func TestMovieResponseFields(t *testing.T) {
+	movie := &data.Movie{ID: 1, Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Version: 2}
+
+	//The fields have spare capacity, as a slice built by appending usually does
+	fields := make([]string, 0, 8)
+	fields = append(fields, "id", "title")
+
+	resp := newMovieListResponse([]*data.Movie{movie, movie}, data.RuntimeFormatMins, fields...)
+
+	for i := range resp {
+		js, err := json.Marshal(resp[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `{"id":1,"title":"Alien","_links":{"collection":{"href":"/v1/movies"},"history":{"href":"/v1/movies/1/history"},"self":{"href":"/v1/movies/1"}}}`
+		if string(js) != want {
+			t.Errorf("got %s, want %s", js, want)
+		}
+	}
+
+	if spare := fields[:cap(fields)][2]; spare != "" {
+		t.Errorf("marshalling wrote %q into the shared fields slice", spare)
+	}
+}
+
+//tombstoneTestMovies keeps live and soft deleted movies in memory, so they can be deleted, restored and purged.
+type tombstoneTestMovies struct {
+	data.MockMovieModel
+
+	live    map[int64]*data.Movie
+	deleted map[int64]*data.Movie
+}
+
+func (m *tombstoneTestMovies) Get(id int64, fields ...string) (*data.Movie, error) {
+	movie, ok := m.live[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	return movie, nil
+}
+
+func (m *tombstoneTestMovies) GetAll(title string, genres []string, filters data.Filters, fields ...string) ([]*data.Movie, data.Metadata, error) {
+	return sortedMovies(m.live), data.Metadata{}, nil
+}
+
+func (m *tombstoneTestMovies) GetAllDeleted(title string, genres []string, filters data.Filters, fields ...string) ([]*data.Movie, data.Metadata, error) {
+	return sortedMovies(m.deleted), data.Metadata{}, nil
+}
+
+func (m *tombstoneTestMovies) Delete(id int64, audit data.Audit) error {
+	movie, ok := m.live[id]
+	if !ok {
+		return data.ErrRecordNotFound
+	}
+
+	movie.Version++
+	delete(m.live, id)
+	m.deleted[id] = movie
+
+	return nil
+}
+
+func (m *tombstoneTestMovies) Restore(id int64, audit data.Audit) (*data.Movie, error) {
+	movie, ok := m.deleted[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	movie.Version++
+	delete(m.deleted, id)
+	m.live[id] = movie
+
+	return movie, nil
+}
+
+func (m *tombstoneTestMovies) Purge(id int64, audit data.Audit) error {
+	if _, ok := m.deleted[id]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	delete(m.deleted, id)
+
+	return nil
+}
+
+//sortedMovies returns the movies in a map, ordered by ID.
+func sortedMovies(movies map[int64]*data.Movie) []*data.Movie {
+	list := []*data.Movie{}
+	for _, movie := range movies {
+		list = append(list, movie)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list
+}
+
+func TestSoftDelete(t *testing.T) {
+	app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+	app.config.adminToken = "secret"
+	app.models.Movies = &tombstoneTestMovies{
+		live: map[int64]*data.Movie{
+			1: {ID: 1, Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Version: 1},
+			2: {ID: 2, Title: "Heat", Year: 1995, Runtime: 170, Genres: []string{"crime"}, Version: 1},
+		},
+		deleted: map[int64]*data.Movie{},
+	}
+
+	routes := app.routes()
+
+	//Each step runs against the state the steps before it left behind
+	steps := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"delete", http.MethodDelete, "/v1/movies/1", "", http.StatusOK, `"movie successfully deleted"`},
+		{"deleted movie is gone", http.MethodGet, "/v1/movies/1", "", http.StatusNotFound, "movie could not be found"},
+		{"deleted movie isn't listed", http.MethodGet, "/v1/movies?fields=id", "", http.StatusOK, `"movies":[{"id":2,`},
+		{"list deleted without the token", http.MethodGet, "/v1/movies?deleted=true", "", http.StatusForbidden, "administrator"},
+		{"list deleted with the wrong token", http.MethodGet, "/v1/movies?deleted=true", "wrong", http.StatusForbidden, "administrator"},
+		{"list deleted", http.MethodGet, "/v1/movies?deleted=true&fields=id", "secret", http.StatusOK, `"movies":[{"id":1,`},
+		{"restore", http.MethodPost, "/v1/movies/1/restore", "", http.StatusOK, `"version":3`},
+		{"restored movie is back", http.MethodGet, "/v1/movies/1", "", http.StatusOK, `"title":"Alien"`},
+		{"restore a live movie", http.MethodPost, "/v1/movies/1/restore", "", http.StatusNotFound, "movie could not be found"},
+		{"purge a live movie", http.MethodPost, "/v1/movies/1/purge", "secret", http.StatusNotFound, "movie could not be found"},
+		{"delete again", http.MethodDelete, "/v1/movies/1", "", http.StatusOK, `"movie successfully deleted"`},
+		{"purge without the token", http.MethodPost, "/v1/movies/1/purge", "", http.StatusForbidden, "administrator"},
+		{"purge", http.MethodPost, "/v1/movies/1/purge", "secret", http.StatusOK, `"movie successfully purged"`},
+		{"purged movie can't be restored", http.MethodPost, "/v1/movies/1/restore", "", http.StatusNotFound, "movie could not be found"},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, nil)
+		if step.token != "" {
+			r.Header.Set("Authorization", "Bearer "+step.token)
+		}
+
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, r)
+
+		if w.Code != step.wantStatus || !strings.Contains(w.Body.String(), step.wantBody) {
+			t.Fatalf("%s: got status %d and body %s, want %d and %s", step.name, w.Code, w.Body.String(), step.wantStatus, step.wantBody)
+		}
+	}
+}
+
+//revisionTestMovies holds movie 1 at version 3, and records what Revert() is called with.
+type revisionTestMovies struct {
+	data.MockMovieModel
+
+	reverted *data.Movie
+	audit    data.Audit
+}
+
+func (m *revisionTestMovies) Get(id int64, fields ...string) (*data.Movie, error) {
+	if id != 1 {
+		return nil, data.ErrRecordNotFound
+	}
+	return &data.Movie{ID: 1, Title: "Alien 3", Year: 1992, Runtime: 114, Genres: []string{"horror"}, Version: 3}, nil
+}
+
+func (m *revisionTestMovies) Revert(movie *data.Movie, audit data.Audit) error {
+	if movie.Version != 3 {
+		return data.ErrEditConflict
+	}
+
+	movie.Version++
+	m.reverted, m.audit = movie, audit
+
+	return nil
+}
+
+//revisionTestRevisions holds the history of movie 1, which was inserted and then updated twice.
+type revisionTestRevisions struct {
+	data.MockRevisionModel
+}
+
+var testRevisions = []*data.Revision{
+	{ID: 1, MovieID: 1, Version: 1, Operation: data.RevisionInsert, After: json.RawMessage(`{"id":1,"title":"Alien","year":1979,"runtime":117,"genres":["horror"],"version":1}`)},
+	{ID: 2, MovieID: 1, Version: 2, Operation: data.RevisionUpdate, After: json.RawMessage(`{"id":1,"title":"Aliens","year":1986,"runtime":137,"genres":["action"],"version":2}`)},
+	{ID: 3, MovieID: 1, Version: 3, Operation: data.RevisionUpdate, After: json.RawMessage(`{"id":1,"title":"Alien 3","year":1992,"runtime":114,"genres":["horror"],"version":3}`)},
+}
+
+func (revisionTestRevisions) GetAllForMovie(movieID int64) ([]*data.Revision, error) {
+	if movieID != 1 {
+		return nil, nil
+	}
+	return testRevisions, nil
+}
+
+func (revisionTestRevisions) GetForVersion(movieID int64, version int32) (*data.Revision, error) {
+	for _, rev := range testRevisions {
+		if rev.MovieID == movieID && rev.Version == version {
+			return rev, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func TestMovieHistory(t *testing.T) {
+	app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+	app.models.Revisions = revisionTestRevisions{}
+
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantCount  int
+	}{
+		{"/v1/movies/1/history", http.StatusOK, 3},
+		{"/v1/movies/2/history", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		app.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+		var body struct {
+			History []*data.Revision `json:"history"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != tt.wantStatus || len(body.History) != tt.wantCount {
+			t.Errorf("%s: got status %d and %d revisions, want %d and %d", tt.url, w.Code, len(body.History), tt.wantStatus, tt.wantCount)
+		}
+	}
+}
+
+func TestRevertMovie(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		ifMatch    string
+		token      string
+		wantStatus int
+		wantTitle  string
+		wantActor  string
+	}{
+		{"first version", "/v1/movies/1/revert?version=1", "", "", http.StatusOK, "Alien", "anonymous"},
+		{"by an administrator", "/v1/movies/1/revert?version=2", `"1-3"`, "secret", http.StatusOK, "Aliens", "admin"},
+		{"stale If-Match", "/v1/movies/1/revert?version=1", `"1-2"`, "", http.StatusPreconditionFailed, "", ""},
+		{"unknown version", "/v1/movies/1/revert?version=9", "", "", http.StatusUnprocessableEntity, "", ""},
+		{"no version", "/v1/movies/1/revert", "", "", http.StatusUnprocessableEntity, "", ""},
+		{"unknown movie", "/v1/movies/2/revert?version=1", "", "", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movies := &revisionTestMovies{}
+
+			app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+			app.config.adminToken = "secret"
+			app.models.Movies = movies
+			app.models.Revisions = revisionTestRevisions{}
+
+			r := httptest.NewRequest(http.MethodPost, tt.url, nil)
+			r.Header.Set("X-Request-ID", "revert-test")
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantTitle == "" {
+				if movies.reverted != nil {
+					t.Errorf("the movie was reverted to %+v", movies.reverted)
+				}
+				return
+			}
+
+			//The earlier version's fields are saved as a new version, recorded against the client and request
+			if movies.reverted.Title != tt.wantTitle || movies.reverted.Version != 4 {
+				t.Errorf("got %+v, want %q at version 4", movies.reverted, tt.wantTitle)
+			}
+			if want := (data.Audit{Actor: tt.wantActor, RequestID: "revert-test"}); movies.audit != want {
+				t.Errorf("got audit %+v, want %+v", movies.audit, want)
+			}
+			if w.Header().Get("ETag") != `"1-4"` {
+				t.Errorf("got ETag %s, want \"1-4\"", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+//upsertTestMovies holds movies by external ID, and gives new movies the next ID.
+type upsertTestMovies struct {
+	data.MockMovieModel
+
+	movies map[string]*data.Movie
+}
+
+func (m *upsertTestMovies) GetByExternalID(externalID string) (*data.Movie, error) {
+	movie, ok := m.movies[externalID]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	found := *movie
+	return &found, nil
+}
+
+func (m *upsertTestMovies) Upsert(movie *data.Movie, expectedVersion *int32, audit data.Audit) (bool, error) {
+	existing, ok := m.movies[movie.ExternalID]
+	if !ok {
+		movie.ID = int64(len(m.movies) + 1)
+		movie.Version = 1
+		m.movies[movie.ExternalID] = movie
+		return true, nil
+	}
+
+	if expectedVersion != nil && *expectedVersion != existing.Version {
+		return false, data.ErrEditConflict
+	}
+
+	movie.ID = existing.ID
+	movie.Version = existing.Version + 1
+	m.movies[movie.ExternalID] = movie
+
+	return false, nil
+}
+
+func TestUpsertMovie(t *testing.T) {
+	body := `{"title":"Alien","year":1979,"runtime":"117 mins","genres":["horror"]}`
+
+	tests := []struct {
+		name         string
+		ext          string
+		body         string
+		header       string
+		value        string
+		wantStatus   int
+		wantLocation string
+		wantETag     string
+	}{
+		{"create", "imdb-new", body, "", "", http.StatusCreated, "/v1/movies/2", `"2-1"`},
+		{"replace", "imdb-alien", body, "", "", http.StatusOK, "", `"1-3"`},
+		{"replace with If-Match", "imdb-alien", body, "If-Match", `"1-2"`, http.StatusOK, "", `"1-3"`},
+		{"replace with a stale If-Match", "imdb-alien", body, "If-Match", `"1-1"`, http.StatusPreconditionFailed, "", ""},
+		{"create with If-Match", "imdb-new", body, "If-Match", `"2-1"`, http.StatusPreconditionFailed, "", ""},
+		{"create only", "imdb-new", body, "If-None-Match", "*", http.StatusCreated, "/v1/movies/2", `"2-1"`},
+		{"create only, but it exists", "imdb-alien", body, "If-None-Match", "*", http.StatusPreconditionFailed, "", ""},
+		{"missing fields", "imdb-new", `{"title":"Alien"}`, "", "", http.StatusUnprocessableEntity, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movies := &upsertTestMovies{movies: map[string]*data.Movie{
+				"imdb-alien": {ID: 1, ExternalID: "imdb-alien", Title: "Alien", Year: 1979, Runtime: 116, Genres: []string{"horror"}, Version: 2},
+			}}
+
+			app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+			app.models.Movies = movies
+
+			r := httptest.NewRequest(http.MethodPut, "/v1/movies/by-external-id/"+tt.ext, strings.NewReader(tt.body))
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Header().Get("Location") != tt.wantLocation || w.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("got Location %q and ETag %s, want %q and %s", w.Header().Get("Location"), w.Header().Get("ETag"), tt.wantLocation, tt.wantETag)
+			}
+
+			//A replaced movie keeps its ID, and nothing else from the old movie
+			if tt.wantStatus == http.StatusOK {
+				if movie := movies.movies[tt.ext]; movie.ID != 1 || movie.Runtime != 117 {
+					t.Errorf("got %+v, want movie 1 with the new runtime", movie)
+				}
+			}
+		})
+	}
+}
+
+//fieldsTestMovies records the fields Get() and GetAll() are asked for.
+type fieldsTestMovies struct {
+	data.MockMovieModel
+
+	fields []string
+}
+
+func (m *fieldsTestMovies) Get(id int64, fields ...string) (*data.Movie, error) {
+	m.fields = fields
+	return &data.Movie{ID: id, Title: "Alien", Version: 1}, nil
+}
+
+func (m *fieldsTestMovies) GetAll(title string, genres []string, filters data.Filters, fields ...string) ([]*data.Movie, data.Metadata, error) {
+	m.fields = fields
+	return []*data.Movie{{ID: 1, Title: "Alien", Version: 1}}, data.Metadata{}, nil
+}
+
+func TestSparseFieldsets(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantFields []string
+		wantBody   string
+	}{
+		{"/v1/movies/1", http.StatusOK, nil, `"version":1`},
+		{"/v1/movies/1?fields=title,id", http.StatusOK, []string{"id", "title"}, `{"movie":{"id":1,"title":"Alien","_links":`},
+		{"/v1/movies?fields=title", http.StatusOK, []string{"title"}, `"movies":[{"title":"Alien","_links":`},
+		{"/v1/movies/1?fields=director", http.StatusUnprocessableEntity, nil, `"fields":"must be a comma-separated list of fields from: id, title,`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			movies := &fieldsTestMovies{}
+
+			app := &application{logger: log.New(io.Discard, "", 0), models: data.NewMockModels(), codecs: codec.NewRegistry(codec.JSON{})}
+			app.models.Movies = movies
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Fatalf("got status %d and body %s, want %d and %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+
+			//Only the selected fields are read from the database
+			if !reflect.DeepEqual(movies.fields, tt.wantFields) {
+				t.Errorf("the model was asked for %q, want %q", movies.fields, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/jsonpatch"
+)
+
+//The patch document types PATCH /v1/movies/:id accepts, in addition to plain JSON.
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+)
+
+//patchMediaTypes lists every Content-Type accepted for PATCH, for the Accept-Patch header.
+var patchMediaTypes = []string{"application/json", mergePatchMediaType, jsonPatchMediaType}
+
+//patchMovie applies an RFC 7396 JSON Merge Patch or RFC 6902 JSON Patch from the request body to the movie's JSON
+//representation, then copies the result back into the movie. It returns false if it has already sent an error response.
+//The movie's id and version are part of the document, so a JSON Patch can "test" the version, but they can't be changed.
+func (app *application) patchMovie(w http.ResponseWriter, r *http.Request, movie *data.Movie) bool {
+	patch, err := app.readBody(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return false
+	}
+
+	doc, err := json.Marshal(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if requestMediaType(r) == mergePatchMediaType {
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	} else {
+		doc, err = jsonpatch.Apply(doc, patch)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.patchTestFailedResponse(w, r)
+		case errors.Is(err, jsonpatch.ErrInvalidPatch):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.invalidPatchResponse(w, r, err)
+		}
+		return false
+	}
+
+	//Decode the patched document back into the movie's fields. Unknown members are rejected, just as readJSON() does
+	var patched struct {
+		ID         int64        `json:"id"`
+		Title      string       `json:"title"`
+		Year       int32        `json:"year"`
+		Runtime    data.Runtime `json:"runtime"`
+		Genres     []string     `json:"genres"`
+		Version    int32        `json:"version"`
+		ExternalID string       `json:"external_id"`
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(&patched)
+	if err != nil {
+		app.invalidPatchResponse(w, r, err)
+		return false
+	}
+
+	if patched.ID != movie.ID || patched.Version != movie.Version || patched.ExternalID != movie.ExternalID {
+		app.invalidPatchResponse(w, r, errors.New("the id, version and external_id fields are read-only"))
+		return false
+	}
+
+	movie.Title = patched.Title
+	movie.Year = patched.Year
+	movie.Runtime = patched.Runtime
+	movie.Genres = patched.Genres
+
+	return true
+}
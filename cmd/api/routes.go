@@ -2,23 +2,114 @@ package main
 
 import (
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
 
-func (app *application) routes() *httprouter.Router {
+//The path of every route. routes() registers them and responses build their links from them, so links always point at
+//real routes. Named parameters like ":id" are filled in by routePath().
+const (
+	pathHealthcheck       = "/v1/healthcheck"
+	pathLiveness          = "/v1/healthz/live"
+	pathReadiness         = "/v1/healthz/ready"
+	pathMovies            = "/v1/movies"
+	pathMovie             = "/v1/movies/:id"
+	pathMovieByExternal   = "/v1/movies/by-external-id/:ext"
+	pathMovieRestore      = "/v1/movies/:id/restore"
+	pathMoviePurge        = "/v1/movies/:id/purge"
+	pathMovieHistory      = "/v1/movies/:id/history"
+	pathMovieRevert       = "/v1/movies/:id/revert"
+	pathMoviesBatch       = "/v1/movies:batch"
+	pathMoviesImport      = "/v1/movies/import"
+	pathMoviesExport      = "/v1/movies/export"
+	pathMovieChanges      = "/v1/movies/changes"
+	pathGraphQL           = "/v1/graphql"
+	pathWebhooks          = "/v1/webhooks"
+	pathWebhook           = "/v1/webhooks/:id"
+	pathWebhookDeliveries = "/v1/webhooks/:id/deliveries"
+	pathWebhookRetry      = "/v1/webhooks/:id/deliveries/:delivery/retry"
+)
+
+func (app *application) routes() http.Handler {
 	router := httprouter.New() //Initialize a new httprouter router instance
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, pathHealthcheck, app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, pathLiveness, app.livenessHandler)
+	router.HandlerFunc(http.MethodGet, pathReadiness, app.readinessHandler)
+
+	router.HandlerFunc(http.MethodGet, pathMovies, app.listMoviesHandler)
+	router.HandlerFunc(http.MethodPost, pathMovies, app.idempotent(app.createMovieHandler))
+	router.HandlerFunc(http.MethodGet, pathMovie, app.showMovieHandler)
+	router.HandlerFunc(http.MethodPatch, pathMovie, app.updateMovieHandler)
+	router.HandlerFunc(http.MethodDelete, pathMovie, app.deleteMovieHandler)
+	router.HandlerFunc(http.MethodPut, pathMovieByExternal, app.upsertMovieHandler)
+	router.HandlerFunc(http.MethodPost, pathMovieRestore, app.restoreMovieHandler)
+	router.HandlerFunc(http.MethodPost, pathMoviePurge, app.requireAdmin(app.purgeMovieHandler))
+	router.HandlerFunc(http.MethodGet, pathMovieHistory, app.showMovieHistoryHandler)
+	router.HandlerFunc(http.MethodPost, pathMovieRevert, app.revertMovieHandler)
+
+	router.HandlerFunc(http.MethodPost, pathGraphQL, app.graphqlHandler)
+
+	router.HandlerFunc(http.MethodGet, pathWebhooks, app.requireAdmin(app.listWebhooksHandler))
+	router.HandlerFunc(http.MethodPost, pathWebhooks, app.requireAdmin(app.createWebhookHandler))
+	router.HandlerFunc(http.MethodGet, pathWebhook, app.requireAdmin(app.showWebhookHandler))
+	router.HandlerFunc(http.MethodPatch, pathWebhook, app.requireAdmin(app.updateWebhookHandler))
+	router.HandlerFunc(http.MethodDelete, pathWebhook, app.requireAdmin(app.deleteWebhookHandler))
+	router.HandlerFunc(http.MethodGet, pathWebhookDeliveries, app.requireAdmin(app.listWebhookDeliveriesHandler))
+	router.HandlerFunc(http.MethodPost, pathWebhookRetry, app.requireAdmin(app.retryWebhookDeliveryHandler))
+
+	//These routes clash with the named parameters above, so they are matched before the request reaches the router
+	exact := exactRoutes{}
+	exact.handle(http.MethodPost, pathMoviesBatch, app.idempotent(app.batchMoviesHandler))
+	exact.handle(http.MethodPost, pathMoviesImport, app.importMoviesHandler)
+	exact.handle(http.MethodGet, pathMoviesExport, app.exportMoviesHandler)
+	exact.handle(http.MethodGet, pathMovieChanges, app.movieChangesHandler)
+
+	//Wrap the router with the middleware that applies to every response
+	return app.compress(app.requestID(app.setVersionHeader(exact.wrap(router, app.methodNotAllowedResponse))))
+}
+
+//exactRoutes holds routes which httprouter can't register, because they clash with its named parameters. For example,
+//"/v1/movies:batch" would be read as "/v1/movies" followed by a parameter called "batch". They are matched on their
+//exact path, keyed by path and then method.
+type exactRoutes map[string]map[string]http.HandlerFunc
+
+//handle adds a route.
+func (routes exactRoutes) handle(method, path string, handler http.HandlerFunc) {
+	if routes[path] == nil {
+		routes[path] = make(map[string]http.HandlerFunc)
+	}
+	routes[path][method] = handler
+}
+
+//wrap returns a handler which serves the exact routes, and passes every other request on to next. A request for one of
+//the paths with a method it doesn't support gets a 405 Method Not Allowed response, just as the router would send.
+func (routes exactRoutes) wrap(next http.Handler, methodNotAllowed http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods, ok := routes[r.URL.Path]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		handler, ok := methods[r.Method]
+		if !ok {
+			allowed := make([]string, 0, len(methods))
+			for method := range methods {
+				allowed = append(allowed, method)
+			}
+			sort.Strings(allowed)
 
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updateMovieHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			methodNotAllowed(w, r)
+			return
+		}
 
-	return router
+		handler(w, r)
+	})
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+//serve starts the HTTP server and blocks until it has been shut down gracefully.
+//When a SIGINT or SIGTERM signal is caught, the application is marked as draining so that the readiness probe starts
+//failing, then we wait for the drain delay to give load balancers time to notice before shutting the server down.
+func (app *application) serve() error {
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	//Change streams never finish by themselves, so they are ended when shutdown starts rather than holding it up
+	srv.RegisterOnShutdown(app.changes.Close)
+
+	//The gRPC server runs alongside on its own port. Its listener is opened here so a port in use is reported straight
+	//away. It has no write timeout, as a ListMovies stream can run for as long as the client keeps reading
+	var grpcSrv *http.Server
+
+	if app.config.grpc.port != 0 {
+		tlsConfig, err := app.grpcTLSConfig()
+		if err != nil {
+			return err
+		}
+
+		grpcSrv = &http.Server{
+			Addr:              fmt.Sprintf(":%d", app.config.grpc.port),
+			Handler:           app.requestID(app.grpcServer()),
+			TLSConfig:         tlsConfig,
+			IdleTimeout:       time.Minute,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ln, err := net.Listen("tcp", grpcSrv.Addr)
+		if err != nil {
+			return err
+		}
+
+		app.logger.Printf("starting gRPC server on %s", grpcSrv.Addr)
+
+		go func() {
+			err := grpcSrv.ServeTLS(ln, "", "")
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Printf("gRPC server: %v", err)
+			}
+		}()
+	}
+
+	//The shutdownError channel receives any errors returned by the graceful Shutdown() function
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+		//Block until a signal is received
+		s := <-quit
+
+		app.logger.Printf("caught signal %s, draining for %s", s, app.config.drainDelay)
+
+		app.setDraining()
+		time.Sleep(app.config.drainDelay)
+
+		//Give in-flight requests 20 seconds to complete before the server is forcibly stopped
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		if grpcSrv != nil {
+			err = grpcSrv.Shutdown(ctx)
+			if err != nil {
+				shutdownError <- err
+				return
+			}
+		}
+
+		//Tell the background jobs to stop, and wait for them to finish
+		close(app.done)
+		app.wg.Wait()
+
+		shutdownError <- nil
+	}()
+
+	app.logger.Printf("starting %s server on %s", app.config.env, srv.Addr)
+
+	//Calling Shutdown() causes ListenAndServe() to return an http.ErrServerClosed error straight away,
+	//so any other error is a genuine problem starting the server
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.Printf("stopped server on %s", srv.Addr)
+
+	return nil
+}
+
+//setDraining marks the application as shutting down.
+func (app *application) setDraining() {
+	atomic.StoreInt32(&app.draining, 1)
+}
+
+//isDraining reports whether the application is shutting down.
+func (app *application) isDraining() bool {
+	return atomic.LoadInt32(&app.draining) == 1
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/events"
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+	"firstAPI.jweaver11.net/internal/webhook"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+//webhookDeliveryBatch is how many deliveries are sent at once.
+const webhookDeliveryBatch = 10
+
+//deliverySortSafelist lists the values the sort query string parameter can take when listing deliveries.
+var deliverySortSafelist = []string{"id", "-id"}
+
+//deliveryStatuses lists the values the status query string parameter can take when listing deliveries.
+var deliveryStatuses = []string{data.DeliveryPending, data.DeliverySucceeded, data.DeliveryDead}
+
+//Add a 'createWebhookHandler' for the "POST /v1/webhooks" endpoint. A secret is generated if one isn't given, and the
+//secret is only ever included in this response, so the caller must keep it to check the signatures.
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sub := &data.WebhookSubscription{
+		URL:    input.URL,
+		Events: input.Events,
+		Secret: input.Secret,
+		Active: true,
+	}
+
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+
+	if sub.Secret == "" {
+		sub.Secret, err = webhook.NewSecret()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+
+	if app.validateWebhookSubscription(r.Context(), v, sub); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Webhooks.Insert(sub)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", routePath(pathWebhook, sub.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"webhook": sub, "secret": sub.Secret}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//Add a 'listWebhooksHandler' for the "GET /v1/webhooks" endpoint.
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	subs, err := app.models.Webhooks.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"webhooks": subs}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//Add a 'showWebhookHandler' for the "GET /v1/webhooks/:id" endpoint.
+func (app *application) showWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"webhook": sub}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//Add an 'updateWebhookHandler' for the "PATCH /v1/webhooks/:id" endpoint. Setting "active" to false pauses a
+//subscription: new events aren't queued for it, and its pending deliveries wait until it's made active again.
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL    *string  `json:"url"`
+		Events []string `json:"events"`
+		Secret *string  `json:"secret"`
+		Active *bool    `json:"active"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.URL != nil {
+		sub.URL = *input.URL
+	}
+	if input.Events != nil {
+		sub.Events = input.Events
+	}
+	if input.Secret != nil {
+		sub.Secret = *input.Secret
+	}
+	if input.Active != nil {
+		sub.Active = *input.Active
+	}
+
+	v := validator.New()
+
+	if app.validateWebhookSubscription(r.Context(), v, sub); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Webhooks.Update(sub)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"webhook": sub}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//Add a 'deleteWebhookHandler' for the "DELETE /v1/webhooks/:id" endpoint. The subscription's deliveries go with it.
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Webhooks.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "webhook successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//Add a 'listWebhookDeliveriesHandler' for the "GET /v1/webhooks/:id/deliveries" endpoint. Each delivery includes
+//every attempt at sending it, with the receiver's response, so failures can be looked into. ?status=dead lists the
+//dead letters.
+func (app *application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	status := app.readString(qs, "status", "")
+	v.Check(status == "" || validator.In(status, deliveryStatuses...), "status", i18n.Key("validation.oneof", "pending, succeeded, dead"))
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         app.readString(qs, "sort", "-id"),
+		SortSafelist: deliverySortSafelist,
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	deliveries, metadata, err := app.models.Webhooks.GetDeliveries(sub.ID, status, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	pages := pageLinks(r, metadata)
+	if link := linkHeader(pages, paginationRels...); link != "" {
+		headers.Set("Link", link)
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"deliveries": deliveries, "_links": pages}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//Add a 'retryWebhookDeliveryHandler' for the "POST /v1/webhooks/:id/deliveries/:delivery/retry" endpoint, which sends
+//a delivery again, usually a dead letter once the receiver has been fixed.
+func (app *application) retryWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("delivery"), 10, 64)
+	if err != nil || deliveryID < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	delivery, err := app.models.Webhooks.Retry(sub.ID, deliveryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusAccepted, envelope{"delivery": delivery}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//validateWebhookSubscription validates a subscription and, unless -webhook-allow-private is set, checks that its URL
+//isn't on a private address, so that clients can't use webhooks to make the server send requests inside its network.
+func (app *application) validateWebhookSubscription(ctx context.Context, v *validator.Validator, sub *data.WebhookSubscription) {
+	if data.ValidateWebhookSubscription(v, sub); !v.Valid() || app.config.webhooks.allowPrivate {
+		return
+	}
+
+	u, _ := url.Parse(sub.URL)
+
+	err := webhook.CheckHost(ctx, u.Hostname())
+	v.Check(!errors.Is(err, webhook.ErrPrivateAddress), "url", "validation.url_private")
+}
+
+//readWebhook fetches the subscription named by the :id parameter. If it can't, it sends the error response itself
+//and returns false.
+func (app *application) readWebhook(w http.ResponseWriter, r *http.Request) (*data.WebhookSubscription, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	sub, err := app.models.Webhooks.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return sub, true
+}
+
+//enqueueWebhooks is the event relay handler which turns movie events into a delivery for each subscription which wants
+//them. The body sent has the movie under data, as it's sent for every kind of movie event.
+func (app *application) enqueueWebhooks(ctx context.Context, msg *events.Message) error {
+	var movie *data.Movie
+
+	switch e := msg.Event.(type) {
+	case events.MovieCreated:
+		movie = e.Movie
+	case events.MovieUpdated:
+		movie = e.Movie
+	case events.MovieDeleted:
+		movie = e.Movie
+	default:
+		return nil
+	}
+
+	_, err := app.models.Webhooks.Enqueue(msg.ID, msg.Event.EventType(), msg.OccurredAt, envelope{"movie": movie})
+	return err
+}
+
+//deliverWebhooks is the webhook delivery worker. Every poll interval it sends the deliveries which are due. It runs
+//until the application starts shutting down.
+func (app *application) deliverWebhooks() {
+	sender := &webhook.Sender{
+		Client:    webhook.NewClient(app.config.webhooks.timeout, app.config.webhooks.allowPrivate),
+		UserAgent: "FirstAPI-Webhooks/" + buildInfo.Version,
+	}
+
+	poll := time.NewTicker(app.config.webhooks.pollInterval)
+	defer poll.Stop()
+
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-poll.C:
+			app.runWebhookDeliveries(sender)
+
+		case <-purge.C:
+			n, err := app.models.Webhooks.PurgeDeliveriesBefore(time.Now().Add(-app.config.webhooks.retention))
+			if err != nil {
+				app.logger.Println(err)
+				continue
+			}
+
+			if n > 0 {
+				app.logger.Printf("purged %d webhook deliveries older than %s", n, app.config.webhooks.retention)
+			}
+
+		case <-app.done:
+			return
+		}
+	}
+}
+
+//runWebhookDeliveries sends due deliveries a batch at a time until there are none left or the application starts
+//shutting down.
+func (app *application) runWebhookDeliveries(sender *webhook.Sender) {
+	//A claimed delivery isn't picked up by another worker until the lease runs out, which is well after the request
+	//has timed out
+	lease := app.config.webhooks.timeout + time.Minute
+
+	for {
+		select {
+		case <-app.done:
+			return
+		default:
+		}
+
+		deliveries, err := app.models.Webhooks.ClaimDue(webhookDeliveryBatch, lease)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		var wg sync.WaitGroup
+
+		for _, d := range deliveries {
+			wg.Add(1)
+
+			go func(d *data.WebhookDelivery) {
+				defer wg.Done()
+				app.attemptWebhookDelivery(sender, d)
+			}(d)
+		}
+
+		wg.Wait()
+
+		if len(deliveries) < webhookDeliveryBatch {
+			return
+		}
+	}
+}
+
+//attemptWebhookDelivery sends a delivery and records how it went. A failed delivery is tried again after an
+//exponential backoff, until it has used up its attempts and becomes a dead letter.
+func (app *application) attemptWebhookDelivery(sender *webhook.Sender, d *data.WebhookDelivery) {
+	result := sender.Send(context.Background(), webhook.Request{
+		URL:        d.URL,
+		Secret:     d.Secret,
+		DeliveryID: strconv.FormatInt(d.ID, 10),
+		Event:      d.EventType,
+		Body:       d.Payload,
+	})
+
+	attempt := &data.WebhookAttempt{
+		Attempt:      d.Attempts + 1,
+		StatusCode:   result.StatusCode,
+		ResponseBody: result.ResponseBody,
+		DurationMS:   result.Duration.Milliseconds(),
+	}
+
+	status, next := data.DeliverySucceeded, time.Now()
+
+	if !result.OK() {
+		attempt.Error = result.Err.Error()
+
+		switch {
+		case attempt.Attempt >= app.config.webhooks.maxAttempts:
+			status = data.DeliveryDead
+			app.logger.Printf("webhook delivery %d to %s is dead after %d attempts: %v", d.ID, d.URL, attempt.Attempt, result.Err)
+		default:
+			status = data.DeliveryPending
+			next = next.Add(webhook.Backoff(attempt.Attempt, app.config.webhooks.backoffBase, app.config.webhooks.backoffMax))
+		}
+	}
+
+	err := app.models.Webhooks.RecordAttempt(d.ID, attempt, status, next)
+	if err != nil {
+		app.logger.Println(err)
+	}
+}
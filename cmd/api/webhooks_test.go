@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/codec"
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/webhook"
+)
+
+//recordingWebhooks hands out the deliveries in due, and records every attempt at them.
+type recordingWebhooks struct {
+	data.MockWebhookModel
+
+	due      []*data.WebhookDelivery
+	attempts []recordedAttempt
+}
+
+type recordedAttempt struct {
+	attempt *data.WebhookAttempt
+	status  string
+	next    time.Time
+}
+
+func (m *recordingWebhooks) ClaimDue(limit int, lease time.Duration) ([]*data.WebhookDelivery, error) {
+	due := m.due
+	m.due = nil
+	return due, nil
+}
+
+func (m *recordingWebhooks) RecordAttempt(deliveryID int64, attempt *data.WebhookAttempt, status string, next time.Time) error {
+	m.attempts = append(m.attempts, recordedAttempt{attempt, status, next})
+	return nil
+}
+
+func TestWebhookDeliveryAttempts(t *testing.T) {
+	secret := "whsec_0123456789abcdef"
+	fail := true
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if err := webhook.Verify(secret, r.Header.Get(webhook.HeaderSignature), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("receiver couldn't verify the signature: %v", err)
+		}
+
+		if fail {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	webhooks := &recordingWebhooks{}
+
+	models := data.NewMockModels()
+	models.Webhooks = webhooks
+
+	app := &application{logger: log.New(io.Discard, "", 0), models: models, done: make(chan struct{})}
+	app.config.webhooks.timeout = time.Second
+	app.config.webhooks.maxAttempts = 3
+	app.config.webhooks.backoffBase = time.Minute
+	app.config.webhooks.backoffMax = time.Hour
+
+	sender := &webhook.Sender{Client: webhook.NewClient(time.Second, true), UserAgent: "test"}
+
+	deliver := func(attempts int) recordedAttempt {
+		t.Helper()
+
+		webhooks.due = []*data.WebhookDelivery{{
+			ID:        7,
+			EventType: data.WebhookMovieCreated,
+			Payload:   []byte(`{"movie":{"id":1}}`),
+			Attempts:  attempts,
+			URL:       receiver.URL,
+			Secret:    secret,
+		}}
+		app.runWebhookDeliveries(sender)
+
+		if len(webhooks.attempts) == 0 {
+			t.Fatal("no attempt was recorded")
+		}
+		return webhooks.attempts[len(webhooks.attempts)-1]
+	}
+
+	first := deliver(0)
+	if first.status != data.DeliveryPending || first.attempt.Attempt != 1 || first.attempt.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("first failure recorded %+v with status %s, want a pending retry", *first.attempt, first.status)
+	}
+	if first.attempt.ResponseBody != "try later\n" || first.attempt.Error == "" {
+		t.Errorf("got response %q and error %q, want both kept", first.attempt.ResponseBody, first.attempt.Error)
+	}
+	if wait := time.Until(first.next); wait < 47*time.Second || wait > 73*time.Second {
+		t.Errorf("retrying in %s, want about a minute", wait)
+	}
+
+	last := deliver(2)
+	if last.status != data.DeliveryDead || last.attempt.Attempt != 3 {
+		t.Errorf("last failure recorded attempt %d with status %s, want a dead letter", last.attempt.Attempt, last.status)
+	}
+
+	fail = false
+
+	success := deliver(1)
+	if success.status != data.DeliverySucceeded || success.attempt.StatusCode != http.StatusOK || success.attempt.Error != "" {
+		t.Errorf("success recorded %+v with status %s", *success.attempt, success.status)
+	}
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	models := data.NewMockModels()
+
+	app := &application{logger: log.New(io.Discard, "", 0), models: models, codecs: codec.NewRegistry(codec.JSON{})}
+	app.config.adminToken = "admin-token"
+
+	routes := app.routes()
+
+	create := func(url, token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/v1/webhooks", strings.NewReader(`{"url":"`+url+`","events":["movie.created"]}`))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	tests := []struct {
+		name  string
+		url   string
+		token string
+		want  int
+	}{
+		{"not an administrator", "https://93.184.216.34/hook", "", http.StatusForbidden},
+		{"wrong token", "https://93.184.216.34/hook", "guess", http.StatusForbidden},
+		{"public address", "https://93.184.216.34/hook", "admin-token", http.StatusCreated},
+		{"loopback", "http://127.0.0.1:8080/hook", "admin-token", http.StatusUnprocessableEntity},
+		{"localhost", "http://localhost/hook", "admin-token", http.StatusUnprocessableEntity},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data", "admin-token", http.StatusUnprocessableEntity},
+		{"private network", "https://10.0.0.5/hook", "admin-token", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := create(tt.url, tt.token); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	app.config.webhooks.allowPrivate = true
+
+	if got := create("http://127.0.0.1:8080/hook", "admin-token"); got != http.StatusCreated {
+		t.Errorf("with -webhook-allow-private, a loopback receiver got %d, want %d", got, http.StatusCreated)
+	}
+}
@@ -0,0 +1,44 @@
+//Package accept parses the headers clients use to say which representations they prefer, like Accept,
+//Accept-Language and Accept-Encoding, so that their q-values are read the same way whichever header they are in.
+package accept
+
+import (
+	"strconv"
+	"strings"
+)
+
+//Preference is one element of an Accept-style header, such as "text/html;q=0.8". Value is lower-cased, as media
+//types, language tags and content codings are all case-insensitive.
+type Preference struct {
+	Value string
+	Q     float64
+}
+
+//Parse splits a header into its preferences, in the order the client listed them. Elements without a valid q
+//parameter, between 0 and 1, have a q-value of 1, and empty elements are left out.
+func Parse(header string) []Preference {
+	var prefs []Preference
+
+	for _, element := range strings.Split(header, ",") {
+		params := strings.Split(element, ";")
+		pref := Preference{Value: strings.ToLower(strings.TrimSpace(params[0])), Q: 1}
+
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err == nil && q >= 0 && q <= 1 {
+				pref.Q = q
+			}
+		}
+
+		if pref.Value != "" {
+			prefs = append(prefs, pref)
+		}
+	}
+
+	return prefs
+}
@@ -0,0 +1,32 @@
+package accept
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []Preference
+	}{
+		{"", nil},
+		{" , ,", nil},
+		{"application/json", []Preference{{"application/json", 1}}},
+		{"de-CH, de;q=0.9, en;q=0.5", []Preference{{"de-ch", 1}, {"de", 0.9}, {"en", 0.5}}},
+		{"text/html;level=1;q=0.2", []Preference{{"text/html", 0.2}}},
+		{"gzip; Q = 0.3", []Preference{{"gzip", 0.3}}},
+		{"GZIP;q=0", []Preference{{"gzip", 0}}},
+		{"gzip;q=2, br;q=-1, deflate;q=abc", []Preference{{"gzip", 1}, {"br", 1}, {"deflate", 1}}},
+		{"*/*;q", []Preference{{"*/*", 1}}},
+		{";q=0.5, *", []Preference{{"*", 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := Parse(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
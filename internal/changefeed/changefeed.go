@@ -0,0 +1,152 @@
+//Package changefeed fans events out to in-process subscribers, and keeps a bounded log of recent events so a
+//subscriber which reconnects can catch up on the ones it missed, as Server-Sent Events clients do with Last-Event-ID.
+package changefeed
+
+import "sync"
+
+//Event is one change. ID must be unique, and events must be published in the order they happened.
+type Event struct {
+	ID   string
+	Type string
+	Data interface{}
+}
+
+//Broker publishes events to subscribers. It's safe for concurrent use.
+type Broker struct {
+	mu     sync.Mutex
+	log    []Event //ring buffer of the most recent events
+	next   int     //where the next event goes in log
+	full   bool    //whether log has wrapped around
+	subs   map[*Subscription]struct{}
+	buffer int
+	closed bool
+}
+
+//New returns a broker which keeps the last logSize events for replay. Each subscriber can fall up to buffer events
+//behind before it's dropped.
+func New(logSize, buffer int) *Broker {
+	if logSize < 1 {
+		logSize = 1
+	}
+
+	return &Broker{
+		log:    make([]Event, logSize),
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+//Subscription receives the events published after it was made. Its channel is closed if the subscriber falls too far
+//behind, or the broker is reset or closed, and the subscriber should then subscribe again from the last event it saw.
+type Subscription struct {
+	C <-chan Event
+
+	c chan Event
+}
+
+//Subscribe starts a subscription. If lastEventID is set, the events after it in the log are returned for replay. The
+//returned bool is false if lastEventID isn't in the log any more, so events may have been missed and the subscriber
+//should start afresh, for example by fetching everything again. The whole log is returned for replay in that case, so
+//that nothing is missed between the subscriber starting afresh and the next event.
+func (b *Broker) Subscribe(lastEventID string) (*Subscription, []Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Event, b.buffer)
+	sub := &Subscription{C: c, c: c}
+
+	if b.closed {
+		close(c)
+	} else {
+		b.subs[sub] = struct{}{}
+	}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+
+	events := b.events()
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].ID == lastEventID {
+			return sub, events[i+1:], true
+		}
+	}
+
+	return sub, events, false
+}
+
+//Unsubscribe ends a subscription.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+//Publish adds an event to the log and sends it to every subscriber. A subscriber whose buffer is full is dropped
+//rather than holding up the others, and can catch up from the log when it subscribes again.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.log[b.next] = e
+	b.next = (b.next + 1) % len(b.log)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- e:
+		default:
+			delete(b.subs, sub)
+			close(sub.c)
+		}
+	}
+}
+
+//Reset empties the log and drops every subscriber. It's used when events may have been missed, so that no subscriber
+//carries on as if it has seen everything.
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.next, b.full = 0, false
+	for i := range b.log {
+		b.log[i] = Event{}
+	}
+
+	b.dropAll()
+}
+
+//Close drops every subscriber, and closes any later subscriptions straight away. It's called when the server shuts
+//down, so that long-lived streams end.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.dropAll()
+}
+
+func (b *Broker) dropAll() {
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+//events returns the log, oldest first.
+func (b *Broker) events() []Event {
+	if !b.full {
+		return append([]Event(nil), b.log[:b.next]...)
+	}
+	return append(append([]Event(nil), b.log[b.next:]...), b.log[:b.next]...)
+}
@@ -0,0 +1,212 @@
+package changefeed
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//publish publishes events with the IDs first to last.
+func publish(b *Broker, first, last int) {
+	for i := first; i <= last; i++ {
+		b.Publish(Event{ID: strconv.Itoa(i), Type: "updated"})
+	}
+}
+
+//ids returns the IDs of events, joined with commas.
+func ids(events []Event) string {
+	s := make([]string, len(events))
+	for i, e := range events {
+		s[i] = e.ID
+	}
+	return strings.Join(s, ",")
+}
+
+//drain returns the events waiting on a subscription, and whether its channel has been closed.
+func drain(sub *Subscription) ([]Event, bool) {
+	var events []Event
+
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return events, true
+			}
+			events = append(events, e)
+		default:
+			return events, false
+		}
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	b := New(3, 10)
+	publish(b, 1, 5)
+
+	tests := []struct {
+		lastEventID string
+		want        string
+		ok          bool
+	}{
+		{"", "", true},
+		{"5", "", true},
+		{"4", "5", true},
+		{"3", "4,5", true},
+		{"2", "3,4,5", false},
+		{"unknown", "3,4,5", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lastEventID, func(t *testing.T) {
+			sub, replay, ok := b.Subscribe(tt.lastEventID)
+			defer b.Unsubscribe(sub)
+
+			if ids(replay) != tt.want || ok != tt.ok {
+				t.Errorf("got %q and %t, want %q and %t", ids(replay), ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSubscribeBeforeLogFills(t *testing.T) {
+	b := New(5, 10)
+	publish(b, 1, 2)
+
+	sub, replay, ok := b.Subscribe("1")
+	defer b.Unsubscribe(sub)
+
+	if ids(replay) != "2" || !ok {
+		t.Errorf("got %q and %t, want \"2\" and true", ids(replay), ok)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	b := New(10, 10)
+
+	first, _, _ := b.Subscribe("")
+	second, _, _ := b.Subscribe("")
+	publish(b, 1, 3)
+
+	for _, sub := range []*Subscription{first, second} {
+		events, closed := drain(sub)
+		if ids(events) != "1,2,3" || closed {
+			t.Errorf("got %q and closed %t, want \"1,2,3\" and open", ids(events), closed)
+		}
+	}
+
+	b.Unsubscribe(first)
+	publish(b, 4, 4)
+
+	if events, closed := drain(first); len(events) != 0 || !closed {
+		t.Errorf("after unsubscribing, got %q and closed %t", ids(events), closed)
+	}
+	if events, _ := drain(second); ids(events) != "4" {
+		t.Errorf("got %q, want \"4\"", ids(events))
+	}
+
+	//Unsubscribing twice does nothing
+	b.Unsubscribe(first)
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := New(10, 2)
+
+	slow, _, _ := b.Subscribe("")
+	fast, _, _ := b.Subscribe("")
+
+	publish(b, 1, 2)
+	drain(fast)
+	publish(b, 3, 3)
+
+	events, closed := drain(slow)
+	if ids(events) != "1,2" || !closed {
+		t.Errorf("got %q and closed %t, want \"1,2\" and closed", ids(events), closed)
+	}
+
+	events, closed = drain(fast)
+	if ids(events) != "3" || closed {
+		t.Errorf("got %q and closed %t, want \"3\" and open", ids(events), closed)
+	}
+
+	//The dropped subscriber catches up from the log
+	_, replay, ok := b.Subscribe("2")
+	if ids(replay) != "3" || !ok {
+		t.Errorf("got %q and %t, want \"3\" and true", ids(replay), ok)
+	}
+}
+
+func TestReset(t *testing.T) {
+	b := New(3, 10)
+	publish(b, 1, 4)
+
+	sub, _, _ := b.Subscribe("")
+	b.Reset()
+
+	if _, closed := drain(sub); !closed {
+		t.Error("the subscription wasn't closed")
+	}
+
+	_, replay, ok := b.Subscribe("4")
+	if len(replay) != 0 || ok {
+		t.Errorf("got %q and %t, want nothing and false", ids(replay), ok)
+	}
+
+	publish(b, 5, 5)
+
+	_, replay, ok = b.Subscribe("")
+	if len(replay) != 0 || !ok {
+		t.Errorf("got %q and %t", ids(replay), ok)
+	}
+	if _, replay, _ = b.Subscribe("unknown"); ids(replay) != "5" {
+		t.Errorf("got %q, want \"5\"", ids(replay))
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := New(3, 10)
+
+	sub, _, _ := b.Subscribe("")
+	b.Close()
+
+	if _, closed := drain(sub); !closed {
+		t.Error("the subscription wasn't closed")
+	}
+
+	later, _, _ := b.Subscribe("")
+	publish(b, 1, 1)
+
+	if events, closed := drain(later); len(events) != 0 || !closed {
+		t.Errorf("a subscription after Close got %q and closed %t", ids(events), closed)
+	}
+
+	//Unsubscribing after Close doesn't close the channel again
+	b.Unsubscribe(sub)
+	b.Unsubscribe(later)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	b := New(16, 1000)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			publish(b, i*100, i*100+99)
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				sub, _, _ := b.Subscribe("")
+				b.Unsubscribe(sub)
+			}
+		}()
+	}
+
+	wg.Wait()
+	b.Close()
+}
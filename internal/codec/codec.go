@@ -0,0 +1,108 @@
+//Package codec encodes response bodies in the formats clients can ask for with the Accept header. Every format is
+//derived from the value's JSON encoding, so field names, omitted fields and custom MarshalJSON methods (like
+//data.Runtime's) come out the same whichever format is used.
+package codec
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/accept"
+)
+
+//ErrUnsupported is returned by Encode() when a value can't be represented in the codec's format, such as a single
+//movie as CSV.
+var ErrUnsupported = errors.New("value not supported by this format")
+
+//Codec encodes values in one format.
+type Codec interface {
+	//Name is a short name for the format, used to tell its ETags apart from other formats'
+	Name() string
+
+	//ContentType is sent as the Content-Type of responses in this format
+	ContentType() string
+
+	//MediaTypes are the media types in an Accept header which select this format
+	MediaTypes() []string
+
+	//Encode writes v to w. If pretty is true, text formats are indented to make them easier for people to read
+	Encode(w io.Writer, v interface{}, pretty bool) error
+}
+
+//Registry holds the codecs a server supports, in order of preference. The first codec is the default.
+type Registry struct {
+	codecs []Codec
+}
+
+//NewRegistry returns a registry of the given codecs.
+func NewRegistry(codecs ...Codec) *Registry {
+	return &Registry{codecs: codecs}
+}
+
+//Register adds a codec, with the lowest preference so far.
+func (reg *Registry) Register(c Codec) {
+	reg.codecs = append(reg.codecs, c)
+}
+
+//Default returns the default codec.
+func (reg *Registry) Default() Codec {
+	return reg.codecs[0]
+}
+
+//MediaTypes returns the media types of every codec, for telling clients what they can ask for.
+func (reg *Registry) MediaTypes() []string {
+	var mediaTypes []string
+	for _, c := range reg.codecs {
+		mediaTypes = append(mediaTypes, c.MediaTypes()...)
+	}
+	return mediaTypes
+}
+
+//Negotiate picks the codec which best matches an Accept header. Each codec gets the q-value of the most specific media
+//range matching it, and the one with the highest q-value wins, with ties going to the codec registered first. An empty
+//header gets the default codec, and it returns false if the header doesn't accept any of them.
+func (reg *Registry) Negotiate(header string) (Codec, bool) {
+	if strings.TrimSpace(header) == "" {
+		return reg.Default(), true
+	}
+
+	ranges := accept.Parse(header)
+
+	var best Codec
+	bestQ := 0.0
+
+	for _, c := range reg.codecs {
+		q := 0.0
+		specificity := -1
+
+		for _, mediaType := range c.MediaTypes() {
+			for _, rng := range ranges {
+				if s := matchMediaRange(rng.Value, mediaType); s > specificity {
+					specificity, q = s, rng.Q
+				}
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+
+	return best, best != nil
+}
+
+//matchMediaRange returns how specifically a media range from an Accept header matches a media type: 2 for an exact
+//match, 1 for "type/*", 0 for "*/*" and -1 if it doesn't match at all.
+func matchMediaRange(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	case mediaRange == "*/*":
+		return 0
+	default:
+		return -1
+	}
+}
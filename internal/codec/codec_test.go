@@ -0,0 +1,219 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	reg := NewRegistry(JSON{}, XML{}, CSV{}, MessagePack{})
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "json"},
+		{"application/json", "json"},
+		{"APPLICATION/XML", "xml"},
+		{"text/xml", "xml"},
+		{"application/x-msgpack", "msgpack"},
+		{"*/*", "json"},
+		{"text/*", "xml"},
+		{"text/csv, application/json;q=0.5", "csv"},
+		{"application/json;q=0.1, */*;q=0.9", "xml"},
+		{"application/msgpack;q=0.8, text/csv;q=0.8", "csv"},
+		{"application/json;q=nonsense", "json"},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", "xml"},
+		{"image/png", ""},
+		{"application/json;q=0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			c, ok := reg.Negotiate(tt.accept)
+
+			got := ""
+			if ok {
+				got = c.Name()
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(JSON{})
+	reg.Register(CSV{})
+
+	if reg.Default().Name() != "json" {
+		t.Errorf("got default %q, want json", reg.Default().Name())
+	}
+
+	if got := strings.Join(reg.MediaTypes(), ","); got != "application/json,text/csv" {
+		t.Errorf("got media types %q", got)
+	}
+}
+
+//testMovie has the shape of a movie response, with fields that are omitted when empty and links nested in objects.
+type testMovie struct {
+	ID     int64                        `json:"id"`
+	Title  string                       `json:"title"`
+	Year   int32                        `json:"year,omitempty"`
+	Genres []string                     `json:"genres"`
+	Sequel *int64                       `json:"sequel"`
+	Links  map[string]map[string]string `json:"_links,omitempty"`
+}
+
+func TestJSON(t *testing.T) {
+	v := map[string]interface{}{"movie": testMovie{ID: 1, Title: "Alien", Genres: []string{"horror"}}}
+
+	var compact, pretty bytes.Buffer
+
+	if err := (JSON{}).Encode(&compact, v, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := (JSON{}).Encode(&pretty, v, true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"movie":{"id":1,"title":"Alien","genres":["horror"],"sequel":null}}` + "\n"
+	if compact.String() != want {
+		t.Errorf("got %s, want %s", compact.String(), want)
+	}
+
+	if !strings.HasPrefix(pretty.String(), "{\n\t\"movie\": {\n\t\t\"id\": 1,") || !strings.HasSuffix(pretty.String(), "}\n") {
+		t.Errorf("got pretty output %s", pretty.String())
+	}
+}
+
+func TestXML(t *testing.T) {
+	v := map[string]interface{}{
+		"movie": testMovie{ID: 1, Title: "Alien & Aliens", Genres: []string{"horror", "sci-fi"}},
+		"data":  []int{1},
+		"1st":   true,
+	}
+
+	var buf bytes.Buffer
+	if err := (XML{}).Encode(&buf, v, false); err != nil {
+		t.Fatal(err)
+	}
+
+	//Map keys are encoded in sorted order
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<response><_st>true</_st><data><item>1</item></data>` +
+		`<movie><id>1</id><title>Alien &amp; Aliens</title><genres><genre>horror</genre><genre>sci-fi</genre></genres>` +
+		`<sequel nil="true"></sequel></movie></response>` + "\n"
+
+	if buf.String() != want {
+		t.Errorf("got %s\nwant %s", buf.String(), want)
+	}
+}
+
+func TestXMLName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"title", "title"},
+		{"_links", "_links"},
+		{"x-1.y", "x-1.y"},
+		{"9lives", "_lives"},
+		{"a b", "a_b"},
+		{"", "_"},
+	}
+
+	for _, tt := range tests {
+		if got := xmlName(tt.key); got != tt.want {
+			t.Errorf("xmlName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	v := map[string]interface{}{
+		"metadata": map[string]int{"total_records": 2},
+		"movies": []testMovie{
+			{ID: 1, Title: "Alien", Genres: []string{"horror", "sci-fi"}},
+			{ID: 2, Title: "Heat, the movie", Year: 1995, Genres: []string{}, Links: map[string]map[string]string{"self": {"href": "/v1/movies/2"}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := (CSV{}).Encode(&buf, v, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id,title,genres,sequel,year,_links.self.href\n" +
+		"1,Alien,horror|sci-fi,,,\n" +
+		"2,\"Heat, the movie\",,,1995,/v1/movies/2\n"
+
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestCSVUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"single movie", map[string]interface{}{"movie": testMovie{ID: 1}}},
+		{"not an object", []testMovie{{ID: 1}}},
+		{"two lists", map[string]interface{}{"movies": []testMovie{}, "errors": []string{}}},
+		{"list of scalars", map[string]interface{}{"ids": []int{1, 2}}},
+		{"nested list of objects", map[string]interface{}{"movies": []map[string]interface{}{{"cast": []map[string]string{{"name": "Ripley"}}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (CSV{}).Encode(&bytes.Buffer{}, tt.v, false); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("got %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
+
+func TestMessagePack(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{"nil", nil, []byte{0xc0}},
+		{"true", true, []byte{0xc3}},
+		{"false", false, []byte{0xc2}},
+		{"positive fixint", 127, []byte{0x7f}},
+		{"negative fixint", -32, []byte{0xe0}},
+		{"int8", -100, []byte{0xd0, 0x9c}},
+		{"int16", 300, []byte{0xd1, 0x01, 0x2c}},
+		{"int32", -40000, []byte{0xd2, 0xff, 0xff, 0x63, 0xc0}},
+		{"int64", int64(1) << 40, []byte{0xd3, 0, 0, 0x01, 0, 0, 0, 0, 0}},
+		{"float", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"fixstr", "hi", []byte{0xa2, 'h', 'i'}},
+		{"str8", strings.Repeat("a", 40), append([]byte{0xd9, 40}, strings.Repeat("a", 40)...)},
+		{"fixarray", []interface{}{1, "a"}, []byte{0x92, 0x01, 0xa1, 'a'}},
+		{"array16", make([]bool, 16), append([]byte{0xdc, 0, 16}, bytes.Repeat([]byte{0xc2}, 16)...)},
+		{"fixmap", struct {
+			B int    `json:"b"`
+			A string `json:"a"`
+		}{1, "x"}, []byte{0x82, 0xa1, 'b', 0x01, 0xa1, 'a', 0xa1, 'x'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (MessagePack{}).Encode(&buf, tt.v, false); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Errorf("got % x, want % x", buf.Bytes(), tt.want)
+			}
+		})
+	}
+}
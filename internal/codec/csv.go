@@ -0,0 +1,135 @@
+package codec
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+//CSV encodes lists as CSV with a header row. It only supports an envelope with exactly one member holding an array of
+//objects, like {"movies": [...]}, and returns ErrUnsupported for anything else. Other members, like links, have no
+//place in CSV and are left out. The columns are the objects' keys, in the order they first appear, and nested objects
+//are flattened into columns with dotted names, like "_links.self.href". Arrays of scalars, like genres, are joined
+//with "|" in one cell.
+type CSV struct{}
+
+func (CSV) Name() string         { return "csv" }
+func (CSV) ContentType() string  { return "text/csv; charset=utf-8" }
+func (CSV) MediaTypes() []string { return []string{"text/csv"} }
+
+//Encode writes the list in v as CSV. Rows are already one per line, so pretty has no effect.
+func (CSV) Encode(w io.Writer, v interface{}, pretty bool) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+
+	envelope, ok := tree.(*object)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	var items []interface{}
+	lists := 0
+
+	for _, value := range envelope.values {
+		if arr, ok := value.([]interface{}); ok {
+			items = arr
+			lists++
+		}
+	}
+
+	if lists != 1 {
+		return ErrUnsupported
+	}
+
+	//Collect the columns from every row, as fields which are omitted when empty may only appear in some of them
+	var columns []string
+	index := make(map[string]int)
+
+	rows := make([]*object, 0, len(items))
+
+	for _, item := range items {
+		obj, ok := item.(*object)
+		if !ok {
+			return ErrUnsupported
+		}
+
+		row := flatten("", obj, &object{})
+
+		for _, key := range row.keys {
+			if _, seen := index[key]; !seen {
+				index[key] = len(columns)
+				columns = append(columns, key)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	cw := csv.NewWriter(w)
+
+	if len(columns) > 0 {
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		record := make([]string, len(columns))
+
+		for i, key := range row.keys {
+			cell, ok := csvCell(row.values[i])
+			if !ok {
+				return ErrUnsupported
+			}
+			record[index[key]] = cell
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+//flatten adds the members of obj to row, replacing nested objects with their members under dotted names.
+func flatten(prefix string, obj *object, row *object) *object {
+	for i, key := range obj.keys {
+		if nested, ok := obj.values[i].(*object); ok {
+			flatten(prefix+key+".", nested, row)
+			continue
+		}
+
+		row.keys = append(row.keys, prefix+key)
+		row.values = append(row.values, obj.values[i])
+	}
+
+	return row
+}
+
+//csvCell returns a tree value as a CSV cell. Scalars are written as they are and arrays of scalars are joined with
+//"|", but arrays holding objects or other arrays can't be, so it returns false for them.
+func csvCell(v interface{}) (string, bool) {
+	if s, ok := scalarString(v); ok {
+		return s, true
+	}
+
+	arr, ok := v.([]interface{})
+	if !ok {
+		return "", false
+	}
+
+	parts := make([]string, 0, len(arr))
+	for _, item := range arr {
+		s, ok := scalarString(item)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, s)
+	}
+
+	return strings.Join(parts, "|"), true
+}
@@ -0,0 +1,32 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+//JSON encodes values as JSON.
+type JSON struct{}
+
+func (JSON) Name() string         { return "json" }
+func (JSON) ContentType() string  { return "application/json" }
+func (JSON) MediaTypes() []string { return []string{"application/json"} }
+
+//Encode writes v as JSON, indented with tabs if pretty is true, followed by a newline to make it easier to view in
+//terminal applications.
+func (JSON) Encode(w io.Writer, v interface{}, pretty bool) error {
+	var js []byte
+	var err error
+
+	if pretty {
+		js, err = json.MarshalIndent(v, "", "\t")
+	} else {
+		js, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(js, '\n'))
+	return err
+}
@@ -0,0 +1,132 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+//MessagePack encodes values in the MessagePack binary format (https://msgpack.org). Objects become maps, and numbers
+//become integers if they are whole and floats otherwise.
+type MessagePack struct{}
+
+func (MessagePack) Name() string        { return "msgpack" }
+func (MessagePack) ContentType() string { return "application/msgpack" }
+func (MessagePack) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+//Encode writes v as MessagePack. It's a binary format, so pretty has no effect.
+func (MessagePack) Encode(w io.Writer, v interface{}, pretty bool) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	err = encodeMsgpack(bw, tree)
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+//encodeMsgpack writes a tree value, using the smallest MessagePack type which can hold it.
+func encodeMsgpack(w *bufio.Writer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+
+	case bool:
+		if v {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return writeMsgpackInt(w, n)
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+
+		w.WriteByte(0xcb)
+		return binary.Write(w, binary.BigEndian, math.Float64bits(f))
+
+	case string:
+		writeMsgpackHeader(w, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		_, err := w.WriteString(v)
+		return err
+
+	case []interface{}:
+		writeMsgpackHeader(w, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := encodeMsgpack(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *object:
+		writeMsgpackHeader(w, len(v.keys), 0x80, 16, 0, 0xde, 0xdf)
+		for i, key := range v.keys {
+			if err := encodeMsgpack(w, key); err != nil {
+				return err
+			}
+			if err := encodeMsgpack(w, v.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("cannot encode %T as MessagePack", v)
+}
+
+//writeMsgpackInt writes a signed integer.
+func writeMsgpackInt(w *bufio.Writer, n int64) error {
+	switch {
+	case n >= 0 && n <= 127:
+		return w.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		return w.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		w.WriteByte(0xd0)
+		return w.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		w.WriteByte(0xd1)
+		return binary.Write(w, binary.BigEndian, int16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		w.WriteByte(0xd2)
+		return binary.Write(w, binary.BigEndian, int32(n))
+	default:
+		w.WriteByte(0xd3)
+		return binary.Write(w, binary.BigEndian, n)
+	}
+}
+
+//writeMsgpackHeader writes the type and length for a string, array or map. Lengths below fixLimit fit in the fix
+//type's byte, and longer ones use the 8, 16 or 32-bit type. Arrays and maps have no 8-bit type, so pass 0 for it.
+func writeMsgpackHeader(w *bufio.Writer, n int, fix byte, fixLimit int, type8, type16, type32 byte) {
+	switch {
+	case n < fixLimit:
+		w.WriteByte(fix | byte(n))
+	case type8 != 0 && n <= math.MaxUint8:
+		w.WriteByte(type8)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(type16)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(type32)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+//object is a JSON object which keeps its keys in the order they were encoded.
+type object struct {
+	keys   []string
+	values []interface{}
+}
+
+//toTree converts v into plain values by encoding it as JSON and decoding it again. The result is made up of nil,
+//bool, json.Number, string, []interface{} and *object values.
+func toTree(v interface{}) (interface{}, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	return decodeValue(dec)
+}
+
+//decodeValue reads the next value from the decoder.
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := &object{}
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			obj.keys = append(obj.keys, key.(string))
+			obj.values = append(obj.values, value)
+		}
+
+		_, err = dec.Token()
+		return obj, err
+
+	case '[':
+		arr := []interface{}{}
+
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, value)
+		}
+
+		_, err = dec.Token()
+		return arr, err
+	}
+
+	return nil, fmt.Errorf("unexpected delimiter %q", delim)
+}
+
+//scalarString returns a scalar tree value as a string, and false for arrays and objects.
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case bool:
+		return fmt.Sprint(v), true
+	case json.Number:
+		return v.String(), true
+	case string:
+		return v, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+//XML encodes values as XML, inside a <response> root element. Object members become elements named after their keys,
+//and each array element is named after the array in the singular ("genres" holds <genre> elements), or <item> if the
+//name doesn't end in "s". Nulls are empty elements with a nil="true" attribute.
+type XML struct{}
+
+func (XML) Name() string         { return "xml" }
+func (XML) ContentType() string  { return "application/xml; charset=utf-8" }
+func (XML) MediaTypes() []string { return []string{"application/xml", "text/xml"} }
+
+//Encode writes v as XML, indented with tabs if pretty is true.
+func (XML) Encode(w io.Writer, v interface{}, pretty bool) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	if pretty {
+		enc.Indent("", "\t")
+	}
+
+	err = encodeXML(enc, "response", tree)
+	if err != nil {
+		return err
+	}
+
+	err = enc.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+//encodeXML writes a tree value as an element with the given name.
+func encodeXML(enc *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+
+	if v == nil {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "nil"}, Value: "true"}}
+	}
+
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case *object:
+		for i, key := range v.keys {
+			if err := encodeXML(enc, key, v.values[i]); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		itemName := "item"
+		if len(name) > 1 && strings.HasSuffix(name, "s") {
+			itemName = strings.TrimSuffix(name, "s")
+		}
+
+		for _, item := range v {
+			if err := encodeXML(enc, itemName, item); err != nil {
+				return err
+			}
+		}
+
+	default:
+		s, _ := scalarString(v)
+		if s != "" {
+			if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+//xmlName makes a key safe to use as an element name, replacing any characters which aren't allowed with underscores.
+func xmlName(key string) string {
+	var b strings.Builder
+
+	for i, r := range key {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
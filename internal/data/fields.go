@@ -0,0 +1,113 @@
+package data
+
+import (
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+
+	"github.com/lib/pq"
+)
+
+//MovieFields lists the fields of a movie which clients can select with the "fields" parameter, by their JSON names,
+//in the order they are written.
+var MovieFields = []string{"id", "title", "year", "runtime", "genres", "version", "external_id"}
+
+//ValidateFields checks that every selected field is one of MovieFields.
+func ValidateFields(v *validator.Validator, fields []string) {
+	for _, field := range fields {
+		if !validator.In(field, MovieFields...) {
+			v.AddError("fields", i18n.Key("validation.fields", strings.Join(MovieFields, ", ")))
+			return
+		}
+	}
+}
+
+//SelectFields returns the selected fields in the order of MovieFields, without duplicates, so that the same selection
+//always comes out the same. No fields means every field, and it returns nil.
+func SelectFields(fields []string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var selected []string
+	for _, field := range MovieFields {
+		if validator.In(field, fields...) {
+			selected = append(selected, field)
+		}
+	}
+
+	return selected
+}
+
+//movieColumns are the columns read for a movie, in the order scanMovie() expects them. They are keyed by JSON name,
+//except created_at, which isn't part of a movie's JSON.
+var movieColumns = []struct {
+	field  string
+	column string
+}{
+	{"id", "id"},
+	{"created_at", "created_at"},
+	{"title", "title"},
+	{"year", "year"},
+	{"runtime", "runtime"},
+	{"genres", "genres"},
+	{"version", "version"},
+	{"external_id", "COALESCE(external_id, '')"},
+}
+
+//movieSelection is the set of fields a query reads for each movie. The id, created_at and version are always read,
+//as the ID and version are needed for ETags, so a query only ever narrows the other columns.
+type movieSelection []string
+
+//selectMovie returns the selection for the given fields, where no fields means every field.
+func selectMovie(fields []string) movieSelection {
+	var sel movieSelection
+
+	for _, c := range movieColumns {
+		switch {
+		case len(fields) == 0, c.field == "id", c.field == "created_at", c.field == "version":
+			sel = append(sel, c.field)
+		case validator.In(c.field, fields...):
+			sel = append(sel, c.field)
+		}
+	}
+
+	return sel
+}
+
+//columns returns the SELECT list for the selection.
+func (sel movieSelection) columns() string {
+	var columns []string
+
+	for _, c := range movieColumns {
+		if validator.In(c.field, sel...) {
+			columns = append(columns, c.column)
+		}
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+//dest returns the scan destinations in movie for the selection's columns, in the same order as columns().
+func (sel movieSelection) dest(movie *Movie) []interface{} {
+	targets := map[string]interface{}{
+		"id":          &movie.ID,
+		"created_at":  &movie.CreatedAt,
+		"title":       &movie.Title,
+		"year":        &movie.Year,
+		"runtime":     &movie.Runtime,
+		"genres":      pq.Array(&movie.Genres),
+		"version":     &movie.Version,
+		"external_id": &movie.ExternalID,
+	}
+
+	var dest []interface{}
+	for _, c := range movieColumns {
+		if validator.In(c.field, sel...) {
+			dest = append(dest, targets[c.field])
+		}
+	}
+
+	return dest
+}
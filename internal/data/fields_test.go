@@ -0,0 +1,95 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		fields []string
+		valid  bool
+	}{
+		{nil, true},
+		{[]string{"id", "title"}, true},
+		{[]string{"external_id", "genres", "genres"}, true},
+		{[]string{"title", "director"}, false},
+		{[]string{"created_at"}, false},
+		{[]string{""}, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateFields(v, tt.fields)
+
+		if v.Valid() != tt.valid {
+			t.Errorf("%q: got valid %t, want %t", tt.fields, v.Valid(), tt.valid)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	tests := []struct {
+		fields []string
+		want   []string
+	}{
+		{nil, nil},
+		{[]string{}, nil},
+		{[]string{"title", "id"}, []string{"id", "title"}},
+		{[]string{"genres", "year", "genres"}, []string{"year", "genres"}},
+	}
+
+	for _, tt := range tests {
+		if got := SelectFields(tt.fields); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestSelectMovie(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		columns string
+	}{
+		{"every field", nil, "id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')"},
+		{"title", []string{"title"}, "id, created_at, title, version"},
+		{"genres and external ID", []string{"genres", "external_id"}, "id, created_at, genres, version, COALESCE(external_id, '')"},
+		{"id and version", []string{"id", "version"}, "id, created_at, version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel := selectMovie(tt.fields)
+
+			if got := sel.columns(); got != tt.columns {
+				t.Errorf("got columns %q, want %q", got, tt.columns)
+			}
+
+			//There must be a scan destination for each column
+			var movie Movie
+			if dest := sel.dest(&movie); len(dest) != len(sel) {
+				t.Errorf("got %d destinations for %d columns", len(dest), len(sel))
+			}
+		})
+	}
+}
+
+func TestSelectMovieDest(t *testing.T) {
+	var movie Movie
+
+	dest := selectMovie([]string{"year", "title"}).dest(&movie)
+	want := []interface{}{&movie.ID, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Version}
+
+	if len(dest) != len(want) {
+		t.Fatalf("got %d destinations, want %d", len(dest), len(want))
+	}
+
+	for i := range want {
+		if dest[i] != want[i] {
+			t.Errorf("destination %d is %T for the wrong field", i, dest[i])
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package data
 
 import (
+	"strings"
+
 	"firstAPI.jweaver11.net/internal/validator"
 )
 
@@ -13,11 +15,65 @@ type Filters struct {
 
 func ValidateFilters(v *validator.Validator, f Filters) {
 	//Check that the page and page_size parameters containn sensible values
-	v.Check(f.Page > 0, "page", "must be greater than zero")
-	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
-	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
-	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+	v.Check(f.Page > 0, "page", "validation.page.min")
+	v.Check(f.Page <= 10_000_000, "page", "validation.page.max")
+	v.Check(f.PageSize > 0, "page_size", "validation.page_size.min")
+	v.Check(f.PageSize <= 100, "page_size", "validation.page_size.max")
 
 	//Check the sort parameter matchs a value in the safelist
-	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "validation.sort.invalid")
+}
+
+//sortColumn returns the column to sort by. It checks the sort value against the safelist again, as the column is
+//written into the query, and panics if it isn't there, which protects against SQL injection if validation was skipped.
+func (f Filters) sortColumn() string {
+	for _, safeValue := range f.SortSafelist {
+		if f.Sort == safeValue {
+			return strings.TrimPrefix(f.Sort, "-")
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+//sortDirection returns "DESC" if the sort value has a hyphen prefix, and "ASC" otherwise.
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+//limit and offset return the LIMIT and OFFSET for the page.
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+//Metadata describes where a page of results sits in the whole list, for building pagination links.
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+}
+
+//calculateMetadata works out the pagination metadata from the total number of records. If there are no records we
+//don't know how many there would have been, so it returns an empty Metadata struct.
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     (totalRecords + pageSize - 1) / pageSize,
+		TotalRecords: totalRecords,
+	}
 }
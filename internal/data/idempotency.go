@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+//Errors returned by an idempotency store when a key can't be reserved for a request.
+var (
+	ErrIdempotencyKeyReused   = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("idempotency key in flight")
+)
+
+//IdempotencyRecord is the stored outcome of a request made with an Idempotency-Key header. RequestHash identifies the
+//request, so a retry can be told apart from a different request using the same key. Status is 0 while the original
+//request is still being handled, which it's assumed to be until LockedUntil. ExpiresAt is when a stored response
+//stops being replayed.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	Status      int
+	Header      map[string][]string
+	Body        []byte
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
+
+//IdempotencyModel stores idempotency keys in the idempotency_keys table, so they are shared by every instance of the API.
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+//Reserve claims a key for a request, locking it for the given lease. It returns a nil record if the key is new, its
+//old record has expired, or its old request never finished and the lease has run out, as happens when the process
+//handling it dies. The caller should then handle the request and call Complete() or Release(), and a completed
+//response is replayed until ttl after the key was reserved. If the key was already used for the same request and that
+//has finished, the stored record is returned so the response can be replayed. Otherwise it returns
+//ErrIdempotencyKeyReused or ErrIdempotencyKeyInFlight.
+func (m IdempotencyModel) Reserve(key, requestHash string, lease, ttl time.Duration) (*IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	//Insert the key, or take over an expired or abandoned one. If there's a live record nothing is returned
+	query := `
+		INSERT INTO idempotency_keys (key, request_hash, locked_until, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status = NULL, header = NULL, body = NULL,
+			created_at = NOW(), locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+			OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until <= NOW())
+		RETURNING key`
+
+	now := time.Now()
+
+	err := m.DB.QueryRowContext(ctx, query, key, requestHash, now.Add(lease), now.Add(ttl)).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	query = `
+		SELECT key, request_hash, COALESCE(status, 0), COALESCE(header, '{}'), COALESCE(body, ''), locked_until, expires_at
+		FROM idempotency_keys
+		WHERE key = $1`
+
+	var record IdempotencyRecord
+	var header []byte
+
+	err = m.DB.QueryRowContext(ctx, query, key).Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.Status,
+		&header,
+		&record.Body,
+		&record.LockedUntil,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		//The record was removed between our two queries, which only happens if its request failed. The client can retry
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrIdempotencyKeyInFlight
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(header, &record.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkIdempotencyRecord(&record, requestHash)
+}
+
+//Complete stores the response for a reserved key, so retries get the same response.
+func (m IdempotencyModel) Complete(key string, status int, header map[string][]string, body []byte) error {
+	js, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status = $2, header = $3, body = $4
+		WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, key, status, js, body)
+	return err
+}
+
+//Release removes a reserved key whose request didn't finish, so that a retry can try again.
+func (m IdempotencyModel) Release(key string) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE key = $1 AND status IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, key)
+	return err
+}
+
+//DeleteExpired removes every record which expired before the cutoff, and returns how many were removed.
+func (m IdempotencyModel) DeleteExpired(cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE expires_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+//checkIdempotencyRecord decides what to do with an existing, unexpired record for a key.
+func checkIdempotencyRecord(record *IdempotencyRecord, requestHash string) (*IdempotencyRecord, error) {
+	switch {
+	case record.RequestHash != requestHash:
+		return nil, ErrIdempotencyKeyReused
+	case record.Status == 0:
+		return nil, ErrIdempotencyKeyInFlight
+	default:
+		return record, nil
+	}
+}
+
+//MemoryIdempotencyStore keeps idempotency keys in memory. It's only suitable for a single instance of the API, and the keys
+//are lost when it restarts, but it needs no database.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*IdempotencyRecord
+}
+
+//NewMemoryIdempotencyStore returns an empty in-memory store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]*IdempotencyRecord)}
+}
+
+//Reserve works like IdempotencyModel.Reserve().
+func (s *MemoryIdempotencyStore) Reserve(key, requestHash string, lease, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	record, ok := s.records[key]
+	if !ok || !record.ExpiresAt.After(now) || record.Status == 0 && !record.LockedUntil.After(now) {
+		s.records[key] = &IdempotencyRecord{Key: key, RequestHash: requestHash, LockedUntil: now.Add(lease), ExpiresAt: now.Add(ttl)}
+		return nil, nil
+	}
+
+	//Return a copy, so the caller can't change the stored record
+	record, err := checkIdempotencyRecord(record, requestHash)
+	if record != nil {
+		stored := *record
+		record = &stored
+	}
+
+	return record, err
+}
+
+//Complete works like IdempotencyModel.Complete().
+func (s *MemoryIdempotencyStore) Complete(key string, status int, header map[string][]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		record.Status = status
+		record.Header = header
+		record.Body = body
+	}
+
+	return nil
+}
+
+//Release works like IdempotencyModel.Release().
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Status == 0 {
+		delete(s.records, key)
+	}
+
+	return nil
+}
+
+//DeleteExpired works like IdempotencyModel.DeleteExpired().
+func (s *MemoryIdempotencyStore) DeleteExpired(cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+
+	for key, record := range s.records {
+		if record.ExpiresAt.Before(cutoff) {
+			delete(s.records, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+type MockIdempotencyModel struct{}
+
+func (m MockIdempotencyModel) Reserve(key, requestHash string, lease, ttl time.Duration) (*IdempotencyRecord, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockIdempotencyModel) Complete(key string, status int, header map[string][]string, body []byte) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockIdempotencyModel) Release(key string) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockIdempotencyModel) DeleteExpired(cutoff time.Time) (int64, error) {
+	//Mock the action...
+	return 0, nil
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyStoreReserve(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+
+	//A new key is reserved, and a retry while it's locked is told the request is still in flight
+	if record, err := store.Reserve("new", "hash", time.Minute, time.Hour); record != nil || err != nil {
+		t.Fatalf("got %v and %v, want the key reserved", record, err)
+	}
+	if _, err := store.Reserve("new", "hash", time.Minute, time.Hour); err != ErrIdempotencyKeyInFlight {
+		t.Errorf("got %v, want ErrIdempotencyKeyInFlight", err)
+	}
+	if _, err := store.Reserve("new", "other", time.Minute, time.Hour); err != ErrIdempotencyKeyReused {
+		t.Errorf("got %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	//A completed response is replayed
+	store.Complete("new", 201, map[string][]string{"Location": {"/v1/movies/1"}}, []byte(`{}`))
+
+	record, err := store.Reserve("new", "hash", time.Minute, time.Hour)
+	if err != nil || record == nil || record.Status != 201 || string(record.Body) != `{}` {
+		t.Errorf("got %+v and %v, want the stored response", record, err)
+	}
+
+	//A released key can be reserved again straight away
+	store.Reserve("released", "hash", time.Minute, time.Hour)
+	store.Release("released")
+
+	if record, err := store.Reserve("released", "hash", time.Minute, time.Hour); record != nil || err != nil {
+		t.Errorf("got %v and %v, want the released key reserved again", record, err)
+	}
+}
+
+func TestMemoryIdempotencyStoreAbandonedReservation(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	lease := 20 * time.Millisecond
+
+	//The request holding the key is never completed or released, as if the process handling it was killed
+	store.Reserve("abandoned", "hash", lease, time.Hour)
+
+	if _, err := store.Reserve("abandoned", "hash", lease, time.Hour); err != ErrIdempotencyKeyInFlight {
+		t.Fatalf("got %v during the lease, want ErrIdempotencyKeyInFlight", err)
+	}
+
+	time.Sleep(2 * lease)
+
+	//Once the lease has run out, a retry takes the key over rather than waiting for the replay TTL
+	if record, err := store.Reserve("abandoned", "hash", lease, time.Hour); record != nil || err != nil {
+		t.Fatalf("got %v and %v after the lease, want the key taken over", record, err)
+	}
+
+	//A completed response is still replayed after the lease, until it expires
+	store.Complete("abandoned", 201, nil, []byte(`{}`))
+	time.Sleep(2 * lease)
+
+	if record, err := store.Reserve("abandoned", "hash", lease, time.Hour); err != nil || record == nil || record.Status != 201 {
+		t.Errorf("got %+v and %v, want the stored response replayed", record, err)
+	}
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+
+	store.Reserve("expired", "hash", time.Minute, -time.Second)
+	store.Complete("expired", 201, nil, nil)
+	store.Reserve("live", "hash", time.Minute, time.Hour)
+
+	if record, err := store.Reserve("expired", "other", time.Minute, time.Hour); record != nil || err != nil {
+		t.Errorf("got %v and %v, want the expired key reused", record, err)
+	}
+
+	store.Reserve("old", "hash", time.Minute, -time.Second)
+
+	if n, _ := store.DeleteExpired(time.Now()); n != 1 {
+		t.Errorf("deleted %d records, want 1", n)
+	}
+}
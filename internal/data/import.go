@@ -0,0 +1,232 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//ErrDuplicateMovies is returned by Import() when duplicates aren't being skipped and at least one row is a duplicate.
+var ErrDuplicateMovies = errors.New("import contains duplicate movies")
+
+//errDryRun is returned inside a dry run's transaction, so that it's rolled back.
+var errDryRun = errors.New("dry run")
+
+//ImportRow is a validated movie to import, with its row number in the file it came from.
+type ImportRow struct {
+	Row   int
+	Movie *Movie
+}
+
+//ImportOptions control how Import() behaves.
+type ImportOptions struct {
+	SkipDuplicates bool //skip duplicate rows, rather than importing nothing
+	DryRun         bool //check the rows, including for duplicates, but don't save anything
+}
+
+//ImportResult reports what Import() did. Duplicates lists the row numbers which were duplicates, whether they were
+//skipped or stopped the import.
+type ImportResult struct {
+	Inserted   int64
+	Duplicates []int
+}
+
+//Import bulk loads movies in a single transaction, recording an insert revision and a created event for each one, as
+//Insert() does. The rows are staged in a temporary table with COPY and inserted with a single statement, which is far
+//faster than inserting them one at a time.
+//
+//A row is a duplicate if a movie with its external ID already exists (even a deleted one), or if it has no external ID
+//and a live movie with the same title and year exists. Rows are also duplicates of earlier rows in the same import.
+//Unless opts.SkipDuplicates is set, any duplicate means nothing is imported and ErrDuplicateMovies is returned.
+func (m MovieModel) Import(rows []ImportRow, opts ImportOptions, audit Audit) (*ImportResult, error) {
+	//Imports can be large, so allow much longer than our usual 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	result := &ImportResult{Duplicates: []int{}}
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := `
+			CREATE TEMPORARY TABLE movie_import (
+				line integer PRIMARY KEY,
+				title text NOT NULL,
+				year integer NOT NULL,
+				runtime integer NOT NULL,
+				genres text[] NOT NULL,
+				external_id text
+			) ON COMMIT DROP`
+
+		_, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		staged := make([][]interface{}, 0, len(rows))
+		for _, row := range rows {
+			movie := row.Movie
+			staged = append(staged, []interface{}{row.Row, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), nullString(movie.ExternalID)})
+		}
+
+		err = copyIn(ctx, tx, "movie_import", []string{"line", "title", "year", "runtime", "genres", "external_id"}, staged)
+		if err != nil {
+			return err
+		}
+
+		//Find the duplicates, either of existing movies or of earlier rows
+		query = `
+			SELECT i.line
+			FROM movie_import i
+			WHERE EXISTS (
+				SELECT 1 FROM movies m
+				WHERE (i.external_id IS NOT NULL AND m.external_id = i.external_id)
+				OR (i.external_id IS NULL AND m.deleted_at IS NULL AND lower(m.title) = lower(i.title) AND m.year = i.year)
+			) OR EXISTS (
+				SELECT 1 FROM movie_import j
+				WHERE j.line < i.line
+				AND ((i.external_id IS NOT NULL AND j.external_id = i.external_id)
+				OR (i.external_id IS NULL AND j.external_id IS NULL AND lower(j.title) = lower(i.title) AND j.year = i.year))
+			)
+			ORDER BY i.line`
+
+		result.Duplicates, err = queryInts(ctx, tx, query)
+		if err != nil {
+			return err
+		}
+
+		if len(result.Duplicates) > 0 {
+			if !opts.SkipDuplicates {
+				return ErrDuplicateMovies
+			}
+
+			_, err = tx.ExecContext(ctx, `DELETE FROM movie_import WHERE line = ANY($1)`, pq.Array(result.Duplicates))
+			if err != nil {
+				return err
+			}
+		}
+
+		if opts.DryRun {
+			result.Inserted = int64(len(rows) - len(result.Duplicates))
+			return errDryRun
+		}
+
+		//Insert the remaining rows in the order they appear in the file
+		query = `
+			INSERT INTO movies (title, year, runtime, genres, external_id)
+			SELECT title, year, runtime, genres, external_id
+			FROM movie_import
+			ORDER BY line
+			RETURNING id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')`
+
+		inserted, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer inserted.Close()
+
+		revisions := make([][]interface{}, 0, len(rows))
+		events := make([][]interface{}, 0, len(rows))
+
+		for inserted.Next() {
+			movie, err := scanMovie(inserted)
+			if err != nil {
+				return err
+			}
+
+			after, err := snapshotJSON(movie)
+			if err != nil {
+				return err
+			}
+
+			revisions = append(revisions, []interface{}{movie.ID, movie.Version, RevisionInsert, nil, after, audit.Actor, audit.RequestID})
+
+			event, err := movieEventRow(RevisionInsert, nil, movie)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+
+		if err = inserted.Err(); err != nil {
+			return err
+		}
+
+		//The connection can't start the COPY until the results have been closed
+		inserted.Close()
+
+		result.Inserted = int64(len(revisions))
+
+		//Record each insert in the movies' history, and write its event to the outbox, in the same transaction
+		err = copyIn(ctx, tx, "movie_revisions", []string{"movie_id", "version", "operation", "before", "after", "actor", "request_id"}, revisions)
+		if err != nil {
+			return err
+		}
+
+		return copyIn(ctx, tx, "outbox", outboxColumns, events)
+	})
+
+	switch {
+	case errors.Is(err, errDryRun):
+		return result, nil
+	case err != nil:
+		//For ErrDuplicateMovies the result says which rows were duplicates
+		return result, err
+	}
+
+	return result, nil
+}
+
+//copyIn loads rows into a table with COPY. Each row's values must be in the same order as the columns.
+func copyIn(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		_, err = stmt.ExecContext(ctx, row...)
+		if err != nil {
+			return err
+		}
+	}
+
+	//An Exec with no arguments flushes the buffered rows and ends the COPY
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return stmt.Close()
+}
+
+//queryInts runs a query which returns a single integer column.
+func queryInts(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ints := []int{}
+
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+
+	return ints, rows.Err()
+}
+
+//nullString returns nil (SQL NULL) for an empty string.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -1,8 +1,10 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 //Define a custom ErrRecordNotFound error. This returns from our Get() method when movie doesn't exist in our database
@@ -17,23 +19,89 @@ type Models struct {
 	//Set the Movies field to be an interface containing the methods that both the 'real model and mock model need to support
 
 	Movies interface {
-		Insert(movie *Movie) error
-		Get(id int64) (*Movie, error)
-		Update(movie *Movie) error
+		Insert(movie *Movie, audit Audit) error
+		Get(id int64, fields ...string) (*Movie, error)
+		GetMany(ids []int64) ([]*Movie, error)
+		GetByExternalID(externalID string) (*Movie, error)
+		Upsert(movie *Movie, expectedVersion *int32, audit Audit) (bool, error)
+		Update(movie *Movie, audit Audit) error
+		Revert(movie *Movie, audit Audit) error
+		Delete(id int64, audit Audit) error
+		DeleteVersion(id int64, version int32, audit Audit) error
+		GetAll(title string, genres []string, filters Filters, fields ...string) ([]*Movie, Metadata, error)
+		GetAllDeleted(title string, genres []string, filters Filters, fields ...string) ([]*Movie, Metadata, error)
+		Export(ctx context.Context, title string, genres []string, filters Filters, deleted bool, fn func(movie *Movie) error) error
+		Restore(id int64, audit Audit) (*Movie, error)
+		Purge(id int64, audit Audit) error
+		PurgeDeletedBefore(cutoff time.Time, audit Audit) (int64, error)
+		Import(rows []ImportRow, opts ImportOptions, audit Audit) (*ImportResult, error)
+
+		//Transaction-aware variants, for making several changes atomically
+		InTx(fn func(tx *sql.Tx) error) error
+		InsertTx(tx *sql.Tx, movie *Movie, audit Audit) error
+		GetTx(tx *sql.Tx, id int64) (*Movie, error)
+		UpdateTx(tx *sql.Tx, movie *Movie, audit Audit) error
+		DeleteTx(tx *sql.Tx, id int64, version *int32, audit Audit) error
+	}
+
+	//Revisions are written by the Movies model, in the same transaction as each change, so this is read-only
+	Revisions interface {
+		GetAllForMovie(movieID int64) ([]*Revision, error)
+		GetAllForMovies(movieIDs []int64) (map[int64][]*Revision, error)
+		GetForVersion(movieID int64, version int32) (*Revision, error)
+	}
+
+	//IdempotencyKeys can be backed by Postgres or kept in memory, so main() may swap in a different store
+	IdempotencyKeys interface {
+		Reserve(key, requestHash string, lease, ttl time.Duration) (*IdempotencyRecord, error)
+		Complete(key string, status int, header map[string][]string, body []byte) error
+		Release(key string) error
+		DeleteExpired(cutoff time.Time) (int64, error)
+	}
+
+	//Webhooks holds the webhook subscriptions and their deliveries. Deliveries are enqueued by a handler on the event relay
+	Webhooks interface {
+		Insert(sub *WebhookSubscription) error
+		Get(id int64) (*WebhookSubscription, error)
+		GetAll() ([]*WebhookSubscription, error)
+		Update(sub *WebhookSubscription) error
 		Delete(id int64) error
+		Enqueue(eventID int64, eventType string, occurredAt time.Time, data interface{}) (int64, error)
+		ClaimDue(limit int, lease time.Duration) ([]*WebhookDelivery, error)
+		RecordAttempt(deliveryID int64, attempt *WebhookAttempt, status string, nextAttemptAt time.Time) error
+		GetDeliveries(subscriptionID int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error)
+		Retry(subscriptionID, deliveryID int64) (*WebhookDelivery, error)
+		PurgeDeliveriesBefore(cutoff time.Time) (int64, error)
+	}
+
+	//Outbox holds the events written by the Movies model, in the same transaction as each change, and each event
+	//handler's checkpoint
+	Outbox interface {
+		After(position int64, limit int) ([]*OutboxEvent, error)
+		Checkpoint(handler string) (int64, error)
+		SaveCheckpoint(handler string, position int64) error
+		PurgeBefore(cutoff time.Time, handlers []string) (int64, error)
 	}
 }
 
 //For ease of use, we also add a New() method which returns a Models struct containing the initialized MovieModel
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies: MovieModel{DB: db},
+		Movies:          MovieModel{DB: db},
+		Revisions:       RevisionModel{DB: db},
+		IdempotencyKeys: IdempotencyModel{DB: db},
+		Webhooks:        WebhookModel{DB: db},
+		Outbox:          OutboxModel{DB: db},
 	}
 }
 
 //Create a helper function which returns a Models instance containning the mock models only
 func NewMockModels() Models {
 	return Models{
-		Movies: MockMovieModel{},
+		Movies:          MockMovieModel{},
+		Revisions:       MockRevisionModel{},
+		IdempotencyKeys: MockIdempotencyModel{},
+		Webhooks:        MockWebhookModel{},
+		Outbox:          MockOutboxModel{},
 	}
 }
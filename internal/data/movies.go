@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"firstAPI.jweaver11.net/internal/validator"
@@ -16,40 +17,158 @@ type MovieModel struct {
 	DB *sql.DB
 }
 
-//Add a placeholder method for inserting a new recod in the movies table
-func (m MovieModel) Insert(movie *Movie) error {
+//Add a placeholder method for inserting a new recod in the movies table.
+//The insert and its revision in movie_revisions are written in the same transaction.
+func (m MovieModel) Insert(movie *Movie, audit Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return insertMovie(ctx, tx, movie, audit)
+	})
+}
+
+//insertMovie does the work for Insert() inside a transaction.
+func insertMovie(ctx context.Context, tx *sql.Tx, movie *Movie, audit Audit) error {
 	//define the SQL query for inserting a new record in the movies table and returning the system-generated data
 	query := `
-		INSERT INTO movies (title, year, runtime, genres)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO movies (title, year, runtime, genres, external_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
 		RETURNING id, created_at, version`
 
 	//Create an args slice containing the values for the placeholder parameters from the movie struct.
 	//Declaring this slice immediately next to our SQL query helps to make it clear *What values are uses where* in query
-	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ExternalID}
+
+	//Use the QueryRow() method to execute the SQL query, passing in the args slice as a variadic
+	//parameter and scanning the system genereated id, created_at and version values into the movie struct
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	return recordChange(ctx, tx, RevisionInsert, nil, movie, audit)
+}
 
+//Upsert creates the movie with the given external ID, or fully replaces it if one already exists, and reports whether it was
+//created. A soft deleted movie with the external ID is brought back by the replace. If expectedVersion isn't nil, an existing
+//movie must be at that version, otherwise ErrEditConflict is returned. The write and its revision are in the same transaction.
+func (m MovieModel) Upsert(movie *Movie, expectedVersion *int32, audit Audit) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	//Use the QueryRow() method to execute the SQL query on our connection pool, passing in the args slice as a variadic
-	//parameter and scanning the system genereated id, created_at and version values into the movie struct
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	var created bool
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		var err error
+		created, err = upsertMovie(ctx, tx, movie, expectedVersion, audit)
+		return err
+	})
+
+	return created, err
+}
+
+//upsertMovie does the work for Upsert() inside a transaction.
+func upsertMovie(ctx context.Context, tx *sql.Tx, movie *Movie, expectedVersion *int32, audit Audit) (bool, error) {
+	//Lock the existing live row, if there is one, so we can record its state before it's replaced
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')
+		FROM movies
+		WHERE external_id = $1 AND deleted_at IS NULL
+		FOR UPDATE`
+
+	before, err := scanMovie(tx.QueryRowContext(ctx, query, movie.ExternalID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	//A soft deleted movie counts as missing, so there's nothing for an expected version to match
+	if expectedVersion != nil && (before == nil || before.Version != *expectedVersion) {
+		return false, ErrEditConflict
+	}
+
+	//ON CONFLICT covers a concurrent request inserting the same external ID after our SELECT. xmax is 0 for a freshly inserted row,
+	//which is how we tell an insert from an update
+	query = `
+		INSERT INTO movies (external_id, title, year, runtime, genres)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (external_id) DO UPDATE
+		SET title = EXCLUDED.title, year = EXCLUDED.year, runtime = EXCLUDED.runtime, genres = EXCLUDED.genres,
+			version = movies.version + 1, deleted_at = NULL
+		WHERE $6::integer IS NULL OR (movies.version = $6 AND movies.deleted_at IS NULL)
+		RETURNING id, created_at, version, (xmax = 0)`
+
+	args := []interface{}{movie.ExternalID, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), expectedVersion}
+
+	var created bool
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version, &created)
+	if err != nil {
+		switch {
+		//The row exists but the DO UPDATE's WHERE clause didn't match, so nothing was returned
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrEditConflict
+		default:
+			return false, err
+		}
+	}
+
+	if created {
+		return true, recordChange(ctx, tx, RevisionInsert, nil, movie, audit)
+	}
+
+	//If there was no live movie before, the replace brought back a soft deleted one
+	operation := RevisionUpdate
+	if before == nil {
+		operation = RevisionRestore
+	}
+
+	return false, recordChange(ctx, tx, operation, before, movie, audit)
+}
+
+//GetByExternalID fetches a live movie by its external ID.
+func (m MovieModel) GetByExternalID(externalID string) (*Movie, error) {
+	if externalID == "" {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')
+		FROM movies
+		WHERE external_id = $1 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	movie, err := scanMovie(m.DB.QueryRowContext(ctx, query, externalID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return movie, nil
 }
 
 //Add a placeholder method for fetching a specific record fromt he movies table
-func (m MovieModel) Get(id int64) (*Movie, error) {
+//If any fields are given, only those columns are read, along with the ID and version, and the other fields are left empty
+func (m MovieModel) Get(id int64, fields ...string) (*Movie, error) {
 	//The PostgreSQL bigserial type starts auto-incrementing at 1 by default, so we know non movies have an ID number less than that
 	//To avoic unenecessary database call, we take a shortcut and return 'ErrRecordNotFound' error straight away
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
-	//Define the SQL query for retrieving the movie data
-	//Makes our database sleep for 10 seconds before return response
+	//Define the SQL query for retrieving the movie data, selecting only the columns for the fields asked for
+	sel := selectMovie(fields)
+
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
+		SELECT ` + sel.columns() + `
 		FROM movies
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	var movie Movie
 
@@ -58,16 +177,8 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	defer cancel()
 
 	//Execute the query using the QueryRow() method, passing in the providied id value as a placeholder parameter, and scan the
-	//response data into the fileds of Movie struct. We convert the scan target for the genres column using the pq.Array() adapter function
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-	)
+	//response data into the fileds of Movie struct. The selection converts the scan target for the genres column using the pq.Array() adapter function
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(sel.dest(&movie)...)
 
 	//Handle any errors. If there was no matching movie found, Scan() will return a sql.ErrNoRows errror.
 	//We check for this and return our custom 'ErrRecordNotFound' error instead
@@ -84,12 +195,42 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 }
 
 //Add a placeholder method for updating a specific record in the movies table.
-func (m MovieModel) Update(movie *Movie) error {
+//The update and its revision in movie_revisions are written in the same transaction.
+func (m MovieModel) Update(movie *Movie, audit Audit) error {
+	//Create a context with a 3-second timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return updateMovie(ctx, tx, movie, audit, RevisionUpdate)
+	})
+}
+
+//Revert works like Update(), but records the change as a revert in the movie's history. The caller is expected
+//to have copied the fields from an earlier revision into the movie.
+func (m MovieModel) Revert(movie *Movie, audit Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return updateMovie(ctx, tx, movie, audit, RevisionRevert)
+	})
+}
+
+//updateMovie does the work for Update() and Revert() inside a transaction.
+func updateMovie(ctx context.Context, tx *sql.Tx, movie *Movie, audit Audit, operation string) error {
+	//Lock the current row so we can record its state before the update. If no matching row could be found, we know the
+	//movie version has changed (or that record has been deleted) and we return our custom ErrEditConflict error
+	before, err := getMovieForUpdate(ctx, tx, movie.ID, movie.Version)
+	if err != nil {
+		return err
+	}
+
 	//Declare the SQL query for updating the record and returning the new version number
 	query := `
 		UPDATE movies
 		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 		RETURNING version`
 
 	//create an args slice containing the values for the placeholder parameters
@@ -102,138 +243,528 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Version, //add the expected movie version
 	}
 
+	//Execute the SQL query. As the row is locked, it can't have changed since we read it
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return recordChange(ctx, tx, operation, before, movie, audit)
+}
+
+//getMovieForUpdate fetches and locks a live movie at a specific version, returning ErrEditConflict if there isn't one.
+func getMovieForUpdate(ctx context.Context, tx *sql.Tx, id int64, version int32) (*Movie, error) {
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')
+		FROM movies
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		FOR UPDATE`
+
+	movie, err := scanMovie(tx.QueryRowContext(ctx, query, id, version))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrEditConflict
+		default:
+			return nil, err
+		}
+	}
+
+	return movie, nil
+}
+
+//Add a placeholder method for deleting a specific record from the movies table.
+//This is a soft delete: the record is kept as a tombstone with its deleted_at time set, which Get() and GetAll() skip.
+//Tombstones can be brought back with Restore(), or removed for good with Purge().
+func (m MovieModel) Delete(id int64, audit Audit) error {
+	//Return an ErrRecordNotFound error if the movie ID is less than 1
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
 	//Create a context with a 3-second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	//Execute the SQL query. If no matching row could be found, we know th emovie version has changed (or that record
-	//has been deleted) and we return our custom ErrEditConflict error
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	//If there's no live movie with the ID, deleteMovie() returns an ErrRecordNotFound error
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return deleteMovie(ctx, tx, id, nil, audit)
+	})
+}
+
+//DeleteVersion soft deletes a movie only if it is still at the given version. If the movie exists at a different version
+//it returns ErrEditConflict, and if it doesn't exist at all it returns ErrRecordNotFound.
+func (m MovieModel) DeleteVersion(id int64, version int32, audit Audit) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return deleteMovie(ctx, tx, id, &version, audit)
+	})
+}
+
+//deleteMovie does the work for Delete() and DeleteVersion() inside a transaction. If version is nil, any version is deleted.
+func deleteMovie(ctx context.Context, tx *sql.Tx, id int64, version *int32, audit Audit) error {
+	//Construct the SQL query to delete the record. The version is bumped, as the movie's state has changed
+	query := `
+		UPDATE movies
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND ($2::integer IS NULL OR version = $2) AND deleted_at IS NULL
+		RETURNING id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')`
+
+	movie, err := scanMovie(tx.QueryRowContext(ctx, query, id, version))
 	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		//Nothing was deleted. If we were matching a version, check whether the movie exists at another one
+		if version == nil {
+			return ErrRecordNotFound
+		}
+
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM movies WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
 		switch {
-		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		case exists:
 			return ErrEditConflict
 		default:
+			return ErrRecordNotFound
+		}
+	}
+
+	//The state before the delete is the same movie at the previous version
+	before := *movie
+	before.Version--
+
+	return recordChange(ctx, tx, RevisionDelete, &before, nil, audit)
+}
+
+//Restore brings back a soft deleted movie, bumping its version. It returns ErrRecordNotFound if there is no
+//deleted movie with the ID.
+func (m MovieModel) Restore(id int64, audit Audit) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var movie *Movie
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		var err error
+
+		movie, err = scanMovie(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
 			return err
 		}
+
+		//The state before the restore is the tombstone, which is the same movie at the previous version
+		before := *movie
+		before.Version--
+
+		return recordChange(ctx, tx, RevisionRestore, &before, movie, audit)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	return nil
+	return movie, nil
 }
 
-//Add a placeholder method for deleting a specific record from the movies table
-func (m MovieModel) Delete(id int64) error {
-	//Return an ErrRecordNotFound error if the movie ID is less than 1
+//Purge permanently removes a soft deleted movie, recording a revision with the tombstone as its before state. Live
+//movies can't be purged, they must be deleted first, so it returns ErrRecordNotFound if there is no deleted movie with
+//the ID.
+func (m MovieModel) Purge(id int64, audit Audit) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	//Construct the SQL query to delete the record
 	query := `
 		DELETE FROM movies
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')`
 
-	//Create a context with a 3-second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	//Execute the SQL query using the Exec() method, passing in the id variables as the value for the placeholder parameter
-	//The Exec() method returns a sql.Result object
-	result, err := m.DB.ExecContext(ctx, query, id)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		movie, err := scanMovie(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return err
+		}
+
+		return recordRevision(ctx, tx, RevisionPurge, movie, nil, audit)
+	})
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+//PurgeDeletedBefore permanently removes every movie which was soft deleted before the cutoff, recording a revision for
+//each in the same transaction, and returns how many were removed.
+func (m MovieModel) PurgeDeletedBefore(cutoff time.Time, audit Audit) (int64, error) {
+	query := `
+		DELETE FROM movies
+		WHERE deleted_at < $1
+		RETURNING id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')`
+
+	//This can touch a lot of rows, so allow longer than our usual 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var purged []*Movie
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			movie, err := scanMovie(rows)
+			if err != nil {
+				return err
+			}
+
+			purged = append(purged, movie)
+		}
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		//The rows must be closed before the transaction can be used again
+		rows.Close()
+
+		for _, movie := range purged {
+			err = recordRevision(ctx, tx, RevisionPurge, movie, nil, audit)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	//Call the RowsAffected() method on teh sql.Result object to get the number of rows affected by the query
-	rowsAffected, err := result.RowsAffected()
+	return int64(len(purged)), nil
+}
+
+//The transaction-aware variants below let a caller make several changes in one transaction, which InTx() begins and
+//commits. Each does the same work as the method without the Tx suffix.
+
+//InTx runs fn in a transaction, committing if it returns nil and rolling back otherwise. Each statement still has our
+//usual 3-second timeout, but the transaction as a whole can run for up to a minute.
+func (m MovieModel) InTx(fn func(tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	return withTx(ctx, m.DB, fn)
+}
+
+//InsertTx works like Insert(), inside the caller's transaction.
+func (m MovieModel) InsertTx(tx *sql.Tx, movie *Movie, audit Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertMovie(ctx, tx, movie, audit)
+}
+
+//GetTx works like Get(), inside the caller's transaction. The movie is locked until the transaction ends, so it can
+//safely be changed and passed to UpdateTx().
+func (m MovieModel) GetTx(tx *sql.Tx, id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')
+		FROM movies
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	movie, err := scanMovie(tx.QueryRowContext(ctx, query, id))
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	//If no rows were affected, we know that the movies table didn't contain a record with the provided ID at the moment
-	//we tried to delete it. In that case we return an ErrRecordNotFound error
-	if rowsAffected == 0 {
+	return movie, nil
+}
+
+//UpdateTx works like Update(), inside the caller's transaction.
+func (m MovieModel) UpdateTx(tx *sql.Tx, movie *Movie, audit Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return updateMovie(ctx, tx, movie, audit, RevisionUpdate)
+}
+
+//DeleteTx works like Delete(), or like DeleteVersion() if version isn't nil, inside the caller's transaction.
+func (m MovieModel) DeleteTx(tx *sql.Tx, id int64, version *int32, audit Audit) error {
+	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return deleteMovie(ctx, tx, id, version, audit)
+}
+
+//recordChange records a change to a movie in the same transaction as the change: its revision in the movie's history,
+//and its event in the outbox.
+func recordChange(ctx context.Context, tx *sql.Tx, operation string, before, after *Movie, audit Audit) error {
+	err := recordRevision(ctx, tx, operation, before, after, audit)
+	if err != nil {
+		return err
+	}
 
+	return recordEvent(ctx, tx, operation, before, after)
 }
 
 type MockMovieModel struct{}
 
-func (m MockMovieModel) Insert(movie *Movie) error {
+func (m MockMovieModel) Insert(movie *Movie, audit Audit) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockMovieModel) Get(id int64, fields ...string) (*Movie, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockMovieModel) Upsert(movie *Movie, expectedVersion *int32, audit Audit) (bool, error) {
+	//Mock the action...
+	return false, nil
+}
+
+func (m MockMovieModel) GetByExternalID(externalID string) (*Movie, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockMovieModel) Update(movie *Movie, audit Audit) error {
+	//Mock the action...
+	return nil
+
+}
+
+func (m MockMovieModel) Revert(movie *Movie, audit Audit) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockMovieModel) Delete(id int64, audit Audit) error {
+	//Mock the action...
+	return nil
+
+}
+
+func (m MockMovieModel) DeleteVersion(id int64, version int32, audit Audit) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockMovieModel) Restore(id int64, audit Audit) (*Movie, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockMovieModel) Purge(id int64, audit Audit) error {
 	//Mock the action...
 	return nil
 }
 
-func (m MockMovieModel) Get(id int64) (*Movie, error) {
+func (m MockMovieModel) PurgeDeletedBefore(cutoff time.Time, audit Audit) (int64, error) {
+	//Mock the action...
+	return 0, nil
+}
+
+func (m MockMovieModel) GetMany(ids []int64) ([]*Movie, error) {
 	//Mock the action...
 	return nil, nil
 }
 
-func (m MockMovieModel) Update(movie *Movie) error {
+func (m MockMovieModel) GetAllDeleted(title string, genres []string, filters Filters, fields ...string) ([]*Movie, Metadata, error) {
+	//Mock the action...
+	return nil, Metadata{}, nil
+}
+
+func (m MockMovieModel) GetAll(title string, genres []string, filters Filters, fields ...string) ([]*Movie, Metadata, error) {
+	//Mock the action...
+	return nil, Metadata{}, nil
+}
+
+func (m MockMovieModel) InTx(fn func(tx *sql.Tx) error) error {
+	//Mock the action...
+	return fn(nil)
+}
+
+func (m MockMovieModel) InsertTx(tx *sql.Tx, movie *Movie, audit Audit) error {
 	//Mock the action...
 	return nil
+}
 
+func (m MockMovieModel) GetTx(tx *sql.Tx, id int64) (*Movie, error) {
+	//Mock the action...
+	return nil, nil
 }
 
-func (m MockMovieModel) Delete(id int64) error {
+func (m MockMovieModel) UpdateTx(tx *sql.Tx, movie *Movie, audit Audit) error {
 	//Mock the action...
 	return nil
+}
 
+func (m MockMovieModel) DeleteTx(tx *sql.Tx, id int64, version *int32, audit Audit) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockMovieModel) Import(rows []ImportRow, opts ImportOptions, audit Audit) (*ImportResult, error) {
+	//Mock the action...
+	return &ImportResult{Inserted: int64(len(rows)), Duplicates: []int{}}, nil
+}
+
+func (m MockMovieModel) Export(ctx context.Context, title string, genres []string, filters Filters, deleted bool, fn func(movie *Movie) error) error {
+	//Mock the action...
+	return nil
 }
 
 type Movie struct {
-	ID        int64     `json:"id"`                //Unique integer ID for the movie
-	CreatedAt time.Time `json:"-"`                 //Timestamp for when the movie is added to our database
-	Title     string    `json:"title"`             //Movie title
-	Year      int32     `json:"year,omitempty"`    //Movie release year
-	Runtime   Runtime   `json:"runtime,omitempty"` //Movie runtime (in minutes)
-	Genres    []string  `json:"genres,omitempty"`  //Slice of genres for the movie (romance, comedy, etc.)
-	Version   int32     `json:"version"`           //The version number starts at 1 and will be incremented each time the movie information is updated
+	ID         int64     `json:"id"`                                                                    //Unique integer ID for the movie
+	CreatedAt  time.Time `json:"-"`                                                                     //Timestamp for when the movie is added to our database
+	Title      string    `json:"title" validate:"required,max=500"`                                     //Movie title
+	Year       int32     `json:"year,omitempty" validate:"required,min=1888"`                           //Movie release year
+	Runtime    Runtime   `json:"runtime,omitempty" validate:"required,min=1"`                           //Movie runtime (in minutes)
+	Genres     []string  `json:"genres,omitempty" validate:"required,min=1,max=5,unique,dive,required"` //Slice of genres for the movie (romance, comedy, etc.)
+	Version    int32     `json:"version"`                                                               //The version number starts at 1 and will be incremented each time the movie information is updated
+	ExternalID string    `json:"external_id,omitempty" validate:"omitempty,max=255"`                    //Optional ID from another system, unique across movies, used by Upsert()
 }
 
+//ValidateMovie checks a movie against the rules in the validate tags on the Movie struct, which mirror the CHECK
+//constraints in the movies table. Rules that can't be expressed as a tag, like the year not being in the future, are checked by hand.
 func ValidateMovie(v *validator.Validator, movie *Movie) {
-	v.Check(movie.Title != "", "title", "must be provided")
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
+	validator.ValidateStruct(v, movie)
+
+	//The messages are i18n catalog keys, which are translated into the client's language when the response is written
+	v.Check(movie.Year <= int32(time.Now().Year()), "year", "validation.year.future")
+}
+
+//GetMany returns the live movies with the given IDs in one query, for loading them in a batch. IDs with no live movie
+//are skipped, so the result may be shorter than ids, and it's in no particular order.
+func (m MovieModel) GetMany(ids []int64) ([]*Movie, error) {
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version, COALESCE(external_id, '')
+		FROM movies
+		WHERE id = ANY($1) AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		movie, err := scanMovie(rows)
+		if err != nil {
+			return nil, err
+		}
 
-	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
+		movies = append(movies, movie)
+	}
 
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
-	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
+	return movies, nil
+}
 
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain dupliate values")
+//Create a new 'GetAll()' method which returns a slice of movies and the pagination metadata. We set these up to accept the various filter parameters as arguments
+//As with Get(), any fields given narrow the columns which are read
+func (m MovieModel) GetAll(title string, genres []string, filters Filters, fields ...string) ([]*Movie, Metadata, error) {
+	return m.getAll(title, genres, filters, false, fields)
 }
 
-//Create a new 'GetAll()' method which returns a slice of movies. We set these up to accept the various filter parameters as arguments
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, error) {
-	//Construct the SQL query to retrieve all movie records
-	query := `
-	SELECT id, created_at, title, year, runtime, genres, version
-	FROM movies
-	ORDER BY id`
+//GetAllDeleted works like GetAll(), but returns only the soft deleted movies.
+func (m MovieModel) GetAllDeleted(title string, genres []string, filters Filters, fields ...string) ([]*Movie, Metadata, error) {
+	return m.getAll(title, genres, filters, true, fields)
+}
+
+//getAll returns a page of either the live movies or the soft deleted ones.
+func (m MovieModel) getAll(title string, genres []string, filters Filters, deleted bool, fields []string) ([]*Movie, Metadata, error) {
+	//Construct the SQL query to retrieve the page of movie records. The count(*) OVER() window function adds the
+	//total number of matching movies, before paging, to every row
+	sel := selectMovie(fields)
+
+	query := movieListQuery("count(*) OVER(), "+sel.columns(), filters) + `
+		LIMIT $4 OFFSET $5`
 
 	//Create a context with a 3-second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	args := []interface{}{deleted, title, pq.Array(genres), filters.limit(), filters.offset()}
+
 	//Use the QueryContext() to execute the query. Returns the sql.Rows resultset with the result
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
 	//defer a call to rows.Close() to ensure that the resultset is closed before 'GetAll()' returns
 	defer rows.Close()
 
 	//Initialize an empty slice to hold movie data
+	totalRecords := 0
 	movies := []*Movie{}
 
 	//use rows.Next to iterate through the rows in the resultset
@@ -242,17 +773,9 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 		var movie Movie
 
 		//Scan the values from row into movie struct
-		err := rows.Scan(
-			&movie.ID,
-			&movie.CreatedAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-		)
+		err := rows.Scan(append([]interface{}{&totalRecords}, sel.dest(&movie)...)...)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 
 		//Add the Movie struct to the slice
@@ -261,8 +784,83 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 
 	//When the rows.Next() loop has finished, call rows.Err() to retrieve any error encountered
 	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return movies, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+//movieListQuery returns the query GetAll() and Export() use to filter and sort movies, reading the given columns,
+//which takes the parameters $1 deleted, $2 title and $3 genres. A movie matches the title if its title contains all the words in it, and the
+//genres if it has all of them, and empty values match everything. Movies are sorted by id after the sort column,
+//so the order is always the same.
+func movieListQuery(columns string, filters Filters) string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM movies
+		WHERE (deleted_at IS NOT NULL) = $1
+		AND (to_tsvector('simple', title) @@ plainto_tsquery('simple', $2) OR $2 = '')
+		AND (genres @> $3 OR $3 = '{}')
+		ORDER BY %s %s, id ASC`, columns, filters.sortColumn(), filters.sortDirection())
+}
+
+//exportBatchSize is how many movies Export() fetches from its cursor at a time.
+const exportBatchSize = 1000
+
+//Export calls fn for every movie which matches the filters, in order, and returns the first error fn returns. Unlike
+//GetAll() it ignores paging and never holds more than one batch of movies in memory, as it reads them from a
+//server-side cursor. The cursor sees the movies as they were when the export started.
+func (m MovieModel) Export(ctx context.Context, title string, genres []string, filters Filters, deleted bool, fn func(movie *Movie) error) error {
+	//There's no overall timeout, as an export takes as long as the client takes to read it, but it stops as soon as ctx
+	//is cancelled
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := `DECLARE movie_export NO SCROLL CURSOR FOR ` + movieListQuery(selectMovie(nil).columns(), filters)
+
+		_, err := tx.ExecContext(ctx, query, deleted, title, pq.Array(genres))
+		if err != nil {
+			return err
+		}
+
+		for {
+			batch, err := fetchMovies(ctx, tx, "movie_export", exportBatchSize)
+			if err != nil {
+				return err
+			}
+
+			//The batch is read in full before fn is called, so a slow client can't hold up the query
+			for _, movie := range batch {
+				if err := fn(movie); err != nil {
+					return err
+				}
+			}
+
+			if len(batch) < exportBatchSize {
+				return nil
+			}
+		}
+	})
+}
+
+//fetchMovies fetches the next n movies from a cursor.
+func fetchMovies(ctx context.Context, tx *sql.Tx, cursor string, n int) ([]*Movie, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("FETCH %d FROM %s", n, cursor))
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return movies, nil
+	movies := make([]*Movie, 0, n)
+
+	for rows.Next() {
+		movie, err := scanMovie(rows)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, movie)
+	}
+
+	return movies, rows.Err()
 }
@@ -0,0 +1,51 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+func TestValidateTags(t *testing.T) {
+	err := validator.CheckTags(Movie{}, WebhookSubscription{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateMovie(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(m *Movie)
+		want   map[string]string
+	}{
+		{"valid", func(m *Movie) {}, map[string]string{}},
+		{"missing title", func(m *Movie) { m.Title = "" }, map[string]string{"title": "must be provided"}},
+		{"early year", func(m *Movie) { m.Year = 1800 }, map[string]string{"year": "must be at least 1888"}},
+		{"future year", func(m *Movie) { m.Year = 3000 }, map[string]string{"year": "must not be in the future"}},
+		{"no genres", func(m *Movie) { m.Genres = []string{} }, map[string]string{"genres": "must contain at least 1 item"}},
+		{"too many genres", func(m *Movie) { m.Genres = []string{"a", "b", "c", "d", "e", "f"} }, map[string]string{"genres": "must not contain more than 5 items"}},
+		{"duplicate genres", func(m *Movie) { m.Genres = []string{"a", "a"} }, map[string]string{"genres": "must not contain duplicate values"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := &Movie{Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama", "romance"}}
+			tt.modify(movie)
+
+			v := validator.New()
+			ValidateMovie(v, movie)
+
+			got := make(map[string]string)
+			for field, message := range v.Errors {
+				got[field] = i18n.T("en", message)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
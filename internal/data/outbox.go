@@ -0,0 +1,237 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//The types of event written to the outbox. Restoring a movie counts as creating it, and reverting one as updating it.
+const (
+	EventMovieCreated = "movie.created"
+	EventMovieUpdated = "movie.updated"
+	EventMovieDeleted = "movie.deleted"
+)
+
+//AggregateMovie is the aggregate type of movie events. An aggregate is the thing an event happened to, and its events
+//are always relayed in the order they happened.
+const AggregateMovie = "movie"
+
+//movieEventTypes maps each revision operation to the event it's written to the outbox as.
+var movieEventTypes = map[string]string{
+	RevisionInsert:  EventMovieCreated,
+	RevisionRestore: EventMovieCreated,
+	RevisionUpdate:  EventMovieUpdated,
+	RevisionRevert:  EventMovieUpdated,
+	RevisionDelete:  EventMovieDeleted,
+}
+
+//OutboxEvent is an event as it's stored in the outbox. Position is its place in the order events are relayed in,
+//which is the order their transactions committed. Payload is decoded by the internal/events package.
+type OutboxEvent struct {
+	ID               int64
+	Position         int64
+	AggregateType    string
+	AggregateID      int64
+	AggregateVersion int32
+	Type             string
+	Payload          json.RawMessage
+	OccurredAt       time.Time
+}
+
+//movieEventPayload is the payload of a movie event. Movie is the movie after the change, or as it was when it was
+//deleted, and Previous is the movie before an update.
+type movieEventPayload struct {
+	Movie    *Movie `json:"movie"`
+	Previous *Movie `json:"previous,omitempty"`
+}
+
+//outboxColumns are the columns written for a new event, in the order movieEventRow() returns their values.
+var outboxColumns = []string{"aggregate_type", "aggregate_id", "aggregate_version", "event_type", "payload"}
+
+//recordEvent writes a change to a movie to the outbox. It must be called in the same transaction as the change, so
+//the event is relayed if, and only if, the change is committed.
+func recordEvent(ctx context.Context, tx *sql.Tx, operation string, before, after *Movie) error {
+	row, err := movieEventRow(operation, before, after)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox (aggregate_type, aggregate_id, aggregate_version, event_type, payload)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = tx.ExecContext(ctx, query, row...)
+	return err
+}
+
+//movieEventRow returns the values of outboxColumns for a change to a movie.
+func movieEventRow(operation string, before, after *Movie) ([]interface{}, error) {
+	eventType, ok := movieEventTypes[operation]
+	if !ok {
+		return nil, errors.New("no event for operation " + operation)
+	}
+
+	payload := movieEventPayload{Movie: after}
+
+	switch {
+	case after == nil:
+		//The deleted movie is given the version its tombstone was
+		deleted := *before
+		deleted.Version++
+		payload.Movie = &deleted
+	case eventType == EventMovieUpdated:
+		payload.Previous = before
+	}
+
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{AggregateMovie, payload.Movie.ID, payload.Movie.Version, eventType, string(js)}, nil
+}
+
+//Define 'OutboxModel' struct which wraps a sql.DB connection pool. Events are only written by MovieModel, so this
+//model reads them, and keeps track of how far each handler has got through them.
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+//After returns up to limit events after the given position, in order. Events whose transactions haven't committed
+//yet have no position, and will come after every event returned.
+func (m OutboxModel) After(position int64, limit int) ([]*OutboxEvent, error) {
+	query := `
+		SELECT id, position, aggregate_type, aggregate_id, aggregate_version, event_type, payload, occurred_at
+		FROM outbox
+		WHERE position > $1
+		ORDER BY position
+		LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, position, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*OutboxEvent{}
+
+	for rows.Next() {
+		var event OutboxEvent
+		var payload []byte
+
+		err := rows.Scan(
+			&event.ID,
+			&event.Position,
+			&event.AggregateType,
+			&event.AggregateID,
+			&event.AggregateVersion,
+			&event.Type,
+			&payload,
+			&event.OccurredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Payload = payload
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+//Checkpoint returns the position of the last event the named handler has dealt with, or 0 if it hasn't dealt with any.
+func (m OutboxModel) Checkpoint(handler string) (int64, error) {
+	query := `
+		SELECT position
+		FROM event_checkpoints
+		WHERE handler = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var position int64
+
+	err := m.DB.QueryRowContext(ctx, query, handler).Scan(&position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, nil
+		default:
+			return 0, err
+		}
+	}
+
+	return position, nil
+}
+
+//SaveCheckpoint records that the named handler has dealt with every event up to position. A checkpoint never moves
+//backwards, so a stale save by another instance can't cause events to be handled again.
+func (m OutboxModel) SaveCheckpoint(handler string, position int64) error {
+	query := `
+		INSERT INTO event_checkpoints (handler, position)
+		VALUES ($1, $2)
+		ON CONFLICT (handler) DO UPDATE
+		SET position = GREATEST(event_checkpoints.position, EXCLUDED.position), updated_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, handler, position)
+	return err
+}
+
+//PurgeBefore deletes events which happened before the cutoff and which every one of the named handlers has dealt
+//with. It returns how many events were deleted. Nothing is deleted while any of the handlers has no checkpoint.
+func (m OutboxModel) PurgeBefore(cutoff time.Time, handlers []string) (int64, error) {
+	query := `
+		DELETE FROM outbox
+		WHERE occurred_at < $1 AND position <= (
+			SELECT CASE WHEN count(*) = cardinality($2::text[]) THEN min(position) ELSE 0 END
+			FROM event_checkpoints
+			WHERE handler = ANY($2))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, cutoff, pq.Array(handlers))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+type MockOutboxModel struct{}
+
+func (m MockOutboxModel) After(position int64, limit int) ([]*OutboxEvent, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockOutboxModel) Checkpoint(handler string) (int64, error) {
+	//Mock the action...
+	return 0, nil
+}
+
+func (m MockOutboxModel) SaveCheckpoint(handler string, position int64) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockOutboxModel) PurgeBefore(cutoff time.Time, handlers []string) (int64, error) {
+	//Mock the action...
+	return 0, nil
+}
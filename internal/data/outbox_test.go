@@ -0,0 +1,62 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMovieEventRow(t *testing.T) {
+	before := &Movie{ID: 7, Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Version: 1}
+	after := &Movie{ID: 7, Title: "Alien", Year: 1979, Runtime: 116, Genres: []string{"horror"}, Version: 2}
+
+	tests := []struct {
+		operation string
+		before    *Movie
+		after     *Movie
+		eventType string
+		version   int32
+		previous  bool
+	}{
+		{RevisionInsert, nil, before, EventMovieCreated, 1, false},
+		{RevisionRestore, nil, before, EventMovieCreated, 1, false},
+		{RevisionUpdate, before, after, EventMovieUpdated, 2, true},
+		{RevisionRevert, before, after, EventMovieUpdated, 2, true},
+		{RevisionDelete, after, nil, EventMovieDeleted, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			row, err := movieEventRow(tt.operation, tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(row) != len(outboxColumns) {
+				t.Fatalf("got %d values for %d columns", len(row), len(outboxColumns))
+			}
+
+			if row[0] != AggregateMovie || row[1] != int64(7) || row[2] != tt.version || row[3] != tt.eventType {
+				t.Errorf("got %v", row[:4])
+			}
+
+			var payload struct {
+				Movie    *Movie `json:"movie"`
+				Previous *Movie `json:"previous"`
+			}
+			if err := json.Unmarshal([]byte(row[4].(string)), &payload); err != nil {
+				t.Fatal(err)
+			}
+
+			if payload.Movie == nil || payload.Movie.Version != tt.version {
+				t.Errorf("got payload movie %+v, want version %d", payload.Movie, tt.version)
+			}
+			if (payload.Previous != nil) != tt.previous {
+				t.Errorf("got previous %+v, want it set %t", payload.Previous, tt.previous)
+			}
+		})
+	}
+
+	if _, err := movieEventRow(RevisionPurge, before, nil); err == nil {
+		t.Error("a purge, which has no event, gave no error")
+	}
+}
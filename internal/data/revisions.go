@@ -0,0 +1,249 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//The operations recorded in the movie_revisions table. A purge is the permanent removal of a soft deleted movie.
+const (
+	RevisionInsert  = "insert"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+	RevisionPurge   = "purge"
+)
+
+//Audit identifies who made a change, and the request it was made in, so it can be recorded in the movie's history.
+type Audit struct {
+	Actor     string
+	RequestID string
+}
+
+//Revision is one entry in a movie's history. Before and After are JSON snapshots of the movie either side of the
+//change, and are null for inserts and deletes respectively. Version is the movie's version after the change.
+type Revision struct {
+	ID        int64           `json:"id"`
+	MovieID   int64           `json:"movie_id"`
+	Version   int32           `json:"version"`
+	Operation string          `json:"operation"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+//Snapshot returns the state of the movie at this revision's version. For deletes, that's the movie as it was when
+//it was deleted.
+func (rev *Revision) Snapshot() (*Movie, error) {
+	data := rev.After
+	if data == nil {
+		data = rev.Before
+	}
+
+	var movie Movie
+
+	err := json.Unmarshal(data, &movie)
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+//recordRevision writes a revision for a change to a movie. It must be called in the same transaction as the change,
+//so the history can never disagree with the movies table. At least one of before and after must be non-nil.
+func recordRevision(ctx context.Context, tx *sql.Tx, operation string, before, after *Movie, audit Audit) error {
+	query := `
+		INSERT INTO movie_revisions (movie_id, version, operation, before, after, actor, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	current := after
+	if current == nil {
+		//For a delete, the revision's version is the one the tombstone was given, and for a purge it's the one after
+		//the tombstone's
+		current = &Movie{ID: before.ID, Version: before.Version + 1}
+	}
+
+	beforeJSON, err := snapshotJSON(before)
+	if err != nil {
+		return err
+	}
+
+	afterJSON, err := snapshotJSON(after)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{current.ID, current.Version, operation, beforeJSON, afterJSON, audit.Actor, audit.RequestID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+//snapshotJSON encodes a movie for the before and after columns, returning nil (SQL NULL) for a nil movie.
+func snapshotJSON(movie *Movie) (interface{}, error) {
+	if movie == nil {
+		return nil, nil
+	}
+
+	js, err := json.Marshal(movie)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(js), nil
+}
+
+//Define 'RevisionModel' struct which wraps a sql.DB connection pool. Revisions are only written by MovieModel,
+//so this model only reads them.
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+//GetAllForMovie returns a movie's history, oldest first. Revisions are kept after a movie is deleted or purged.
+func (m RevisionModel) GetAllForMovie(movieID int64) ([]*Revision, error) {
+	query := `
+		SELECT id, movie_id, version, operation, before, after, actor, request_id, created_at
+		FROM movie_revisions
+		WHERE movie_id = $1
+		ORDER BY version, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+//GetAllForMovies returns the history of several movies in one query, keyed by movie ID, for loading them in a batch.
+//Movies with no revisions aren't in the map.
+func (m RevisionModel) GetAllForMovies(movieIDs []int64) (map[int64][]*Revision, error) {
+	query := `
+		SELECT id, movie_id, version, operation, before, after, actor, request_id, created_at
+		FROM movie_revisions
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id, version, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make(map[int64][]*Revision)
+
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions[rev.MovieID] = append(revisions[rev.MovieID], rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+//GetForVersion returns the revision which took a movie to the given version.
+func (m RevisionModel) GetForVersion(movieID int64, version int32) (*Revision, error) {
+	query := `
+		SELECT id, movie_id, version, operation, before, after, actor, request_id, created_at
+		FROM movie_revisions
+		WHERE movie_id = $1 AND version = $2
+		ORDER BY id DESC
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rev, err := scanRevision(m.DB.QueryRowContext(ctx, query, movieID, version))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return rev, nil
+}
+
+//scanRevision scans a row from the movie_revisions table. The snapshots are scanned into plain byte slices first,
+//as database/sql only copies the driver's buffer for *[]byte destinations.
+func scanRevision(row rowScanner) (*Revision, error) {
+	var rev Revision
+	var before, after []byte
+
+	err := row.Scan(
+		&rev.ID,
+		&rev.MovieID,
+		&rev.Version,
+		&rev.Operation,
+		&before,
+		&after,
+		&rev.Actor,
+		&rev.RequestID,
+		&rev.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rev.Before = before
+	rev.After = after
+
+	return &rev, nil
+}
+
+type MockRevisionModel struct{}
+
+func (m MockRevisionModel) GetAllForMovie(movieID int64) ([]*Revision, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockRevisionModel) GetAllForMovies(movieIDs []int64) (map[int64][]*Revision, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockRevisionModel) GetForVersion(movieID int64, version int32) (*Revision, error) {
+	//Mock the action...
+	return nil, nil
+}
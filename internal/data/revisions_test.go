@@ -0,0 +1,53 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRevisionSnapshot(t *testing.T) {
+	before := &Movie{ID: 1, Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Version: 1}
+	after := &Movie{ID: 1, Title: "Aliens", Year: 1986, Runtime: 137, Genres: []string{"action"}, Version: 2}
+
+	tests := []struct {
+		name   string
+		before *Movie
+		after  *Movie
+		want   *Movie
+	}{
+		{"insert", nil, before, before},
+		{"update", before, after, after},
+		{"delete", after, nil, after},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rev := &Revision{Before: snapshot(t, tt.before), After: snapshot(t, tt.after)}
+
+			got, err := rev.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+//snapshot returns a movie as it is stored in a revision's before or after column.
+func snapshot(t *testing.T, movie *Movie) json.RawMessage {
+	t.Helper()
+
+	js, err := snapshotJSON(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if js == nil {
+		return nil
+	}
+
+	return json.RawMessage(js.(string))
+}
@@ -1,22 +1,120 @@
 package data
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-//Define an error that our UnmarshalJSON() method can return if we're unable to parse or convert to the JSON string successfully
-var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
+//Define the errors that our parsing methods can return if we're unable to parse or convert the runtime successfully
+var (
+	ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
+	ErrNegativeRuntime      = errors.New("runtime must not be negative")
+	ErrRuntimeOverflow      = errors.New("runtime is too large")
+)
 
 type Runtime int32 //Declare custom 'Runtime' type as type int32
 
+//RuntimeFormat is one of the ways a runtime can be written in responses.
+type RuntimeFormat string
+
+const (
+	RuntimeFormatMins    RuntimeFormat = "mins"    //"135 mins", the default
+	RuntimeFormatISO8601 RuntimeFormat = "iso8601" //"PT2H15M"
+	RuntimeFormatInteger RuntimeFormat = "integer" //135
+)
+
+//RuntimeFormats lists the supported output formats, for validating client input.
+var RuntimeFormats = []string{string(RuntimeFormatMins), string(RuntimeFormatISO8601), string(RuntimeFormatInteger)}
+
+//Regular expressions for the runtime formats we accept. Input is lower-cased before matching.
+var (
+	//ISO 8601 durations with days, hours and minutes, such as "PT2H15M" or "P1DT2H"
+	iso8601RuntimeRX = regexp.MustCompile(`^p(?:(\d+)d)?(?:t(?:(\d+)h)?(?:(\d+)m)?)?$`)
+
+	//Hours and/or minutes, such as "2h 15m", "2 hours", "135 min", "135 mins" or "135 minutes"
+	unitsRuntimeRX = regexp.MustCompile(`^(?:(\d+)\s*h(?:rs?|ours?)?)?\s*(?:(\d+)\s*m(?:ins?|inutes?)?)?$`)
+)
+
+//ParseRuntime parses a runtime in any of the formats we accept: "<n> mins" (or min/minutes), "2h 15m",
+//an ISO 8601 duration like "PT2H15M", or a plain number of minutes. Negative values and values which don't
+//fit in an int32 are rejected.
+func ParseRuntime(s string) (Runtime, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if strings.HasPrefix(s, "-") {
+		return 0, ErrNegativeRuntime
+	}
+
+	//A plain number of minutes
+	if s != "" && strings.Trim(s, "0123456789") == "" {
+		return runtimeFromParts([]string{s}, []int64{1})
+	}
+
+	//A duration needs at least one component, and a "T" must be followed by hours or minutes
+	if m := iso8601RuntimeRX.FindStringSubmatch(s); m != nil && s != "p" && !strings.HasSuffix(s, "t") {
+		return runtimeFromParts(m[1:], []int64{24 * 60, 60, 1})
+	}
+
+	if m := unitsRuntimeRX.FindStringSubmatch(s); m != nil && s != "" {
+		return runtimeFromParts(m[1:], []int64{60, 1})
+	}
+
+	return 0, ErrInvalidRuntimeFormat
+}
+
+//runtimeFromParts multiplies each numeric part by the number of minutes in its unit and adds them up,
+//checking for overflow as it goes. Empty parts are skipped.
+func runtimeFromParts(parts []string, minutes []int64) (Runtime, error) {
+	var total int64
+
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n > math.MaxInt32/minutes[i] {
+			return 0, ErrRuntimeOverflow
+		}
+
+		total += n * minutes[i]
+		if total > math.MaxInt32 {
+			return 0, ErrRuntimeOverflow
+		}
+	}
+
+	return Runtime(total), nil
+}
+
+//ISO8601 returns the runtime as an ISO 8601 duration, such as "PT2H15M".
+func (r Runtime) ISO8601() string {
+	hours, mins := r/60, r%60
+
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("PT%dM", mins)
+	case mins == 0:
+		return fmt.Sprintf("PT%dH", hours)
+	default:
+		return fmt.Sprintf("PT%dH%dM", hours, mins)
+	}
+}
+
+//String returns the runtime in our default "<runtime> mins" format.
+func (r Runtime) String() string {
+	return fmt.Sprintf("%d mins", r)
+}
+
 //Implement a MarshalJSON() method on the Runtime type so that it satisfies the json.Marshaler interface
 //This should return the JSON-encoded value for the movie runtime (in our case, it will return a string in the format "<runtime> mins").
 func (r Runtime) MarshalJSON() ([]byte, error) {
 	//Generate a string containing the movie runtime in the required format
-	jsonValue := fmt.Sprintf("%d mins", r)
+	jsonValue := r.String()
 
 	//Use the strconv.Quote() function on the string to wrap it in double quotes.
 	//Needs to be surrounded by double quotes in order to be a valid *JSON string*.
@@ -30,31 +128,102 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 //IMPORTANT: Because UnmarshalJSON() needs to modify the receiver (our Runtime type), we must use a pointer receiver for this to work
 //correctly. Otherwise, we will only be modifying a copy (which is then discarded when this method returns).
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	//We expect that the incoming JSON value will be a string in the format:
-	//"<runtime> mins", and the first thing we need to do is remove the surrounding double-quotes from this string.
-	//If we can't unqoute it, then we return our error 'ErrInvalidRuntimeFormat' error
-	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	//The incoming JSON value is either a string in one of the formats ParseRuntime() accepts, or a bare number of minutes.
+	//If it isn't a quoted string, we parse it as a number, which rejects fractions and exponents.
+	s := string(jsonValue)
+
+	if strings.HasPrefix(s, `"`) {
+		unquotedJSONValue, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+		s = unquotedJSONValue
+	}
+
+	runtime, err := ParseRuntime(s)
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
 
-	//Split the string to isolate the part containing the number
-	parts := strings.Split(unquotedJSONValue, " ")
+	//Assign the parsed runtime to the receiver. Note that we use the * operator to deference the receiver.
+	//(which is a pointer to a Runtime type) in order to set the underlying value of the pointer
+	*r = runtime
 
-	//Sanity check the parts of the string to make sure it was in the expected format.
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRuntimeFormat
-	}
+	return nil
+}
 
-	//Otherwise, parse the string containing the number into an int32.
-	i, err := strconv.ParseInt(parts[0], 10, 32)
+//MarshalText implements encoding.TextMarshaler, so the runtime is written as "<runtime> mins" in CSV and query strings.
+func (r Runtime) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+//UnmarshalText implements encoding.TextUnmarshaler, accepting any of the formats ParseRuntime() does.
+func (r *Runtime) UnmarshalText(text []byte) error {
+	runtime, err := ParseRuntime(string(text))
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
 
-	//Convert the int32 to a Runtime type and assign this to the receiver. Note that we use the * operator to deference the receiver.
-	//(which is a pointer to a Runtime type) in order to set the underlying value of the pointer
-	*r = Runtime(i)
+	*r = runtime
 
 	return nil
 }
+
+//Value implements driver.Valuer, storing the runtime in the database as an integer number of minutes.
+func (r Runtime) Value() (driver.Value, error) {
+	return int64(r), nil
+}
+
+//Scan implements sql.Scanner, reading the runtime back from an integer column.
+func (r *Runtime) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		if v < 0 {
+			return ErrNegativeRuntime
+		}
+		if v > math.MaxInt32 {
+			return ErrRuntimeOverflow
+		}
+		*r = Runtime(v)
+		return nil
+	case []byte:
+		return r.UnmarshalText(v)
+	case string:
+		return r.UnmarshalText([]byte(v))
+	case nil:
+		*r = 0
+		return nil
+	}
+
+	return fmt.Errorf("cannot scan %T into Runtime", src)
+}
+
+//Format returns the runtime as a value ready to be encoded in the given format: a string for "mins" and "iso8601",
+//and an integer for "integer". Unknown formats fall back to "mins".
+func (r Runtime) Format(format RuntimeFormat) interface{} {
+	switch format {
+	case RuntimeFormatISO8601:
+		return r.ISO8601()
+	case RuntimeFormatInteger:
+		return int32(r)
+	default:
+		return r.String()
+	}
+}
+
+//FormattedRuntime wraps a runtime with the format it should be written in. It lets a response use a format other than
+//the default without changing how Runtime itself is encoded.
+type FormattedRuntime struct {
+	Runtime Runtime
+	Format  RuntimeFormat
+}
+
+//MarshalJSON writes the runtime in the chosen format.
+func (f FormattedRuntime) MarshalJSON() ([]byte, error) {
+	switch f.Format {
+	case RuntimeFormatInteger:
+		return []byte(strconv.FormatInt(int64(f.Runtime), 10)), nil
+	default:
+		return []byte(strconv.Quote(f.Runtime.Format(f.Format).(string))), nil
+	}
+}
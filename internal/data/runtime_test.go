@@ -0,0 +1,133 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Runtime
+		err  error
+	}{
+		{"135", 135, nil},
+		{"135 mins", 135, nil},
+		{"135 min", 135, nil},
+		{"135minutes", 135, nil},
+		{"2h 15m", 135, nil},
+		{"2 hours 15 minutes", 135, nil},
+		{"2hrs", 120, nil},
+		{" 2H15M ", 135, nil},
+		{"PT2H15M", 135, nil},
+		{"pt45m", 45, nil},
+		{"P1DT1H", 1500, nil},
+		{"P1D", 1440, nil},
+		{"0", 0, nil},
+		{"", 0, ErrInvalidRuntimeFormat},
+		{"P", 0, ErrInvalidRuntimeFormat},
+		{"PT", 0, ErrInvalidRuntimeFormat},
+		{"P1DT", 0, ErrInvalidRuntimeFormat},
+		{"PT1S", 0, ErrInvalidRuntimeFormat},
+		{"1.5", 0, ErrInvalidRuntimeFormat},
+		{"2 days", 0, ErrInvalidRuntimeFormat},
+		{"15m 2h", 0, ErrInvalidRuntimeFormat},
+		{"-5", 0, ErrNegativeRuntime},
+		{"-PT5M", 0, ErrNegativeRuntime},
+		{"2147483647", 2147483647, nil},
+		{"2147483648", 0, ErrRuntimeOverflow},
+		{"99999999999999999999", 0, ErrRuntimeOverflow},
+		{"35791394h 8m", 0, ErrRuntimeOverflow},
+		{"P1491309DT23H", 0, ErrRuntimeOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRuntime(tt.in)
+			if !errors.Is(err, tt.err) || got != tt.want {
+				t.Errorf("got %d, %v, want %d, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestRuntimeFormat(t *testing.T) {
+	tests := []struct {
+		runtime Runtime
+		format  RuntimeFormat
+		want    string
+	}{
+		{135, RuntimeFormatMins, `"135 mins"`},
+		{135, RuntimeFormatISO8601, `"PT2H15M"`},
+		{120, RuntimeFormatISO8601, `"PT2H"`},
+		{45, RuntimeFormatISO8601, `"PT45M"`},
+		{0, RuntimeFormatISO8601, `"PT0M"`},
+		{135, RuntimeFormatInteger, `135`},
+		{135, "unknown", `"135 mins"`},
+	}
+
+	for _, tt := range tests {
+		js, err := json.Marshal(FormattedRuntime{Runtime: tt.runtime, Format: tt.format})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(js) != tt.want {
+			t.Errorf("%d as %s: got %s, want %s", tt.runtime, tt.format, js, tt.want)
+		}
+	}
+}
+
+func TestRuntimeJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Runtime
+		err  error
+	}{
+		{`"135 mins"`, 135, nil},
+		{`"PT2H15M"`, 135, nil},
+		{`135`, 135, nil},
+		{`1.5`, 0, ErrInvalidRuntimeFormat},
+		{`1e3`, 0, ErrInvalidRuntimeFormat},
+		{`-1`, 0, ErrNegativeRuntime},
+		{`"\x"`, 0, ErrInvalidRuntimeFormat},
+	}
+
+	for _, tt := range tests {
+		var got Runtime
+		err := got.UnmarshalJSON([]byte(tt.in))
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("%s: got %d, %v, want %d, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+
+	js, err := json.Marshal(Runtime(135))
+	if err != nil || string(js) != `"135 mins"` {
+		t.Errorf("got %s, %v, want \"135 mins\"", js, err)
+	}
+}
+
+func TestRuntimeScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Runtime
+		ok   bool
+	}{
+		{int64(135), 135, true},
+		{[]byte("135"), 135, true},
+		{"PT2H", 120, true},
+		{nil, 0, true},
+		{int64(-1), 0, false},
+		{int64(1 << 32), 0, false},
+		{1.5, 0, false},
+	}
+
+	for _, tt := range tests {
+		var got Runtime
+		err := got.Scan(tt.src)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Scan(%#v) = %d, %v, want %d (ok %t)", tt.src, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+//SchemaVersion returns the current version and dirty flag recorded by golang-migrate in the schema_migrations table.
+//If no migrations have been applied yet, it returns a version of 0.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int64, bool, error) {
+	query := `
+		SELECT version, dirty
+		FROM schema_migrations
+		LIMIT 1`
+
+	var version int64
+	var dirty bool
+
+	err := db.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, false, nil
+		default:
+			return 0, false, err
+		}
+	}
+
+	return version, dirty, nil
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+//withTx runs fn inside a transaction, committing if it returns nil and rolling back otherwise.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	//Rollback is a no-op once the transaction has been committed, so it's always safe to defer
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//scanMovie scans the columns "id, created_at, title, year, runtime, genres, version, external_id", in that order, into a new movie.
+func scanMovie(row rowScanner) (*Movie, error) {
+	var movie Movie
+
+	err := row.Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+		&movie.ExternalID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
@@ -0,0 +1,533 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+
+	"github.com/lib/pq"
+)
+
+//The events webhook subscriptions can ask for. Restoring a movie counts as creating it, and reverting one as updating it.
+const (
+	WebhookMovieCreated = EventMovieCreated
+	WebhookMovieUpdated = EventMovieUpdated
+	WebhookMovieDeleted = EventMovieDeleted
+)
+
+//WebhookEvents lists every event, in the order they are documented.
+var WebhookEvents = []string{WebhookMovieCreated, WebhookMovieUpdated, WebhookMovieDeleted}
+
+//The states of a webhook delivery. A pending delivery is waiting for its next attempt, and a dead one has run out of
+//attempts and won't be tried again unless it's retried by hand.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+//WebhookSubscription asks for movie events to be POSTed to a URL. An empty Events list means every event. The secret
+//signs the requests, and is only shown when the subscription is created.
+type WebhookSubscription struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url" validate:"required,max=2000"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events" validate:"unique,dive,oneof=movie.created movie.updated movie.deleted"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int32     `json:"version"`
+}
+
+//ValidateWebhookSubscription checks a subscription against the rules in its validate tags, that its URL is an absolute
+//http or https URL, and that its secret is long enough to be hard to guess. The secret isn't in the JSON, so it can't
+//have a validate tag.
+func ValidateWebhookSubscription(v *validator.Validator, sub *WebhookSubscription) {
+	validator.ValidateStruct(v, sub)
+
+	v.Check(len(sub.Secret) >= 16, "secret", i18n.Key("validation.min_length", "16"))
+	v.Check(len(sub.Secret) <= 200, "secret", i18n.Key("validation.max_length", "200"))
+
+	u, err := url.Parse(sub.URL)
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "validation.url")
+}
+
+//WebhookDelivery is one event being sent to one subscription. Payload is the request body, which is the same for every
+//attempt. URL and Secret are only set on deliveries returned by ClaimDue(), for sending them.
+type WebhookDelivery struct {
+	ID             int64             `json:"id"`
+	SubscriptionID int64             `json:"subscription_id"`
+	EventID        int64             `json:"event_id"`
+	EventType      string            `json:"event_type"`
+	Payload        json.RawMessage   `json:"payload"`
+	Status         string            `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	LastError      string            `json:"last_error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	AttemptLog     []*WebhookAttempt `json:"attempt_log,omitempty"`
+	URL            string            `json:"-"`
+	Secret         string            `json:"-"`
+}
+
+//WebhookAttempt records one try at sending a delivery. StatusCode is 0 if no response was received.
+type WebhookAttempt struct {
+	ID           int64     `json:"id"`
+	DeliveryID   int64     `json:"delivery_id"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
+
+//Define 'WebhookModel' struct which wraps a sql.DB connection pool. It holds the subscriptions and their deliveries.
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+//Insert adds a subscription, filling in its ID, creation time and version.
+func (m WebhookModel) Insert(sub *WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (url, secret, events, active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{sub.URL, sub.Secret, pq.Array(sub.Events), sub.Active}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&sub.ID, &sub.CreatedAt, &sub.Version)
+}
+
+//Get fetches a subscription, including its secret.
+func (m WebhookModel) Get(id int64) (*WebhookSubscription, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, url, secret, events, active, created_at, version
+		FROM webhook_subscriptions
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	sub, err := scanWebhookSubscription(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return sub, nil
+}
+
+//GetAll returns every subscription, oldest first.
+func (m WebhookModel) GetAll() ([]*WebhookSubscription, error) {
+	query := `
+		SELECT id, url, secret, events, active, created_at, version
+		FROM webhook_subscriptions
+		ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []*WebhookSubscription{}
+
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		subs = append(subs, sub)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+//Update saves a subscription if it's still at the version it was read at, returning ErrEditConflict if it isn't.
+func (m WebhookModel) Update(sub *WebhookSubscription) error {
+	query := `
+		UPDATE webhook_subscriptions
+		SET url = $1, secret = $2, events = $3, active = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{sub.URL, sub.Secret, pq.Array(sub.Events), sub.Active, sub.ID, sub.Version}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&sub.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Delete removes a subscription, along with its deliveries.
+func (m WebhookModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+//Enqueue creates a delivery of an event for every active subscription which wants it, and returns how many were
+//created. Each subscription gets an event at most once, so it's safe to enqueue the same event again. The payload is
+//the body sent to the subscribers, with data holding the event itself.
+func (m WebhookModel) Enqueue(eventID int64, eventType string, occurredAt time.Time, data interface{}) (int64, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":         eventID,
+		"type":       eventType,
+		"created_at": occurredAt,
+		"data":       data,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_subscriptions
+		WHERE active AND (cardinality(events) = 0 OR $2 = ANY(events))
+		ORDER BY id
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, eventID, eventType, string(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+//ClaimDue returns up to limit pending deliveries whose next attempt is due, for active subscriptions. Each one's next
+//attempt is pushed back by the lease, so no other worker picks it up while it's being sent. If the worker dies before
+//recording the attempt, the delivery is tried again once the lease runs out.
+func (m WebhookModel) ClaimDue(limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * interval '1 millisecond'
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT wd.id
+			FROM webhook_deliveries wd
+			JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+			WHERE wd.status = 'pending' AND wd.next_attempt_at <= NOW() AND ws.active
+			ORDER BY wd.next_attempt_at, wd.id
+			LIMIT $1
+			FOR UPDATE OF wd SKIP LOCKED)
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.attempts, s.url, s.secret`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Attempts, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Payload = payload
+		d.Status = DeliveryPending
+		deliveries = append(deliveries, &d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+//RecordAttempt records an attempt at sending a delivery, and moves the delivery on to its new status. A delivery
+//which is still pending is next tried at nextAttemptAt.
+func (m WebhookModel) RecordAttempt(deliveryID int64, attempt *WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, response_body, duration_ms)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, attempted_at`
+
+		args := []interface{}{deliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.ResponseBody, attempt.DurationMS}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&attempt.ID, &attempt.AttemptedAt)
+		if err != nil {
+			return err
+		}
+
+		attempt.DeliveryID = deliveryID
+
+		query = `
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, updated_at = NOW()
+			WHERE id = $5`
+
+		_, err = tx.ExecContext(ctx, query, status, attempt.Attempt, nextAttemptAt, attempt.Error, deliveryID)
+		return err
+	})
+}
+
+//GetDeliveries returns a page of a subscription's deliveries, with every attempt at each one, optionally only those
+//with the given status.
+func (m WebhookModel) GetDeliveries(subscriptionID int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	query := `
+		SELECT count(*) OVER(), id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			last_error, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND (status = $2 OR $2 = '')
+		ORDER BY ` + filters.sortColumn() + ` ` + filters.sortDirection() + `
+		LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, subscriptionID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+	byID := make(map[int64]*WebhookDelivery)
+	ids := []int64{}
+
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+
+		err := rows.Scan(&totalRecords, &d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status,
+			&d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		d.Payload = payload
+		deliveries = append(deliveries, &d)
+		byID[d.ID] = &d
+		ids = append(ids, d.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	//Load the attempts at every delivery on the page in one query, rather than one query per delivery
+	query = `
+		SELECT id, delivery_id, attempt, status_code, error, response_body, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1)
+		ORDER BY id`
+
+	attemptRows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var a WebhookAttempt
+
+		err := attemptRows.Scan(&a.ID, &a.DeliveryID, &a.Attempt, &a.StatusCode, &a.Error, &a.ResponseBody, &a.DurationMS, &a.AttemptedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if d, ok := byID[a.DeliveryID]; ok {
+			d.AttemptLog = append(d.AttemptLog, &a)
+		}
+	}
+
+	if err = attemptRows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return deliveries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+//Retry queues a delivery to be sent again straight away, with a fresh set of attempts. It's meant for dead deliveries,
+//once whatever was wrong with the receiver has been fixed, but works for any delivery.
+func (m WebhookModel) Retry(subscriptionID, deliveryID int64) (*WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND subscription_id = $2
+		RETURNING id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_error,
+			created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var d WebhookDelivery
+	var payload []byte
+
+	err := m.DB.QueryRowContext(ctx, query, deliveryID, subscriptionID).Scan(&d.ID, &d.SubscriptionID, &d.EventID,
+		&d.EventType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	d.Payload = payload
+
+	return &d, nil
+}
+
+//PurgeDeliveriesBefore removes finished deliveries, and their attempts, which were last updated before the cutoff,
+//and returns how many were removed. Pending deliveries are kept however old they are.
+func (m WebhookModel) PurgeDeliveriesBefore(cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM webhook_deliveries
+		WHERE status <> 'pending' AND updated_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+//scanWebhookSubscription reads a subscription from a row with the columns in the order Get() selects them.
+func scanWebhookSubscription(row rowScanner) (*WebhookSubscription, error) {
+	var sub WebhookSubscription
+
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, pq.Array(&sub.Events), &sub.Active, &sub.CreatedAt, &sub.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+type MockWebhookModel struct{}
+
+func (m MockWebhookModel) Insert(sub *WebhookSubscription) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockWebhookModel) Get(id int64) (*WebhookSubscription, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockWebhookModel) GetAll() ([]*WebhookSubscription, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockWebhookModel) Update(sub *WebhookSubscription) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockWebhookModel) Delete(id int64) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockWebhookModel) Enqueue(eventID int64, eventType string, occurredAt time.Time, data interface{}) (int64, error) {
+	//Mock the action...
+	return 0, nil
+}
+
+func (m MockWebhookModel) ClaimDue(limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockWebhookModel) RecordAttempt(deliveryID int64, attempt *WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	//Mock the action...
+	return nil
+}
+
+func (m MockWebhookModel) GetDeliveries(subscriptionID int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	//Mock the action...
+	return nil, Metadata{}, nil
+}
+
+func (m MockWebhookModel) Retry(subscriptionID, deliveryID int64) (*WebhookDelivery, error) {
+	//Mock the action...
+	return nil, nil
+}
+
+func (m MockWebhookModel) PurgeDeliveriesBefore(cutoff time.Time) (int64, error) {
+	//Mock the action...
+	return 0, nil
+}
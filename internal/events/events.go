@@ -0,0 +1,87 @@
+//Package events relays the domain events written to the outbox to in-process handlers. Events are written by the data
+//models in the same transaction as each change, so an event is relayed if, and only if, its change was committed.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//Event is a typed domain event.
+type Event interface {
+	EventType() string
+}
+
+//MovieCreated is sent when a movie is inserted, or a soft deleted one is restored.
+type MovieCreated struct {
+	Movie *data.Movie `json:"movie"`
+}
+
+//MovieUpdated is sent when a movie is updated or reverted. Previous is the movie before the change.
+type MovieUpdated struct {
+	Movie    *data.Movie `json:"movie"`
+	Previous *data.Movie `json:"previous"`
+}
+
+//MovieDeleted is sent when a movie is deleted. Movie is as it was when it was deleted, with its tombstone's version.
+type MovieDeleted struct {
+	Movie *data.Movie `json:"movie"`
+}
+
+func (e MovieCreated) EventType() string { return data.EventMovieCreated }
+func (e MovieUpdated) EventType() string { return data.EventMovieUpdated }
+func (e MovieDeleted) EventType() string { return data.EventMovieDeleted }
+
+//Message is an event with the details of where it came from. Handlers may see a message more than once, so they
+//should use ID, or AggregateID and AggregateVersion, to ignore ones they have already dealt with.
+type Message struct {
+	ID               int64
+	Position         int64
+	AggregateType    string
+	AggregateID      int64
+	AggregateVersion int32
+	OccurredAt       time.Time
+	Event            Event
+}
+
+//Decode turns an event read from the outbox into a message.
+func Decode(record *data.OutboxEvent) (*Message, error) {
+	var event Event
+	var err error
+
+	switch record.Type {
+	case data.EventMovieCreated:
+		var e MovieCreated
+		err = json.Unmarshal(record.Payload, &e)
+		event = e
+	case data.EventMovieUpdated:
+		var e MovieUpdated
+		err = json.Unmarshal(record.Payload, &e)
+		event = e
+	case data.EventMovieDeleted:
+		var e MovieDeleted
+		err = json.Unmarshal(record.Payload, &e)
+		event = e
+	default:
+		return nil, fmt.Errorf("unknown event type %q", record.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s event %d: %w", record.Type, record.ID, err)
+	}
+
+	msg := &Message{
+		ID:               record.ID,
+		Position:         record.Position,
+		AggregateType:    record.AggregateType,
+		AggregateID:      record.AggregateID,
+		AggregateVersion: record.AggregateVersion,
+		OccurredAt:       record.OccurredAt,
+		Event:            event,
+	}
+
+	return msg, nil
+}
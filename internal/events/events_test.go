@@ -0,0 +1,359 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//memStore is an outbox held in memory.
+type memStore struct {
+	events      []*data.OutboxEvent
+	checkpoints map[string]int64
+	err         error //returned by every method if set
+}
+
+func (m *memStore) After(position int64, limit int) ([]*data.OutboxEvent, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	var events []*data.OutboxEvent
+	for _, e := range m.events {
+		if e.Position > position && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *memStore) Checkpoint(handler string) (int64, error) {
+	return m.checkpoints[handler], m.err
+}
+
+func (m *memStore) SaveCheckpoint(handler string, position int64) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.checkpoints[handler] = position
+	return nil
+}
+
+func (m *memStore) PurgeBefore(cutoff time.Time, handlers []string) (int64, error) {
+	return 0, m.err
+}
+
+//newMemStore returns a store holding n movie events, alternating between two movies.
+func newMemStore(t *testing.T, n int) *memStore {
+	t.Helper()
+
+	store := &memStore{checkpoints: make(map[string]int64)}
+
+	for i := 1; i <= n; i++ {
+		movie := &data.Movie{ID: int64(i%2 + 1), Title: "Alien", Version: int32(i)}
+
+		payload, err := json.Marshal(map[string]interface{}{"movie": movie})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store.events = append(store.events, &data.OutboxEvent{
+			ID:               int64(i),
+			Position:         int64(i),
+			AggregateType:    data.AggregateMovie,
+			AggregateID:      movie.ID,
+			AggregateVersion: movie.Version,
+			Type:             data.EventMovieUpdated,
+			Payload:          payload,
+		})
+	}
+
+	return store
+}
+
+func newTestRelay(store Store) *Relay {
+	return NewRelay(store, log.New(io.Discard, "", 0), time.Second, 3, time.Hour)
+}
+
+//recorder returns a handler which records the IDs of the messages it's given, and fails on those failOn returns an
+//error for.
+func recorder(seen *[]int64, failOn func(id int64) error) HandlerFunc {
+	return func(ctx context.Context, msg *Message) error {
+		if failOn != nil {
+			if err := failOn(msg.ID); err != nil {
+				return err
+			}
+		}
+		*seen = append(*seen, msg.ID)
+		return nil
+	}
+}
+
+func TestDecode(t *testing.T) {
+	movie := &data.Movie{ID: 7, Title: "Alien", Version: 2}
+	previous := &data.Movie{ID: 7, Title: "Alein", Version: 1}
+
+	tests := []struct {
+		eventType string
+		payload   string
+		want      Event
+	}{
+		{data.EventMovieCreated, `{"movie":{"id":7,"title":"Alien","version":2}}`, MovieCreated{Movie: movie}},
+		{data.EventMovieUpdated, `{"movie":{"id":7,"title":"Alien","version":2},"previous":{"id":7,"title":"Alein","version":1}}`, MovieUpdated{Movie: movie, Previous: previous}},
+		{data.EventMovieDeleted, `{"movie":{"id":7,"title":"Alien","version":2}}`, MovieDeleted{Movie: movie}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			record := &data.OutboxEvent{ID: 1, Position: 5, AggregateType: data.AggregateMovie, AggregateID: 7, AggregateVersion: 2, Type: tt.eventType, Payload: []byte(tt.payload)}
+
+			msg, err := Decode(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg.ID != 1 || msg.Position != 5 || msg.AggregateID != 7 || msg.AggregateVersion != 2 {
+				t.Errorf("got message %+v", msg)
+			}
+			if msg.Event.EventType() != tt.eventType {
+				t.Errorf("got event type %s, want %s", msg.Event.EventType(), tt.eventType)
+			}
+			if !reflect.DeepEqual(msg.Event, tt.want) {
+				t.Errorf("got %+v, want %+v", msg.Event, tt.want)
+			}
+		})
+	}
+
+	if _, err := Decode(&data.OutboxEvent{Type: "movie.rated", Payload: []byte(`{}`)}); err == nil {
+		t.Error("an unknown event type gave no error")
+	}
+	if _, err := Decode(&data.OutboxEvent{Type: data.EventMovieCreated, Payload: []byte(`{"movie":[]}`)}); err == nil {
+		t.Error("a malformed payload gave no error")
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	store := newMemStore(t, 7)
+	store.checkpoints["behind"] = 5
+
+	relay := newTestRelay(store)
+
+	var fromStart, fromCheckpoint []int64
+	relay.Handle("new", recorder(&fromStart, nil))
+	relay.Handle("behind", recorder(&fromCheckpoint, nil))
+
+	for _, h := range relay.handlers {
+		relay.dispatch(context.Background(), h)
+	}
+
+	//Batches of 3 are read until the handler has caught up
+	if want := []int64{1, 2, 3, 4, 5, 6, 7}; !reflect.DeepEqual(fromStart, want) {
+		t.Errorf("a new handler got %v, want %v", fromStart, want)
+	}
+	if want := []int64{6, 7}; !reflect.DeepEqual(fromCheckpoint, want) {
+		t.Errorf("a handler with a checkpoint got %v, want %v", fromCheckpoint, want)
+	}
+	if store.checkpoints["new"] != 7 || store.checkpoints["behind"] != 7 {
+		t.Errorf("got checkpoints %v", store.checkpoints)
+	}
+
+	//Nothing is given again once the handlers have caught up
+	fromStart, fromCheckpoint = nil, nil
+	store.events = append(store.events, newMemStore(t, 8).events[7])
+
+	for _, h := range relay.handlers {
+		relay.dispatch(context.Background(), h)
+	}
+
+	if !reflect.DeepEqual(fromStart, []int64{8}) || !reflect.DeepEqual(fromCheckpoint, []int64{8}) {
+		t.Errorf("got %v and %v, want only the new event", fromStart, fromCheckpoint)
+	}
+}
+
+func TestDispatchFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		failOn func(id int64) error
+	}{
+		{"error", func(id int64) error {
+			if id == 4 {
+				return errors.New("receiver unavailable")
+			}
+			return nil
+		}},
+		{"panic", func(id int64) error {
+			if id == 4 {
+				panic("nil map")
+			}
+			return nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore(t, 7)
+			relay := newTestRelay(store)
+
+			var failing, healthy []int64
+			relay.Handle("failing", recorder(&failing, tt.failOn))
+			relay.Handle("healthy", recorder(&healthy, nil))
+
+			for _, h := range relay.handlers {
+				relay.dispatch(context.Background(), h)
+			}
+
+			//A failing handler stops at the event it failed on, and holds up only itself
+			if want := []int64{1, 2, 3}; !reflect.DeepEqual(failing, want) {
+				t.Errorf("the failing handler got %v, want %v", failing, want)
+			}
+			if len(healthy) != 7 {
+				t.Errorf("the healthy handler got %v, want every event", healthy)
+			}
+			if store.checkpoints["failing"] != 3 {
+				t.Errorf("got checkpoint %d, want 3", store.checkpoints["failing"])
+			}
+
+			h := relay.handlers[0]
+			if h.failures != 1 || !h.retryAt.After(time.Now()) {
+				t.Errorf("got %d failures and retry at %s", h.failures, h.retryAt)
+			}
+
+			//It isn't retried until its backoff has passed
+			relay.dispatch(context.Background(), h)
+			if len(failing) != 3 {
+				t.Errorf("retried before the backoff passed, got %v", failing)
+			}
+
+			//Then it carries on from the event it failed on
+			h.fn = recorder(&failing, nil)
+			h.retryAt = time.Time{}
+			relay.dispatch(context.Background(), h)
+
+			if want := []int64{1, 2, 3, 4, 5, 6, 7}; !reflect.DeepEqual(failing, want) {
+				t.Errorf("after recovering got %v, want %v", failing, want)
+			}
+			if h.failures != 0 || store.checkpoints["failing"] != 7 {
+				t.Errorf("got %d failures and checkpoint %d", h.failures, store.checkpoints["failing"])
+			}
+		})
+	}
+}
+
+func TestDispatchUndecodableEvent(t *testing.T) {
+	store := newMemStore(t, 3)
+	store.events[1].Type = "movie.rated"
+
+	relay := newTestRelay(store)
+
+	var seen []int64
+	relay.Handle("handler", recorder(&seen, nil))
+	relay.dispatch(context.Background(), relay.handlers[0])
+
+	//An event from a newer release isn't skipped, so the handler waits at it
+	if !reflect.DeepEqual(seen, []int64{1}) || store.checkpoints["handler"] != 1 || relay.handlers[0].failures != 1 {
+		t.Errorf("got %v, checkpoint %d and %d failures", seen, store.checkpoints["handler"], relay.handlers[0].failures)
+	}
+}
+
+func TestDispatchStoreError(t *testing.T) {
+	store := newMemStore(t, 3)
+	store.err = errors.New("connection refused")
+
+	relay := newTestRelay(store)
+
+	var seen []int64
+	relay.Handle("handler", recorder(&seen, nil))
+	relay.dispatch(context.Background(), relay.handlers[0])
+
+	if len(seen) != 0 || relay.handlers[0].loaded {
+		t.Errorf("got %v and loaded %t while the store was down", seen, relay.handlers[0].loaded)
+	}
+
+	store.err = nil
+	relay.dispatch(context.Background(), relay.handlers[0])
+
+	if len(seen) != 3 {
+		t.Errorf("got %v once the store was back", seen)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	relay := newTestRelay(&memStore{})
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := relay.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestHandleTwice(t *testing.T) {
+	relay := newTestRelay(&memStore{})
+	relay.Handle("search", recorder(new([]int64), nil))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a handler twice didn't panic")
+		}
+	}()
+
+	relay.Handle("search", recorder(new([]int64), nil))
+}
+
+func TestRun(t *testing.T) {
+	store := newMemStore(t, 2)
+
+	relay := NewRelay(store, log.New(io.Discard, "", 0), 5*time.Millisecond, 10, time.Hour)
+
+	handled := make(chan int64, 2)
+	relay.Handle("handler", func(ctx context.Context, msg *Message) error {
+		handled <- msg.ID
+		return nil
+	})
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		relay.Run(done)
+		close(stopped)
+	}()
+
+	for _, want := range []int64{1, 2} {
+		select {
+		case got := <-handled:
+			if got != want {
+				t.Errorf("got event %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the relay didn't deliver the events")
+		}
+	}
+
+	close(done)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the relay didn't stop")
+	}
+}
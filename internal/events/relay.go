@@ -0,0 +1,218 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//Store is where the relay reads events from and keeps its handlers' checkpoints. data.Models.Outbox satisfies it.
+type Store interface {
+	After(position int64, limit int) ([]*data.OutboxEvent, error)
+	Checkpoint(handler string) (int64, error)
+	SaveCheckpoint(handler string, position int64) error
+	PurgeBefore(cutoff time.Time, handlers []string) (int64, error)
+}
+
+//HandlerFunc deals with one message. If it returns an error, the message is given to it again later.
+type HandlerFunc func(ctx context.Context, msg *Message) error
+
+//handler is a registered handler and how far it has got through the outbox.
+type handler struct {
+	name     string
+	fn       HandlerFunc
+	position int64     //the position of the last message it dealt with
+	loaded   bool      //whether position has been read from its checkpoint
+	failures int       //how many times in a row it has failed
+	retryAt  time.Time //when to try it again after a failure
+}
+
+//Relay dispatches the events in the outbox to registered handlers, with at-least-once delivery. Each handler is given
+//the events in the order they were committed, so it always sees an aggregate's events in the order they happened, and
+//has its own checkpoint, so a failing handler holds up only itself. A handler which fails is retried with backoff from
+//the event it failed on, and a checkpoint is only saved once the handler has dealt with every event up to it.
+type Relay struct {
+	store     Store
+	logger    *log.Logger
+	interval  time.Duration //how often the outbox is polled
+	batchSize int           //how many events each handler is given per poll
+	maxDelay  time.Duration //the longest wait before a failing handler is retried
+	retention time.Duration //how long events are kept after every handler has dealt with them
+	handlers  []*handler
+}
+
+//NewRelay returns a relay which polls the store every interval, giving each handler up to batchSize events at a time.
+//Events every handler has dealt with are deleted once they are older than retention.
+func NewRelay(store Store, logger *log.Logger, interval time.Duration, batchSize int, retention time.Duration) *Relay {
+	return &Relay{
+		store:     store,
+		logger:    logger,
+		interval:  interval,
+		batchSize: batchSize,
+		maxDelay:  time.Minute,
+		retention: retention,
+	}
+}
+
+//Handle registers a handler under a name, which its checkpoint is saved under, so the name must stay the same between
+//releases. A new handler starts from the oldest event still in the outbox. Handlers must be registered before Run() is
+//called.
+func (r *Relay) Handle(name string, fn HandlerFunc) {
+	for _, h := range r.handlers {
+		if h.name == name {
+			panic(fmt.Sprintf("events: handler %q registered twice", name))
+		}
+	}
+
+	r.handlers = append(r.handlers, &handler{name: name, fn: fn})
+}
+
+//Run relays events until done is closed. The context given to handlers is cancelled when done is closed.
+func (r *Relay) Run(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	poll := time.NewTicker(r.interval)
+	defer poll.Stop()
+
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-poll.C:
+			for _, h := range r.handlers {
+				r.dispatch(ctx, h)
+			}
+
+		case <-purge.C:
+			r.purge()
+
+		case <-done:
+			return
+		}
+	}
+}
+
+//dispatch gives a handler the events after its checkpoint, a batch at a time, until it has caught up, fails, or the
+//relay is stopped.
+func (r *Relay) dispatch(ctx context.Context, h *handler) {
+	if time.Now().Before(h.retryAt) {
+		return
+	}
+
+	if !h.loaded {
+		position, err := r.store.Checkpoint(h.name)
+		if err != nil {
+			r.logger.Printf("event handler %s: loading checkpoint: %v", h.name, err)
+			return
+		}
+
+		h.position = position
+		h.loaded = true
+	}
+
+	for ctx.Err() == nil {
+		records, err := r.store.After(h.position, r.batchSize)
+		if err != nil {
+			r.logger.Printf("event handler %s: reading events: %v", h.name, err)
+			return
+		}
+
+		start := h.position
+
+		for _, record := range records {
+			err = r.deliver(ctx, h, record)
+			if err != nil {
+				break
+			}
+
+			h.position = record.Position
+		}
+
+		if h.position > start {
+			h.failures = 0
+
+			//If the checkpoint can't be saved, the events since the last one will be handled again after a restart
+			if err := r.store.SaveCheckpoint(h.name, h.position); err != nil {
+				r.logger.Printf("event handler %s: saving checkpoint: %v", h.name, err)
+			}
+		}
+
+		if err != nil {
+			h.failures++
+			h.retryAt = time.Now().Add(r.backoff(h.failures))
+			r.logger.Printf("event handler %s: %v (attempt %d, retrying at %s)", h.name, err, h.failures, h.retryAt.Format(time.RFC3339))
+			return
+		}
+
+		if len(records) < r.batchSize {
+			return
+		}
+	}
+}
+
+//deliver decodes an event and gives it to a handler. An event which can't be decoded is treated as a failure too, as
+//it may have been written by a newer release, so it's retried rather than skipped.
+func (r *Relay) deliver(ctx context.Context, h *handler, record *data.OutboxEvent) (err error) {
+	//A handler which panics is treated as having failed, so it doesn't take the relay down with it
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic handling event %d: %v", record.ID, p)
+		}
+	}()
+
+	msg, err := Decode(record)
+	if err != nil {
+		return err
+	}
+
+	err = h.fn(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("handling %s event %d: %w", record.Type, record.ID, err)
+	}
+
+	return nil
+}
+
+//backoff returns how long to wait before retrying a handler which has failed the given number of times in a row. It
+//starts at the poll interval and doubles up to the maximum.
+func (r *Relay) backoff(failures int) time.Duration {
+	delay := r.interval
+
+	for i := 1; i < failures && delay < r.maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > r.maxDelay {
+		delay = r.maxDelay
+	}
+
+	return delay
+}
+
+//purge deletes the events every handler has dealt with which are older than the retention period.
+func (r *Relay) purge() {
+	names := make([]string, len(r.handlers))
+	for i, h := range r.handlers {
+		names[i] = h.name
+	}
+
+	n, err := r.store.PurgeBefore(time.Now().Add(-r.retention), names)
+	if err != nil {
+		r.logger.Printf("purging events: %v", err)
+		return
+	}
+
+	if n > 0 {
+		r.logger.Printf("purged %d events older than %s", n, r.retention)
+	}
+}
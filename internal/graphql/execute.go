@@ -0,0 +1,269 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+//The executor runs an operation one level of the result at a time. It calls the resolvers for every field at a level,
+//across every object at that level, before it calls any Thunks they returned. That gives each Loader the chance to
+//collect every key it will be asked for at that level and fetch them together, rather than once per object.
+//
+//Nulls are handled once the whole result has been built: a field which couldn't be resolved holds invalid, which
+//becomes null if the field is nullable, and otherwise makes its parent null in turn, as the spec requires.
+
+//invalid marks a field whose value couldn't be completed, and an error has already been recorded for it.
+type invalid struct{}
+
+//resultObject is an object in the result, with the type of each of its fields.
+type resultObject struct {
+	keys   []string
+	types  []Type
+	values []interface{}
+}
+
+//resultList is a list in the result.
+type resultList struct {
+	elem  Type
+	items []interface{}
+}
+
+//pendingObject is an object whose fields are resolved at the next level.
+type pendingObject struct {
+	typ    *Object
+	fields []*plannedField
+	source interface{}
+	path   []interface{}
+	result *resultObject
+}
+
+type executor struct {
+	ctx    context.Context
+	errors []*Error
+}
+
+//execute runs the planned fields on the root type and returns the data for the response. The top level fields of a
+//mutation are run one at a time, in order, as each may depend on the changes made by those before it.
+func (ex *executor) execute(root *Object, fields []*plannedField, serial bool) interface{} {
+	result := &resultObject{}
+
+	if serial {
+		for _, f := range fields {
+			ex.run([]*pendingObject{{typ: root, fields: []*plannedField{f}, result: result}})
+		}
+	} else {
+		ex.run([]*pendingObject{{typ: root, fields: fields, result: result}})
+	}
+
+	data, ok := finish(result)
+	if !ok {
+		return jsonNull{}
+	}
+	return data
+}
+
+//slot is a field of an object at the current level, and the value its resolver returned.
+type slot struct {
+	obj   *pendingObject
+	field *plannedField
+	index int //the index of the field's value in obj.result
+	value interface{}
+	err   error
+}
+
+//run resolves the fields of a level's objects, then runs the objects those fields return as the next level.
+func (ex *executor) run(level []*pendingObject) {
+	for len(level) > 0 {
+		var slots []*slot
+
+		//Call every resolver first
+		for _, obj := range level {
+			for _, f := range obj.fields {
+				s := &slot{obj: obj, field: f, index: len(obj.result.keys)}
+
+				obj.result.keys = append(obj.result.keys, f.key)
+				obj.result.values = append(obj.result.values, nil)
+
+				if f.def == nil {
+					obj.result.types = append(obj.result.types, NewNonNull(String))
+					s.value = obj.typ.Name
+				} else {
+					obj.result.types = append(obj.result.types, f.def.Type)
+					s.value, s.err = ex.resolve(obj, f)
+				}
+
+				slots = append(slots, s)
+			}
+		}
+
+		//Then call the thunks, which load whatever the resolvers asked for in batches
+		for _, s := range slots {
+			if thunk, ok := s.value.(Thunk); ok && s.err == nil {
+				s.value, s.err = thunk()
+			}
+		}
+
+		//Then complete the values, collecting the objects they contain for the next level
+		var next []*pendingObject
+
+		for _, s := range slots {
+			path := appendPath(s.obj.path, s.field.key)
+
+			if s.err != nil {
+				ex.addError(s.err, s.field, path)
+				s.obj.result.values[s.index] = invalid{}
+				continue
+			}
+
+			s.obj.result.values[s.index] = ex.complete(s.obj.result.types[s.index], s.obj.typ, s.field, s.value, path, &next)
+		}
+
+		level = next
+	}
+}
+
+//resolve calls a field's resolver, or reads the field from its parent if it has none.
+func (ex *executor) resolve(obj *pendingObject, f *plannedField) (interface{}, error) {
+	if f.def.Resolve == nil {
+		if m, ok := obj.source.(map[string]interface{}); ok {
+			return m[f.name], nil
+		}
+		return nil, nil
+	}
+
+	return f.def.Resolve(ResolveParams{Context: ex.ctx, Source: obj.source, Args: f.args})
+}
+
+//complete converts a resolved value into its place in the result, according to the field's type. Objects are added
+//to next, to be resolved at the next level.
+func (ex *executor) complete(t Type, parent *Object, f *plannedField, v interface{}, path []interface{}, next *[]*pendingObject) interface{} {
+	if nonNull, ok := t.(*NonNull); ok {
+		if isNil(v) {
+			ex.addError(fmt.Errorf("Cannot return null for non-nullable field %s.%s.", parent.Name, f.name), f, path)
+			return invalid{}
+		}
+		return ex.complete(nonNull.OfType, parent, f, v, path, next)
+	}
+
+	if isNil(v) {
+		return nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			ex.addError(fmt.Errorf("Expected a list for field %s.%s, but got %T.", parent.Name, f.name, v), f, path)
+			return invalid{}
+		}
+
+		list := &resultList{elem: t.OfType, items: make([]interface{}, rv.Len())}
+		for i := range list.items {
+			list.items[i] = ex.complete(t.OfType, parent, f, rv.Index(i).Interface(), appendPath(path, i), next)
+		}
+		return list
+
+	case *Object:
+		obj := &resultObject{}
+		*next = append(*next, &pendingObject{typ: t, fields: f.children, source: v, path: path, result: obj})
+		return obj
+
+	case *Enum:
+		value, err := coerceEnum(t, v)
+		if err != nil {
+			ex.addError(err, f, path)
+			return invalid{}
+		}
+		return value
+
+	case *Scalar:
+		value, err := t.Serialize(v)
+		if err != nil {
+			ex.addError(err, f, path)
+			return invalid{}
+		}
+		return value
+	}
+
+	ex.addError(fmt.Errorf("Field %s.%s has an output type %q which can't be returned.", parent.Name, f.name, t), f, path)
+	return invalid{}
+}
+
+//addError records an error for a field, adding its location and path.
+func (ex *executor) addError(err error, f *plannedField, path []interface{}) {
+	e := &Error{Message: err.Error()}
+	if typed, ok := err.(*Error); ok {
+		copied := *typed
+		e = &copied
+	}
+
+	if len(e.Locations) == 0 {
+		e.Locations = []Location{f.loc}
+	}
+	if len(e.Path) == 0 {
+		e.Path = path
+	}
+
+	ex.errors = append(ex.errors, e)
+}
+
+//finish turns a completed value into the value written in the response, propagating nulls. It returns false if the
+//value is null because of an error, so the caller can make itself null if it can't be.
+func finish(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case invalid:
+		return nil, false
+
+	case *resultObject:
+		out := &orderedMap{}
+		for i, key := range v.keys {
+			value, ok := finish(v.values[i])
+			if !ok {
+				if _, nonNull := v.types[i].(*NonNull); nonNull {
+					return nil, false
+				}
+				value = nil
+			}
+			out.set(key, value)
+		}
+		return out, true
+
+	case *resultList:
+		out := make([]interface{}, len(v.items))
+		for i, item := range v.items {
+			value, ok := finish(item)
+			if !ok {
+				if _, nonNull := v.elem.(*NonNull); nonNull {
+					return nil, false
+				}
+				value = nil
+			}
+			out[i] = value
+		}
+		return out, true
+	}
+
+	return v, true
+}
+
+//appendPath returns a copy of path with one more key or index, so paths shared between fields aren't overwritten.
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	p := make([]interface{}, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, elem)
+}
+
+//isNil reports whether v is nil, including nil pointers and maps held in an interface. A nil slice is an empty list,
+//not null.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Interface, reflect.Func:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+//Package graphql is a small GraphQL server: it parses queries and mutations, validates them against a schema defined in
+//Go, and executes them. It supports fragments, variables, aliases and the @skip and @include directives, but not
+//subscriptions, interfaces, unions or introspection. Limits on the depth and complexity of operations stop clients
+//asking for more than the server is willing to do, and Loader batches the data fields need, so that a list of N items
+//doesn't make N more queries.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+//Location is a position in a GraphQL document.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+//Error is an error in the "errors" member of a response. Resolvers can return an *Error with Extensions to give clients
+//a machine readable code; any other error just has its message sent.
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+//Request is a GraphQL request, as sent in the JSON body of a POST.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"` //accepted, as some clients send it, but unused
+}
+
+//Response is the result of a request. Data is nil if the request couldn't be executed at all, and otherwise holds the
+//result, which may still be partial or null if there were errors.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+//orderedMap is a JSON object whose members are written in the order they were set, as the fields of a response must be
+//in the order they were requested.
+type orderedMap struct {
+	keys   []string
+	values []interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+
+		v, err := json.Marshal(m.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+//jsonNull is written as null. A response's Data is set to it when the whole result is null, as a nil Data means the
+//request wasn't executed, and is left out.
+type jsonNull struct{}
+
+func (jsonNull) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+//Execute runs a request. Errors in the request itself, like syntax errors, unknown fields or operations which are too
+//complex, are returned without running anything. Errors from resolvers make their fields null, and are returned along
+//with the rest of the result.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+
+	root := s.Query
+	if op.kind == "mutation" {
+		root = s.Mutation
+		if root == nil {
+			return &Response{Errors: []*Error{{Message: "Schema is not configured for mutations.", Locations: []Location{op.loc}}}}
+		}
+	}
+
+	vars, err := s.coerceVariables(op, req.Variables)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+
+	pl := &planner{schema: s, doc: doc, vars: vars}
+	fields := pl.plan(root, op.selections)
+	if pl.tooComplex {
+		return &Response{Errors: []*Error{s.tooComplexError()}}
+	}
+	if len(pl.errors) > 0 {
+		return &Response{Errors: pl.errors}
+	}
+
+	if err := s.checkLimits(fields); err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+
+	ex := &executor{ctx: ctx}
+	data := ex.execute(root, fields, op.kind == "mutation")
+
+	return &Response{Data: data, Errors: ex.errors}
+}
+
+//selectOperation returns the operation to run: the one with the given name, or the only one in the document.
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return doc.operations[0], nil
+	}
+
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+//toError returns err as an *Error.
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
@@ -0,0 +1,284 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+//testSchema returns a schema of movies, each similar to the next two, where movie 0 fails to resolve.
+func testSchema(t *testing.T, maxDepth, maxComplexity int) *Schema {
+	t.Helper()
+
+	movie := &Object{Name: "Movie"}
+
+	resolveMovie := func(id int) (interface{}, error) {
+		if id == 0 {
+			return nil, &Error{Message: "movie 0 is unavailable", Extensions: map[string]interface{}{"code": "unavailable"}}
+		}
+		return map[string]interface{}{"id": id, "title": fmt.Sprintf("Movie %d", id)}, nil
+	}
+
+	movie.Fields = Fields{
+		"id":    {Type: NewNonNull(Int)},
+		"title": {Type: String},
+		"similar": {
+			Type: NewList(movie),
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				id := p.Source.(map[string]interface{})["id"].(int)
+				a, _ := resolveMovie(id + 1)
+				b, _ := resolveMovie(id + 2)
+				return []interface{}{a, b}, nil
+			},
+			Complexity: func(args map[string]interface{}, childComplexity int) int {
+				return 1 + 2*childComplexity
+			},
+		},
+	}
+
+	schema, err := NewSchema(SchemaConfig{
+		Query: &Object{
+			Name: "Query",
+			Fields: Fields{
+				"movie": {
+					Type: movie,
+					Args: Args{"id": {Type: NewNonNull(Int)}},
+					Resolve: func(p ResolveParams) (interface{}, error) {
+						return resolveMovie(p.Args["id"].(int))
+					},
+				},
+			},
+		},
+		MaxDepth:      maxDepth,
+		MaxComplexity: maxComplexity,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return schema
+}
+
+//execute runs a query, returning the response as JSON.
+func execute(t *testing.T, schema *Schema, query string, vars map[string]interface{}) string {
+	t.Helper()
+
+	js, err := json.Marshal(schema.Execute(context.Background(), Request{Query: query, Variables: vars}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(js)
+}
+
+func TestExecute(t *testing.T) {
+	schema := testSchema(t, 0, 0)
+
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{
+			"fields in order",
+			`{ movie(id: 1) { title id } }`,
+			nil,
+			`{"data":{"movie":{"title":"Movie 1","id":1}}}`,
+		},
+		{
+			"aliases and __typename",
+			`{ a: movie(id: 1) { id __typename } b: movie(id: 2) { id } }`,
+			nil,
+			`{"data":{"a":{"id":1,"__typename":"Movie"},"b":{"id":2}}}`,
+		},
+		{
+			"nested lists",
+			`{ movie(id: 1) { similar { id } } }`,
+			nil,
+			`{"data":{"movie":{"similar":[{"id":2},{"id":3}]}}}`,
+		},
+		{
+			"fragments merged",
+			`{ movie(id: 1) { ...A ... on Movie { title } id } } fragment A on Movie { id }`,
+			nil,
+			`{"data":{"movie":{"id":1,"title":"Movie 1"}}}`,
+		},
+		{
+			"variables and directives",
+			`query ($id: Int!, $skip: Boolean!) { movie(id: $id) { id title @skip(if: $skip) } }`,
+			map[string]interface{}{"id": 4.0, "skip": true},
+			`{"data":{"movie":{"id":4}}}`,
+		},
+		{
+			"resolver error",
+			`{ movie(id: 0) { id } }`,
+			nil,
+			`{"data":{"movie":null},"errors":[{"message":"movie 0 is unavailable","locations":[{"line":1,"column":3}],"path":["movie"],"extensions":{"code":"unavailable"}}]}`,
+		},
+		{
+			"syntax error",
+			`{ movie(id: 1) { id }`,
+			nil,
+			`{"errors":[{"message":"Syntax Error: expected a name, found end of document","locations":[{"line":1,"column":22}]}]}`,
+		},
+		{
+			"unknown field",
+			`{ movie(id: 1) { id year } }`,
+			nil,
+			`{"errors":[{"message":"Cannot query field \"year\" on type \"Movie\".","locations":[{"line":1,"column":21}]}]}`,
+		},
+		{
+			"missing argument",
+			`{ movie { id } }`,
+			nil,
+			`{"errors":[{"message":"Field \"movie\" argument \"id\" of type \"Int!\" is required, but it was not provided.","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			"conflicting fields",
+			`{ m: movie(id: 1) { id } m: movie(id: 2) { id } }`,
+			nil,
+			`{"errors":[{"message":"Fields \"m\" conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.","locations":[{"line":1,"column":26}]}]}`,
+		},
+		{
+			"fragment cycle",
+			`{ movie(id: 1) { ...A } } fragment A on Movie { id ...B } fragment B on Movie { ...A }`,
+			nil,
+			`{"errors":[{"message":"Cannot spread fragment \"A\" within itself.","locations":[{"line":1,"column":81}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, schema, tt.query, tt.vars); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	schema := testSchema(t, 4, 10)
+
+	var many strings.Builder
+	for i := 0; i < 11; i++ {
+		fmt.Fprintf(&many, "m%d: movie(id: 1) { id } ", i)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{"within limits", `{ movie(id: 1) { similar { similar { id } } } }`, ""},
+		{"too deep", `{ movie(id: 1) { similar { similar { similar { __typename } } } } }`, "max_depth_exceeded"},
+		{"too complex", `{ movie(id: 1) { similar { similar { id title } } } }`, "max_complexity_exceeded"},
+		{"too many fields", "{ " + many.String() + "}", "max_complexity_exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := schema.Execute(context.Background(), Request{Query: tt.query})
+
+			code := ""
+			if len(res.Errors) > 0 {
+				code, _ = res.Errors[0].Extensions["code"].(string)
+			}
+
+			if code != tt.code {
+				t.Errorf("got code %q, want %q (errors %v)", code, tt.code, res.Errors)
+			}
+		})
+	}
+}
+
+//nestedFragments returns a query whose fragments each spread the next twice, so expanding them naively visits 2^n fields.
+func nestedFragments(n int, selection string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "{ movie(id: 1) { ...F0 } }\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "fragment F%d on Movie { ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	fmt.Fprintf(&b, "fragment F%d on Movie { %s }\n", n, selection)
+
+	return b.String()
+}
+
+func TestNestedFragmentSpreads(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection string
+		want      string
+	}{
+		{"fields", "id title", `{"data":{"movie":{"id":1,"title":"Movie 1"}}}`},
+		{"nested fields", "similar { id }", `{"data":{"movie":{"similar":[{"id":2},{"id":3}]}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := testSchema(t, 10, 1000)
+
+			done := make(chan string)
+			go func() {
+				res := schema.Execute(context.Background(), Request{Query: nestedFragments(24, tt.selection)})
+				js, _ := json.Marshal(res)
+				done <- string(js)
+			}()
+
+			select {
+			case got := <-done:
+				if got != tt.want {
+					t.Errorf("got  %s\nwant %s", got, tt.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("fragments spread twice at each level were expanded exponentially")
+			}
+		})
+	}
+}
+
+func TestLoaderBatches(t *testing.T) {
+	var batches [][]interface{}
+
+	loader := NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		batches = append(batches, keys)
+
+		values := make(map[interface{}]interface{})
+		for _, key := range keys {
+			if key.(int) < 0 {
+				return nil, errors.New("negative key")
+			}
+			values[key] = key.(int) * 10
+		}
+		return values, nil
+	})
+
+	a, b, again := loader.Load(1), loader.Load(2), loader.Load(1)
+
+	for _, tt := range []struct {
+		thunk Thunk
+		want  int
+	}{{a, 10}, {b, 20}, {again, 10}} {
+		v, err := tt.thunk()
+		if err != nil || v != tt.want {
+			t.Errorf("got %v, %v, want %d", v, err, tt.want)
+		}
+	}
+
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("got batches %v, want one batch of 2 keys", batches)
+	}
+
+	if _, err := loader.Load(-1)(); err == nil {
+		t.Error("got no error from a failed batch")
+	}
+
+	if len(batches) != 2 {
+		t.Errorf("got %d batches, want 2", len(batches))
+	}
+}
@@ -0,0 +1,308 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//tokenKind is the kind of a lexical token.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+//token is one lexical token of a GraphQL document. For strings, value holds the string with its escapes processed.
+type token struct {
+	kind  tokenKind
+	value string
+	loc   Location
+}
+
+//lexer splits a GraphQL document into tokens. Whitespace, commas and comments are insignificant, so they are skipped.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, col: 1}
+}
+
+//advance moves past n bytes, which mustn't include a newline.
+func (l *lexer) advance(n int) {
+	l.pos += n
+	l.col += n
+}
+
+//skipIgnored skips whitespace, line terminators, commas, the byte order mark and comments.
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == ',':
+			l.advance(1)
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.col = 1
+		case c == '\r':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.pos++
+			}
+			l.line++
+			l.col = 1
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+//next returns the next token.
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+
+	loc := Location{Line: l.line, Column: l.col}
+
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, loc: loc}, nil
+	}
+
+	c := l.src[l.pos]
+
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.advance(3)
+		return token{kind: tokenPunct, value: "...", loc: loc}, nil
+
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		l.advance(1)
+		return token{kind: tokenPunct, value: string(c), loc: loc}, nil
+
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		start := l.pos
+		for l.pos < len(l.src) && isNameByte(l.src[l.pos]) {
+			l.advance(1)
+		}
+		return token{kind: tokenName, value: l.src[start:l.pos], loc: loc}, nil
+
+	case c == '-' || c >= '0' && c <= '9':
+		return l.number(loc)
+
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		return l.blockString(loc)
+
+	case c == '"':
+		return l.string(loc)
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, syntaxError(loc, "unexpected character %q", r)
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+//number reads an integer or float, like -12, 1.5 or 6.02e23.
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	kind := tokenInt
+
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+			l.advance(1)
+			n++
+		}
+		return n
+	}
+
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+
+	intStart := l.pos
+	if digits() == 0 {
+		return token{}, syntaxError(loc, "invalid number %q", l.src[start:l.pos])
+	}
+	if l.pos-intStart > 1 && l.src[intStart] == '0' {
+		return token{}, syntaxError(loc, "invalid number %q, unexpected leading zero", l.src[start:l.pos])
+	}
+
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokenFloat
+		l.advance(1)
+		if digits() == 0 {
+			return token{}, syntaxError(loc, "invalid number %q", l.src[start:l.pos])
+		}
+	}
+
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokenFloat
+		l.advance(1)
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.advance(1)
+		}
+		if digits() == 0 {
+			return token{}, syntaxError(loc, "invalid number %q", l.src[start:l.pos])
+		}
+	}
+
+	//A number can't be followed straight away by a name, like 123abc
+	if l.pos < len(l.src) && (isNameByte(l.src[l.pos]) || l.src[l.pos] == '.') {
+		return token{}, syntaxError(loc, "invalid number %q", l.src[start:l.pos+1])
+	}
+
+	return token{kind: kind, value: l.src[start:l.pos], loc: loc}, nil
+}
+
+//string reads a quoted string, processing its escape sequences.
+func (l *lexer) string(loc Location) (token, error) {
+	l.advance(1)
+
+	var b strings.Builder
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+
+		switch {
+		case c == '"':
+			l.advance(1)
+			return token{kind: tokenString, value: b.String(), loc: loc}, nil
+
+		case c == '\n' || c == '\r':
+			return token{}, syntaxError(loc, "unterminated string")
+
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, syntaxError(loc, "unterminated string")
+			}
+
+			escape := l.src[l.pos+1]
+			l.advance(2)
+
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, syntaxError(loc, "invalid unicode escape in string")
+				}
+				code, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, syntaxError(loc, "invalid unicode escape in string")
+				}
+				b.WriteRune(rune(code))
+				l.advance(4)
+			default:
+				return token{}, syntaxError(loc, "invalid escape sequence \\%c in string", escape)
+			}
+
+		default:
+			_, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			b.WriteString(l.src[l.pos : l.pos+size])
+			l.advance(size)
+		}
+	}
+
+	return token{}, syntaxError(loc, "unterminated string")
+}
+
+//blockString reads a """triple quoted""" string. Only \""" is an escape, and the common indentation of its lines is
+//removed, along with blank first and last lines.
+func (l *lexer) blockString(loc Location) (token, error) {
+	l.advance(3)
+
+	var b strings.Builder
+
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.advance(3)
+			return token{kind: tokenString, value: dedentBlockString(b.String()), loc: loc}, nil
+
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			b.WriteString(`"""`)
+			l.advance(4)
+
+		case l.src[l.pos] == '\n':
+			b.WriteByte('\n')
+			l.pos++
+			l.line++
+			l.col = 1
+
+		default:
+			b.WriteByte(l.src[l.pos])
+			l.advance(1)
+		}
+	}
+
+	return token{}, syntaxError(loc, "unterminated block string")
+}
+
+//dedentBlockString removes the common indentation from a block string's lines, ignoring the first line, then any
+//blank lines at the start and end.
+func dedentBlockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+
+	common := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if indent := len(line) - len(trimmed); common < 0 || indent < common {
+			common = indent
+		}
+	}
+
+	if common > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= common {
+				lines[i] = lines[i][common:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+//syntaxError returns an error for a document which can't be parsed.
+func syntaxError(loc Location, format string, args ...interface{}) *Error {
+	return &Error{Message: "Syntax Error: " + fmt.Sprintf(format, args...), Locations: []Location{loc}}
+}
@@ -0,0 +1,77 @@
+package graphql
+
+import "sync"
+
+//BatchFunc fetches the values for a batch of keys, returning them keyed by key. Keys with no value are left out, and
+//load as nil.
+type BatchFunc func(keys []interface{}) (map[interface{}]interface{}, error)
+
+//Loader batches and caches the data resolvers load. Load only records a key and returns a Thunk. The first time one of
+//those thunks is called, every key recorded since the last batch is fetched with a single call to the BatchFunc. As the
+//executor calls thunks only once every resolver at a level has run, a list of N movies asking for their history makes
+//one query rather than N.
+//
+//A Loader caches every value it has loaded, errors included, so it should only live for one request.
+type Loader struct {
+	fetch BatchFunc
+
+	mu      sync.Mutex
+	cache   map[interface{}]*loaderEntry
+	pending []interface{}
+}
+
+//loaderEntry is the value for one key, once it has been fetched.
+type loaderEntry struct {
+	done  bool
+	value interface{}
+	err   error
+}
+
+//NewLoader returns a loader which fetches with fetch.
+func NewLoader(fetch BatchFunc) *Loader {
+	return &Loader{fetch: fetch, cache: make(map[interface{}]*loaderEntry)}
+}
+
+//Load returns a Thunk for the value of key, adding it to the next batch unless it has been asked for before.
+func (l *Loader) Load(key interface{}) Thunk {
+	l.mu.Lock()
+	entry, ok := l.cache[key]
+	if !ok {
+		entry = &loaderEntry{}
+		l.cache[key] = entry
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if !entry.done {
+			l.dispatch()
+		}
+		return entry.value, entry.err
+	}
+}
+
+//dispatch fetches the pending keys. It must be called with the lock held.
+func (l *Loader) dispatch() {
+	keys := l.pending
+	l.pending = nil
+
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := l.fetch(keys)
+
+	for _, key := range keys {
+		entry := l.cache[key]
+		entry.done = true
+		if err != nil {
+			entry.err = err
+		} else {
+			entry.value = values[key]
+		}
+	}
+}
@@ -0,0 +1,646 @@
+package graphql
+
+//This file parses executable GraphQL documents: operations and fragments. Type system definitions aren't supported,
+//as the schema is defined in Go.
+
+//document is a parsed GraphQL document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+//operation is a query or mutation.
+type operation struct {
+	kind       string //"query" or "mutation"
+	name       string
+	vars       []*varDef
+	selections []selection
+	loc        Location
+}
+
+//varDef declares one of an operation's variables.
+type varDef struct {
+	name       string
+	typ        *typeRef
+	defaultVal *value
+	loc        Location
+}
+
+//typeRef is a type as written in a variable definition, like [String!]!.
+type typeRef struct {
+	name    string   //the named type, if this isn't a list
+	elem    *typeRef //the element type, if this is a list
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+//selection is a *field, *fragmentSpread or *inlineFragment.
+type selection interface{}
+
+//field is a field in a selection set.
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+//responseKey is the field's name in the response, which is its alias if it has one.
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argument struct {
+	name string
+	val  *value
+	loc  Location
+}
+
+type directive struct {
+	name string
+	args []*argument
+	loc  Location
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	typeCondition string
+	directives    []*directive
+	selections    []selection
+	loc           Location
+}
+
+type fragment struct {
+	name          string
+	typeCondition string
+	selections    []selection
+	loc           Location
+}
+
+//valueKind is the kind of a literal value in a document.
+type valueKind int
+
+const (
+	valueVariable valueKind = iota
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueNull
+	valueEnum
+	valueList
+	valueObject
+)
+
+//value is a literal value, or a reference to a variable.
+type value struct {
+	kind   valueKind
+	raw    string //the variable name, or the text of a scalar or enum value
+	list   []*value
+	fields []*objectField
+	loc    Location
+}
+
+type objectField struct {
+	name string
+	val  *value
+}
+
+//parser is a recursive descent parser with one token of lookahead.
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+//parse parses a document.
+func parse(src string) (*document, error) {
+	p := &parser{lex: newLexer(src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"), p.peekName("query"), p.peekName("mutation"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+
+		case p.peekName("fragment"):
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.fragments[frag.name]; exists {
+				return nil, &Error{Message: "There can be only one fragment named \"" + frag.name + "\".", Locations: []Location{frag.loc}}
+			}
+			doc.fragments[frag.name] = frag
+
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "The document doesn't contain any operations."}
+	}
+
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+//peek reports whether the next token is the given punctuator.
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == punct
+}
+
+//peekName reports whether the next token is the given name.
+func (p *parser) peekName(name string) bool {
+	return p.tok.kind == tokenName && p.tok.value == name
+}
+
+//skip consumes the next token if it's the given punctuator, and reports whether it was.
+func (p *parser) skip(punct string) (bool, error) {
+	if !p.peek(punct) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+//expect consumes the given punctuator, or returns an error if it's something else.
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return syntaxError(p.tok.loc, "expected %q, found %s", punct, p.describe())
+	}
+	return p.advance()
+}
+
+//name consumes a name.
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", syntaxError(p.tok.loc, "expected a name, found %s", p.describe())
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) unexpected() error {
+	return syntaxError(p.tok.loc, "unexpected %s", p.describe())
+}
+
+//describe describes the next token for error messages.
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokenEOF:
+		return "end of document"
+	case tokenString:
+		return "string"
+	default:
+		return "\"" + p.tok.value + "\""
+	}
+}
+
+//operation parses an operation, which may be just a selection set for a query.
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: "query", loc: p.tok.loc}
+
+	if p.tok.kind == tokenName {
+		op.kind = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		if p.tok.kind == tokenName {
+			op.name = p.tok.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.peek("(") {
+			vars, err := p.varDefs()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = vars
+		}
+
+		//Directives on operations are parsed but have no effect
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+	}
+
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+
+	return op, nil
+}
+
+//varDefs parses an operation's variable definitions, like ($id: ID!, $first: Int = 10).
+func (p *parser) varDefs() ([]*varDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var defs []*varDef
+
+	for {
+		if ok, err := p.skip(")"); err != nil || ok {
+			return defs, err
+		}
+
+		def := &varDef{loc: p.tok.loc}
+
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		def.name = name
+
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		def.typ, err = p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+
+		if ok, err := p.skip("="); err != nil {
+			return nil, err
+		} else if ok {
+			def.defaultVal, err = p.value(true)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+
+		defs = append(defs, def)
+	}
+}
+
+//typeRef parses a type, like Int, [String!] or ID!.
+func (p *parser) typeRef() (*typeRef, error) {
+	t := &typeRef{}
+
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		t.elem, err = p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		t.name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ok, err := p.skip("!")
+	t.nonNull = ok
+
+	return t, err
+}
+
+//selectionSet parses a { ... } block of fields and fragments.
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []selection
+
+	for {
+		if ok, err := p.skip("}"); err != nil {
+			return nil, err
+		} else if ok {
+			if len(selections) == 0 {
+				return nil, syntaxError(p.tok.loc, "a selection set can't be empty")
+			}
+			return selections, nil
+		}
+
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+}
+
+//selection parses a field, fragment spread or inline fragment.
+func (p *parser) selection() (selection, error) {
+	loc := p.tok.loc
+
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		//A fragment spread is followed by the fragment's name, but "on" starts an inline fragment's type condition
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			spread := &fragmentSpread{name: p.tok.value, loc: loc}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			spread.directives, err = p.directives()
+			return spread, err
+		}
+
+		inline := &inlineFragment{loc: loc}
+
+		if p.peekName("on") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			inline.typeCondition, err = p.name()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		inline.directives, err = p.directives()
+		if err != nil {
+			return nil, err
+		}
+
+		inline.selections, err = p.selectionSet()
+		return inline, err
+	}
+
+	return p.field()
+}
+
+//field parses a field, with its alias, arguments, directives and selection set.
+func (p *parser) field() (*field, error) {
+	f := &field{loc: p.tok.loc}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = name
+		name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+	}
+	f.name = name
+
+	f.args, err = p.arguments(false)
+	if err != nil {
+		return nil, err
+	}
+
+	f.directives, err = p.directives()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek("{") {
+		f.selections, err = p.selectionSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+//arguments parses an optional (name: value, ...) list. If constant is true, variables aren't allowed.
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+
+	var args []*argument
+
+	for {
+		if ok, err := p.skip(")"); err != nil || ok {
+			return args, err
+		}
+
+		arg := &argument{loc: p.tok.loc}
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		arg.name = name
+
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		arg.val, err = p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+}
+
+//directives parses any @name(args) directives.
+func (p *parser) directives() ([]*directive, error) {
+	var directives []*directive
+
+	for p.peek("@") {
+		d := &directive{loc: p.tok.loc}
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d.name = name
+
+		d.args, err = p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+
+		directives = append(directives, d)
+	}
+
+	return directives, nil
+}
+
+//value parses a value. If constant is true, variables aren't allowed, as in default values.
+func (p *parser) value(constant bool) (*value, error) {
+	v := &value{loc: p.tok.loc, raw: p.tok.value}
+
+	switch p.tok.kind {
+	case tokenInt:
+		v.kind = valueInt
+	case tokenFloat:
+		v.kind = valueFloat
+	case tokenString:
+		v.kind = valueString
+
+	case tokenName:
+		switch p.tok.value {
+		case "true", "false":
+			v.kind = valueBoolean
+		case "null":
+			v.kind = valueNull
+		default:
+			v.kind = valueEnum
+		}
+
+	case tokenPunct:
+		switch p.tok.value {
+		case "$":
+			if constant {
+				return nil, syntaxError(p.tok.loc, "variables aren't allowed here")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			v.kind = valueVariable
+			v.raw = name
+			return v, nil
+
+		case "[":
+			v.kind = valueList
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			for {
+				if ok, err := p.skip("]"); err != nil || ok {
+					return v, err
+				}
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				v.list = append(v.list, item)
+			}
+
+		case "{":
+			v.kind = valueObject
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			for {
+				if ok, err := p.skip("}"); err != nil || ok {
+					return v, err
+				}
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				v.fields = append(v.fields, &objectField{name: name, val: item})
+			}
+
+		default:
+			return nil, p.unexpected()
+		}
+
+	default:
+		return nil, p.unexpected()
+	}
+
+	return v, p.advance()
+}
+
+//fragment parses a named fragment definition.
+func (p *parser) fragment() (*fragment, error) {
+	frag := &fragment{loc: p.tok.loc}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, syntaxError(frag.loc, "a fragment can't be named \"on\"")
+	}
+	frag.name = name
+
+	if !p.peekName("on") {
+		return nil, syntaxError(p.tok.loc, "expected \"on\", found %s", p.describe())
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	frag.typeCondition, err = p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	frag.selections, err = p.selectionSet()
+	return frag, err
+}
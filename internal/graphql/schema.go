@@ -0,0 +1,381 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+//Type is a GraphQL type: a *Scalar, *Enum, *Object, *InputObject, *List or *NonNull.
+type Type interface {
+	String() string
+}
+
+//Scalar is a leaf type. Serialize converts a resolved Go value into the value written in the response, and ParseValue
+//converts an input value into the Go value resolvers receive. Input values are nil, bool, string, int64 (for integer
+//literals) or float64 (for other numbers, including every number in the JSON variables).
+type Scalar struct {
+	Name        string
+	Description string
+	Serialize   func(value interface{}) (interface{}, error)
+	ParseValue  func(value interface{}) (interface{}, error)
+}
+
+func (t *Scalar) String() string { return t.Name }
+
+//Enum is a leaf type whose values are one of a fixed set of names. Resolvers return, and receive, them as strings.
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+func (t *Enum) String() string { return t.Name }
+
+//Object is an output type with fields.
+type Object struct {
+	Name        string
+	Description string
+	Fields      Fields
+}
+
+func (t *Object) String() string { return t.Name }
+
+//Fields are an object's fields, keyed by name.
+type Fields map[string]*Field
+
+//Field is a field of an object. If Resolve is nil, the field is read from the parent value, which must be a
+//map[string]interface{}.
+type Field struct {
+	Type        Type
+	Description string
+	Args        Args
+	Resolve     ResolveFunc
+
+	//Complexity returns the cost of the field, given its arguments and the cost of its selection set. Without it the
+	//cost is 1 plus the cost of the selection set. Fields returning lists should multiply the selection set's cost by
+	//the number of items they can return. It shouldn't return less than 1, as operations with more fields than
+	//MaxComplexity are rejected without adding up their costs.
+	Complexity func(args map[string]interface{}, childComplexity int) int
+}
+
+//Args are a field's arguments, or an input object's fields, keyed by name.
+type Args map[string]*Argument
+
+//Argument is an argument to a field, or a field of an input object. DefaultValue is used when it isn't given, and
+//should already be in the form resolvers receive.
+type Argument struct {
+	Type         Type
+	Description  string
+	DefaultValue interface{}
+}
+
+//InputObject is an input type with fields, which resolvers receive as a map[string]interface{}. Fields which aren't
+//given and have no default are left out of the map.
+type InputObject struct {
+	Name        string
+	Description string
+	Fields      Args
+}
+
+func (t *InputObject) String() string { return t.Name }
+
+//List is a list of another type.
+type List struct {
+	OfType Type
+}
+
+func (t *List) String() string { return "[" + t.OfType.String() + "]" }
+
+//NonNull is another type which can't be null.
+type NonNull struct {
+	OfType Type
+}
+
+func (t *NonNull) String() string { return t.OfType.String() + "!" }
+
+//NewList and NewNonNull wrap a type.
+func NewList(t Type) *List       { return &List{OfType: t} }
+func NewNonNull(t Type) *NonNull { return &NonNull{OfType: t} }
+
+//ResolveParams are passed to resolvers. Source is the value of the parent object, which is nil for the root types.
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+}
+
+//ResolveFunc resolves a field's value. To load data in batches, it can return a Thunk from a Loader, which is called
+//once every field at the same level has been resolved.
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+//Thunk returns a value which is loaded later. See Loader.
+type Thunk func() (interface{}, error)
+
+//Schema is a set of types, starting from the root Query and Mutation types. MaxDepth and MaxComplexity limit the
+//operations it will run, if they aren't zero. The depth of an operation is how deeply its fields are nested, and its
+//complexity is the total cost of its fields (see Field.Complexity).
+type Schema struct {
+	Query         *Object
+	Mutation      *Object
+	MaxDepth      int
+	MaxComplexity int
+
+	types map[string]Type
+}
+
+//SchemaConfig configures a new schema.
+type SchemaConfig struct {
+	Query         *Object
+	Mutation      *Object
+	MaxDepth      int
+	MaxComplexity int
+}
+
+//NewSchema returns a schema, checking that every type reachable from the root types has a unique name.
+func NewSchema(cfg SchemaConfig) (*Schema, error) {
+	if cfg.Query == nil {
+		return nil, fmt.Errorf("graphql: a schema must have a query type")
+	}
+
+	s := &Schema{
+		Query:         cfg.Query,
+		Mutation:      cfg.Mutation,
+		MaxDepth:      cfg.MaxDepth,
+		MaxComplexity: cfg.MaxComplexity,
+		types:         make(map[string]Type),
+	}
+
+	for _, t := range []Type{Int, Float, String, Boolean, ID, cfg.Query} {
+		if err := s.addType(t); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Mutation != nil {
+		if err := s.addType(cfg.Mutation); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+//addType adds a type, and every type it refers to, to the schema's types by name.
+func (s *Schema) addType(t Type) error {
+	named := namedType(t)
+
+	if existing, ok := s.types[named.String()]; ok {
+		if existing != named {
+			return fmt.Errorf("graphql: two different types are named %q", named.String())
+		}
+		return nil
+	}
+
+	s.types[named.String()] = named
+
+	switch t := named.(type) {
+	case *Object:
+		for name, f := range t.Fields {
+			if f.Type == nil {
+				return fmt.Errorf("graphql: %s.%s has no type", t.Name, name)
+			}
+			if err := s.addType(f.Type); err != nil {
+				return err
+			}
+			for _, arg := range f.Args {
+				if err := s.addType(arg.Type); err != nil {
+					return err
+				}
+			}
+		}
+
+	case *InputObject:
+		for _, f := range t.Fields {
+			if err := s.addType(f.Type); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//namedType removes any List and NonNull wrappers from a type.
+func namedType(t Type) Type {
+	for {
+		switch wrapper := t.(type) {
+		case *List:
+			t = wrapper.OfType
+		case *NonNull:
+			t = wrapper.OfType
+		default:
+			return t
+		}
+	}
+}
+
+//isInputType reports whether a type can be used for arguments and variables.
+func isInputType(t Type) bool {
+	switch namedType(t).(type) {
+	case *Scalar, *Enum, *InputObject:
+		return true
+	default:
+		return false
+	}
+}
+
+//isLeafType reports whether a type is a scalar or enum, which have no fields to select.
+func isLeafType(t Type) bool {
+	switch namedType(t).(type) {
+	case *Scalar, *Enum:
+		return true
+	default:
+		return false
+	}
+}
+
+//The built-in scalars.
+var (
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			n, ok := toInt64(v)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent value: %v", v)
+			}
+			return n, nil
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			n, ok := toInt64(v)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent value: %v", v)
+			}
+			return int(n), nil
+		},
+	}
+
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating point number.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			f, ok := toFloat64(v)
+			if !ok {
+				return nil, fmt.Errorf("Float cannot represent value: %v", v)
+			}
+			return f, nil
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			f, ok := toFloat64(v)
+			if !ok {
+				return nil, fmt.Errorf("Float cannot represent value: %v", v)
+			}
+			return f, nil
+		},
+	}
+
+	String = &Scalar{
+		Name:        "String",
+		Description: "A UTF-8 character sequence.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			switch v := v.(type) {
+			case string:
+				return v, nil
+			case fmt.Stringer:
+				return v.String(), nil
+			}
+			return nil, fmt.Errorf("String cannot represent value: %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("String cannot represent a non string value: %v", v)
+			}
+			return s, nil
+		},
+	}
+
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("Boolean cannot represent value: %v", v)
+			}
+			return b, nil
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("Boolean cannot represent a non boolean value: %v", v)
+			}
+			return b, nil
+		},
+	}
+
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "A unique identifier, written as a string. Integers are accepted as input.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			if n, ok := toInt64(v); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent value: %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			if n, ok := toInt64(v); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent value: %v", v)
+		},
+	}
+)
+
+//toInt64 converts any Go integer, or a float64 with no fractional part, to an int64.
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+//toFloat64 converts any Go integer or float to a float64.
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+
+	n, ok := toInt64(v)
+	return float64(n), ok
+}
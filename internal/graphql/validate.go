@@ -0,0 +1,406 @@
+package graphql
+
+import "fmt"
+
+//plannedField is a field to execute: the fields in a selection set with the same response key, merged, with fragments
+//expanded, directives applied and arguments coerced. Every field in a planned operation is known to exist.
+type plannedField struct {
+	key      string
+	name     string
+	def      *Field //nil for __typename
+	args     map[string]interface{}
+	children []*plannedField //the selection set, for fields of object types
+	loc      Location
+}
+
+//planner validates an operation against the schema and turns it into planned fields. Rather than stopping at the first
+//error it records them all, so clients can fix every problem at once.
+type planner struct {
+	schema *Schema
+	doc    *document
+	vars   map[string]interface{}
+	errors []*Error
+
+	//fragments holds the fields already collected from each fragment on each parent type, so a fragment spread many
+	//times is only expanded once. Without it, fragments which each spread the next twice take exponential time.
+	fragments map[fragmentKey][]*field
+
+	//planned counts the fields planned so far. Every field costs at least 1, so once there are more than
+	//MaxComplexity, planning stops and tooComplex is set rather than building a plan which would be rejected anyway.
+	planned    int
+	tooComplex bool
+}
+
+//fragmentKey identifies the fields collected from a fragment spread on a parent type.
+type fragmentKey struct {
+	name   string
+	parent *Object
+}
+
+func (pl *planner) errorf(loc Location, format string, args ...interface{}) {
+	pl.errors = append(pl.errors, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+//plan returns the planned fields for a selection set on an object type.
+func (pl *planner) plan(parent *Object, selections []selection) []*plannedField {
+	var keys []string
+	groups := make(map[string][]*field)
+	seen := make(map[*field]bool)
+
+	//A field reached through more than one spread of the same fragment only needs merging once
+	pl.collect(parent, selections, make(map[string]bool), func(f *field) {
+		if seen[f] {
+			return
+		}
+		seen[f] = true
+
+		key := f.responseKey()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], f)
+	})
+
+	planned := make([]*plannedField, 0, len(keys))
+
+	for _, key := range keys {
+		if pl.tooComplex {
+			return nil
+		}
+
+		group := groups[key]
+		first := group[0]
+
+		conflict := false
+		for _, f := range group[1:] {
+			if f.name != first.name {
+				pl.errorf(f.loc, "Fields %q conflict because %q and %q are different fields. Use different aliases on the fields to fetch both if this was intentional.", key, first.name, f.name)
+				conflict = true
+				break
+			}
+			if !sameArgs(f.args, first.args) {
+				pl.errorf(f.loc, "Fields %q conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.", key)
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			continue
+		}
+
+		pf := &plannedField{key: key, name: first.name, loc: first.loc}
+
+		if first.name == "__typename" {
+			if len(first.args) > 0 || len(first.selections) > 0 {
+				pl.errorf(first.loc, "Field \"__typename\" takes no arguments or selections.")
+			}
+			planned = append(planned, pf)
+			continue
+		}
+
+		def, ok := parent.Fields[first.name]
+		if !ok {
+			pl.errorf(first.loc, "Cannot query field %q on type %q.", first.name, parent.Name)
+			continue
+		}
+		pf.def = def
+		pf.args = pl.coerceArgs(parent, first, def)
+
+		pl.planned++
+		if max := pl.schema.MaxComplexity; max > 0 && pl.planned > max {
+			pl.tooComplex = true
+			return nil
+		}
+
+		//The selection sets of every field with this key are merged into one
+		var selections []selection
+		for _, f := range group {
+			selections = append(selections, f.selections...)
+		}
+
+		switch named := namedType(def.Type).(type) {
+		case *Object:
+			if len(selections) == 0 {
+				pl.errorf(first.loc, "Field %q of type %q must have a selection of subfields. Did you mean \"%s { ... }\"?", first.name, def.Type, first.name)
+				continue
+			}
+			pf.children = pl.plan(named, selections)
+
+		default:
+			if len(selections) > 0 {
+				pl.errorf(first.loc, "Field %q must not have a selection since type %q has no subfields.", first.name, def.Type)
+				continue
+			}
+		}
+
+		planned = append(planned, pf)
+	}
+
+	return planned
+}
+
+//sameArgs reports whether two fields with the same response key have the same arguments, written the same way, so they
+//can be merged into one.
+func sameArgs(a, b []*argument) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, argA := range a {
+		found := false
+		for _, argB := range b {
+			if argA.name == argB.name {
+				found = sameValue(argA.val, argB.val)
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+//sameValue reports whether two literals are written the same way.
+func sameValue(a, b *value) bool {
+	if a.kind != b.kind || a.raw != b.raw || len(a.list) != len(b.list) || len(a.fields) != len(b.fields) {
+		return false
+	}
+
+	for i := range a.list {
+		if !sameValue(a.list[i], b.list[i]) {
+			return false
+		}
+	}
+	for i := range a.fields {
+		if a.fields[i].name != b.fields[i].name || !sameValue(a.fields[i].val, b.fields[i].val) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//collect calls add for every field in a selection set, expanding fragments and leaving out fields skipped by the
+//@skip and @include directives. visited holds the fragments already being expanded, so a fragment which spreads itself
+//is reported rather than expanded forever. A fragment is only expanded the first time it's spread on a parent type;
+//after that its fields come from pl.fragments. Any cycle through it was already reported by the first expansion.
+func (pl *planner) collect(parent *Object, selections []selection, visited map[string]bool, add func(*field)) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if pl.included(sel.directives) {
+				add(sel)
+			}
+
+		case *inlineFragment:
+			if !pl.included(sel.directives) || !pl.matchesType(parent, sel.typeCondition, sel.loc) {
+				continue
+			}
+			pl.collect(parent, sel.selections, visited, add)
+
+		case *fragmentSpread:
+			if !pl.included(sel.directives) {
+				continue
+			}
+
+			frag, ok := pl.doc.fragments[sel.name]
+			if !ok {
+				pl.errorf(sel.loc, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			if visited[sel.name] {
+				pl.errorf(sel.loc, "Cannot spread fragment %q within itself.", sel.name)
+				continue
+			}
+			if !pl.matchesType(parent, frag.typeCondition, sel.loc) {
+				continue
+			}
+
+			k := fragmentKey{sel.name, parent}
+
+			fields, ok := pl.fragments[k]
+			if !ok {
+				seen := make(map[*field]bool)
+
+				visited[sel.name] = true
+				pl.collect(parent, frag.selections, visited, func(f *field) {
+					if !seen[f] {
+						seen[f] = true
+						fields = append(fields, f)
+					}
+				})
+				delete(visited, sel.name)
+
+				if pl.fragments == nil {
+					pl.fragments = make(map[fragmentKey][]*field)
+				}
+				pl.fragments[k] = fields
+			}
+
+			for _, f := range fields {
+				add(f)
+			}
+		}
+	}
+}
+
+//matchesType checks a fragment's type condition. Without interfaces or unions, a fragment can only be spread where its
+//type is the parent type, so anything else is an error.
+func (pl *planner) matchesType(parent *Object, typeCondition string, loc Location) bool {
+	if typeCondition == "" || typeCondition == parent.Name {
+		return true
+	}
+
+	if _, ok := pl.schema.types[typeCondition]; !ok {
+		pl.errorf(loc, "Unknown type %q.", typeCondition)
+	} else {
+		pl.errorf(loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", parent.Name, typeCondition)
+	}
+	return false
+}
+
+//included applies the @skip(if:) and @include(if:) directives.
+func (pl *planner) included(directives []*directive) bool {
+	for _, d := range directives {
+		var want bool
+
+		switch d.name {
+		case "skip":
+			want = false
+		case "include":
+			want = true
+		default:
+			pl.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+
+		if len(d.args) != 1 || d.args[0].name != "if" {
+			pl.errorf(d.loc, "Directive \"@%s\" takes one argument, \"if\".", d.name)
+			continue
+		}
+
+		cond, err := coerceLiteral(NewNonNull(Boolean), d.args[0].val, pl.vars)
+		if err != nil {
+			pl.errorf(d.args[0].loc, "Argument \"if\" of directive \"@%s\" has an invalid value: %s", d.name, err)
+			continue
+		}
+
+		if cond.(bool) != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+//coerceArgs checks a field's arguments and returns their values, with defaults applied.
+func (pl *planner) coerceArgs(parent *Object, f *field, def *Field) map[string]interface{} {
+	args := make(map[string]interface{})
+	given := make(map[string]*argument, len(f.args))
+
+	for _, arg := range f.args {
+		if _, ok := def.Args[arg.name]; !ok {
+			pl.errorf(arg.loc, "Unknown argument %q on field \"%s.%s\".", arg.name, parent.Name, f.name)
+			continue
+		}
+		if _, dup := given[arg.name]; dup {
+			pl.errorf(arg.loc, "There can be only one argument named %q.", arg.name)
+			continue
+		}
+		given[arg.name] = arg
+	}
+
+	for name, argDef := range def.Args {
+		arg, ok := given[name]
+
+		//An argument set to a variable which wasn't given is treated as if it wasn't there
+		if ok && arg.val.kind == valueVariable {
+			if _, provided := pl.vars[arg.val.raw]; !provided {
+				ok = false
+			}
+		}
+
+		switch {
+		case ok:
+			value, err := coerceLiteral(argDef.Type, arg.val, pl.vars)
+			if err != nil {
+				pl.errorf(arg.loc, "Argument %q has an invalid value: %s", name, err)
+				continue
+			}
+			args[name] = value
+
+		case argDef.DefaultValue != nil:
+			args[name] = argDef.DefaultValue
+
+		default:
+			if _, ok := argDef.Type.(*NonNull); ok {
+				pl.errorf(f.loc, "Field %q argument %q of type %q is required, but it was not provided.", f.name, name, argDef.Type)
+			}
+		}
+	}
+
+	return args
+}
+
+//checkLimits returns an error if planned fields are nested deeper than MaxDepth, or cost more than MaxComplexity.
+func (s *Schema) checkLimits(fields []*plannedField) *Error {
+	if s.MaxDepth > 0 {
+		if d := depth(fields); d > s.MaxDepth {
+			return &Error{
+				Message:    fmt.Sprintf("Query has depth %d, which exceeds the maximum of %d.", d, s.MaxDepth),
+				Extensions: map[string]interface{}{"code": "max_depth_exceeded"},
+			}
+		}
+	}
+
+	if s.MaxComplexity > 0 {
+		if c := complexity(fields); c > s.MaxComplexity {
+			return &Error{
+				Message:    fmt.Sprintf("Query has complexity %d, which exceeds the maximum of %d.", c, s.MaxComplexity),
+				Extensions: map[string]interface{}{"code": "max_complexity_exceeded"},
+			}
+		}
+	}
+
+	return nil
+}
+
+//depth returns how deeply fields are nested, where a selection set of leaf fields has depth 1.
+func depth(fields []*plannedField) int {
+	max := 0
+	for _, f := range fields {
+		if d := 1 + depth(f.children); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+//complexity returns the total cost of fields. __typename is free, as it needs no data.
+func complexity(fields []*plannedField) int {
+	total := 0
+	for _, f := range fields {
+		if f.def == nil {
+			continue
+		}
+
+		child := complexity(f.children)
+		if f.def.Complexity != nil {
+			total += f.def.Complexity(f.args, child)
+		} else {
+			total += 1 + child
+		}
+	}
+	return total
+}
+
+//tooComplexError is returned for an operation with more fields than MaxComplexity, which was stopped while it was
+//being planned, before its complexity was known.
+func (s *Schema) tooComplexError() *Error {
+	return &Error{
+		Message:    fmt.Sprintf("Query has more than %d fields, which exceeds the maximum complexity of %d.", s.MaxComplexity, s.MaxComplexity),
+		Extensions: map[string]interface{}{"code": "max_complexity_exceeded"},
+	}
+}
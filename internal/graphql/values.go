@@ -0,0 +1,290 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//This file coerces input values, from variables and from literals in the document, into the Go values resolvers
+//receive: nil, bool, string, the results of Scalar.ParseValue, []interface{} for lists and map[string]interface{} for
+//input objects.
+
+//coerceVariables checks the variables given with a request against the operation's definitions, and applies defaults.
+//Variables which aren't given and have no default are left out.
+func (s *Schema) coerceVariables(op *operation, raw map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+
+	for _, def := range op.vars {
+		t, err := s.resolveTypeRef(def.typ)
+		if err != nil {
+			return nil, &Error{Message: err.Error(), Locations: []Location{def.loc}}
+		}
+		if !isInputType(t) {
+			return nil, &Error{Message: fmt.Sprintf("Variable \"$%s\" cannot be non-input type %q.", def.name, t), Locations: []Location{def.loc}}
+		}
+
+		value, given := raw[def.name]
+
+		switch {
+		case given:
+			coerced, err := coerceInput(t, value)
+			if err != nil {
+				return nil, &Error{Message: fmt.Sprintf("Variable \"$%s\" got invalid value: %s", def.name, err), Locations: []Location{def.loc}}
+			}
+			vars[def.name] = coerced
+
+		case def.defaultVal != nil:
+			coerced, err := coerceLiteral(t, def.defaultVal, nil)
+			if err != nil {
+				return nil, &Error{Message: fmt.Sprintf("Variable \"$%s\" has an invalid default value: %s", def.name, err), Locations: []Location{def.loc}}
+			}
+			vars[def.name] = coerced
+
+		default:
+			if _, ok := t.(*NonNull); ok {
+				return nil, &Error{Message: fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", def.name, t), Locations: []Location{def.loc}}
+			}
+		}
+	}
+
+	return vars, nil
+}
+
+//resolveTypeRef returns the schema's type for a type written in a document.
+func (s *Schema) resolveTypeRef(ref *typeRef) (Type, error) {
+	var t Type
+
+	if ref.elem != nil {
+		elem, err := s.resolveTypeRef(ref.elem)
+		if err != nil {
+			return nil, err
+		}
+		t = NewList(elem)
+	} else {
+		named, ok := s.types[ref.name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown type %q.", ref.name)
+		}
+		t = named
+	}
+
+	if ref.nonNull {
+		t = NewNonNull(t)
+	}
+
+	return t, nil
+}
+
+//coerceInput coerces a value decoded from JSON, as found in the request's variables.
+func coerceInput(t Type, v interface{}) (interface{}, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected non-nullable type %q not to be null", t)
+		}
+		return coerceInput(nonNull.OfType, v)
+	}
+
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		//A single value is accepted where a list is expected, as a list of one
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceInput(t.OfType, item)
+			if err != nil {
+				return nil, fmt.Errorf("at index %d, %s", i, err)
+			}
+			list[i] = coerced
+		}
+		return list, nil
+
+	case *InputObject:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected type %q to be an object", t.Name)
+		}
+
+		for name := range fields {
+			if _, ok := t.Fields[name]; !ok {
+				return nil, fmt.Errorf("field %q is not defined by type %q", name, t.Name)
+			}
+		}
+
+		obj := make(map[string]interface{})
+		for name, arg := range t.Fields {
+			value, given := fields[name]
+			switch {
+			case given:
+				coerced, err := coerceInput(arg.Type, value)
+				if err != nil {
+					return nil, fmt.Errorf("in field %q, %s", name, err)
+				}
+				obj[name] = coerced
+			case arg.DefaultValue != nil:
+				obj[name] = arg.DefaultValue
+			default:
+				if _, ok := arg.Type.(*NonNull); ok {
+					return nil, fmt.Errorf("field %q of required type %q was not provided", name, arg.Type)
+				}
+			}
+		}
+		return obj, nil
+
+	case *Enum:
+		return coerceEnum(t, v)
+
+	case *Scalar:
+		return t.ParseValue(v)
+	}
+
+	return nil, fmt.Errorf("type %q can't be used as input", t)
+}
+
+//coerceLiteral coerces a value written in the document. vars are the operation's coerced variables.
+func coerceLiteral(t Type, v *value, vars map[string]interface{}) (interface{}, error) {
+	if v.kind == valueVariable {
+		value := vars[v.raw]
+		if _, ok := t.(*NonNull); ok && value == nil {
+			return nil, fmt.Errorf("variable \"$%s\" of type %q can't be null", v.raw, t)
+		}
+		return value, nil
+	}
+
+	if nonNull, ok := t.(*NonNull); ok {
+		if v.kind == valueNull {
+			return nil, fmt.Errorf("expected value of type %q, found null", t)
+		}
+		return coerceLiteral(nonNull.OfType, v, vars)
+	}
+
+	if v.kind == valueNull {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items := v.list
+		if v.kind != valueList {
+			items = []*value{v}
+		}
+
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceLiteral(t.OfType, item, vars)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+
+	case *InputObject:
+		if v.kind != valueObject {
+			return nil, fmt.Errorf("expected value of type %q, found %s", t.Name, describeValue(v))
+		}
+
+		given := make(map[string]*value, len(v.fields))
+		for _, f := range v.fields {
+			if _, ok := t.Fields[f.name]; !ok {
+				return nil, fmt.Errorf("field %q is not defined by type %q", f.name, t.Name)
+			}
+			given[f.name] = f.val
+		}
+
+		obj := make(map[string]interface{})
+		for name, arg := range t.Fields {
+			value, ok := given[name]
+
+			//A field set to a variable which wasn't given is treated as if it wasn't there
+			if ok && value.kind == valueVariable {
+				if _, provided := vars[value.raw]; !provided {
+					ok = false
+				}
+			}
+
+			switch {
+			case ok:
+				coerced, err := coerceLiteral(arg.Type, value, vars)
+				if err != nil {
+					return nil, err
+				}
+				obj[name] = coerced
+			case arg.DefaultValue != nil:
+				obj[name] = arg.DefaultValue
+			default:
+				if _, ok := arg.Type.(*NonNull); ok {
+					return nil, fmt.Errorf("field %s.%s of required type %q was not provided", t.Name, name, arg.Type)
+				}
+			}
+		}
+		return obj, nil
+
+	case *Enum:
+		if v.kind != valueEnum {
+			return nil, fmt.Errorf("enum %q cannot represent non-enum value: %s", t.Name, describeValue(v))
+		}
+		return coerceEnum(t, v.raw)
+
+	case *Scalar:
+		var raw interface{}
+
+		switch v.kind {
+		case valueInt:
+			n, err := strconv.ParseInt(v.raw, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s cannot represent value: %s", t.Name, v.raw)
+			}
+			raw = n
+		case valueFloat:
+			f, err := strconv.ParseFloat(v.raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s cannot represent value: %s", t.Name, v.raw)
+			}
+			raw = f
+		case valueString:
+			raw = v.raw
+		case valueBoolean:
+			raw = v.raw == "true"
+		default:
+			return nil, fmt.Errorf("%s cannot represent value: %s", t.Name, describeValue(v))
+		}
+
+		return t.ParseValue(raw)
+	}
+
+	return nil, fmt.Errorf("type %q can't be used as input", t)
+}
+
+//coerceEnum checks that a value is one of an enum's values.
+func coerceEnum(t *Enum, v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		for _, value := range t.Values {
+			if s == value {
+				return s, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("value %v does not exist in %q enum", v, t.Name)
+}
+
+//describeValue describes a literal for error messages.
+func describeValue(v *value) string {
+	switch v.kind {
+	case valueString:
+		return strconv.Quote(v.raw)
+	case valueList:
+		return "a list"
+	case valueObject:
+		return "an object"
+	default:
+		return v.raw
+	}
+}
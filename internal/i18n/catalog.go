@@ -0,0 +1,208 @@
+package i18n
+
+//catalog maps a language to its messages, keyed by message key. Every key must have an English entry,
+//other languages may leave keys out and they will fall back to English.
+var catalog = map[string]map[string]string{
+	"en": {
+		//Validation messages
+		"validation.required":             "must be provided",
+		"validation.integer":              "must be an integer value",
+		"validation.boolean":              "must be true or false",
+		"validation.min":                  "must be at least %s",
+		"validation.max":                  "must not be more than %s",
+		"validation.between":              "must be between %s and %s",
+		"validation.min_length":           "must be at least %s bytes long",
+		"validation.max_length":           "must not be more than %s bytes long",
+		"validation.between_length":       "must be between %s and %s bytes long",
+		"validation.min_items":            "must contain at least %s items",
+		"validation.max_items":            "must not contain more than %s items",
+		"validation.between_items":        "must contain between %s and %s items",
+		"validation.min_length_one":       "must be at least %s byte long",
+		"validation.max_length_one":       "must not be more than %s byte long",
+		"validation.min_items_one":        "must contain at least %s item",
+		"validation.max_items_one":        "must not contain more than %s item",
+		"validation.oneof":                "must be one of %s",
+		"validation.unique":               "must not contain duplicate values",
+		"validation.email":                "must be a valid email address",
+		"validation.regexp":               "must match the required format",
+		"validation.year.future":          "must not be in the future",
+		"validation.page.min":             "must be greater than zero",
+		"validation.page.max":             "must be a maximum of 10 million",
+		"validation.page_size.min":        "must be greater than zero",
+		"validation.page_size.max":        "must be a maximum of 100",
+		"validation.sort.invalid":         "invalid sort value",
+		"validation.fields":               "must be a comma-separated list of fields from: %s",
+		"validation.revision_not_found":   "no revision exists for this version",
+		"validation.runtime_format":       "must be a runtime such as \"135 mins\", \"2h 15m\" or \"PT2H15M\"",
+		"validation.url":                  "must be an absolute http or https URL",
+		"validation.url_private":          "must not be on a loopback, link-local or private address",
+		"error.server":                    "the server encountered a problem and could not process your request",
+		"error.not_found":                 "The requested resource could not be found",
+		"error.movie_not_found":           "the requested movie could not be found",
+		"error.forbidden":                 "you need to be an administrator to do this",
+		"error.method_not_allowed":        "the %s method is not supported for this resource",
+		"error.edit_conflict":             "unable to update the record due to an edit conflict, please try again",
+		"error.precondition_failed":       "the record has changed since you last fetched it, please fetch it again and retry",
+		"error.unsupported_media_type":    "the %q content type is not supported for this request",
+		"error.not_acceptable":            "none of the requested response formats are available, try one of: %s",
+		"error.patch_test_failed":         "a test operation in the patch failed, the movie may have changed since you last fetched it",
+		"error.invalid_patch":             "the patch could not be applied: %s",
+		"error.validation_failed":         "one or more fields failed validation",
+		"error.import_invalid_rows":       "one or more rows failed validation, so nothing was imported",
+		"error.import_duplicates":         "one or more rows are duplicates, so nothing was imported",
+		"error.batch_rolled_back":         "not applied, as another operation in the atomic batch failed",
+		"error.idempotency_key_too_long":  "the Idempotency-Key header must not be more than %d characters long",
+		"error.idempotency_key_reused":    "this Idempotency-Key has already been used for a different request",
+		"error.idempotency_key_in_flight": "a request with this Idempotency-Key is still being processed, please retry later",
+		"json.badly_formed_at":            "body contains badly-formed JSON (at character %d)",
+		"json.badly_formed":               "body contains badly-formed JSON",
+		"json.incorrect_type_field":       "body contains incorrect JSON type for field %q",
+		"json.incorrect_type_at":          "body contains incorrect JSON type (at character %d)",
+		"json.empty":                      "body must not be empty",
+		"json.unknown_key":                "body contains unknown key %s",
+		"json.too_large":                  "body must not be larger than %d bytes",
+		"json.multiple_values":            "body must only contain a single JSON value",
+		"graphql.query_missing":           "body must contain a GraphQL query",
+		"import.format":                   "format must be one of %s",
+		"import.empty":                    "the file must not be empty",
+		"import.csv":                      "the CSV could not be read: %s",
+		"import.unknown_column":           "unknown column %q",
+		"import.missing_column":           "missing required column %q",
+		"import.column_count":             "must have %s columns, like the header row",
+		"import.line_too_long":            "line %d is too long",
+	},
+	"es": {
+		"validation.required":             "es obligatorio",
+		"validation.integer":              "debe ser un número entero",
+		"validation.boolean":              "debe ser true o false",
+		"validation.min":                  "debe ser como mínimo %s",
+		"validation.max":                  "no debe ser mayor que %s",
+		"validation.between":              "debe estar entre %s y %s",
+		"validation.min_length":           "debe tener al menos %s bytes",
+		"validation.max_length":           "no debe superar los %s bytes",
+		"validation.between_length":       "debe tener entre %s y %s bytes",
+		"validation.min_items":            "debe contener al menos %s elementos",
+		"validation.max_items":            "no debe contener más de %s elementos",
+		"validation.between_items":        "debe contener entre %s y %s elementos",
+		"validation.min_length_one":       "debe tener al menos %s byte",
+		"validation.max_length_one":       "no debe superar %s byte",
+		"validation.min_items_one":        "debe contener al menos %s elemento",
+		"validation.max_items_one":        "no debe contener más de %s elemento",
+		"validation.oneof":                "debe ser uno de %s",
+		"validation.unique":               "no debe contener valores duplicados",
+		"validation.email":                "debe ser una dirección de correo electrónico válida",
+		"validation.regexp":               "no tiene el formato requerido",
+		"validation.year.future":          "no debe estar en el futuro",
+		"validation.page.min":             "debe ser mayor que cero",
+		"validation.page.max":             "debe ser como máximo 10 millones",
+		"validation.page_size.min":        "debe ser mayor que cero",
+		"validation.page_size.max":        "debe ser como máximo 100",
+		"validation.sort.invalid":         "valor de ordenación no válido",
+		"validation.fields":               "debe ser una lista de campos separados por comas de: %s",
+		"validation.revision_not_found":   "no existe ninguna revisión para esta versión",
+		"validation.runtime_format":       "debe ser una duración como \"135 mins\", \"2h 15m\" o \"PT2H15M\"",
+		"validation.url":                  "debe ser una URL http o https absoluta",
+		"validation.url_private":          "no debe estar en una dirección de loopback, de enlace local o privada",
+		"error.server":                    "el servidor ha encontrado un problema y no ha podido procesar su solicitud",
+		"error.not_found":                 "no se ha encontrado el recurso solicitado",
+		"error.movie_not_found":           "no se ha encontrado la película solicitada",
+		"error.forbidden":                 "necesita ser administrador para hacer esto",
+		"error.method_not_allowed":        "el método %s no está permitido para este recurso",
+		"error.edit_conflict":             "no se ha podido actualizar el registro debido a un conflicto de edición, inténtelo de nuevo",
+		"error.precondition_failed":       "el registro ha cambiado desde la última vez que lo obtuvo, vuelva a obtenerlo e inténtelo de nuevo",
+		"error.unsupported_media_type":    "el tipo de contenido %q no es compatible con esta solicitud",
+		"error.not_acceptable":            "ninguno de los formatos de respuesta solicitados está disponible, pruebe uno de: %s",
+		"error.patch_test_failed":         "una operación test del parche ha fallado, es posible que la película haya cambiado desde la última vez que la obtuvo",
+		"error.invalid_patch":             "no se ha podido aplicar el parche: %s",
+		"error.validation_failed":         "uno o más campos no han superado la validación",
+		"error.import_invalid_rows":       "una o más filas no han superado la validación, por lo que no se ha importado nada",
+		"error.import_duplicates":         "una o más filas están duplicadas, por lo que no se ha importado nada",
+		"error.batch_rolled_back":         "no se ha aplicado, porque otra operación del lote atómico ha fallado",
+		"error.idempotency_key_too_long":  "la cabecera Idempotency-Key no debe superar los %d caracteres",
+		"error.idempotency_key_reused":    "esta Idempotency-Key ya se ha utilizado para una solicitud diferente",
+		"error.idempotency_key_in_flight": "una solicitud con esta Idempotency-Key todavía se está procesando, inténtelo más tarde",
+		"json.badly_formed_at":            "el cuerpo contiene JSON mal formado (en el carácter %d)",
+		"json.badly_formed":               "el cuerpo contiene JSON mal formado",
+		"json.incorrect_type_field":       "el cuerpo contiene un tipo JSON incorrecto para el campo %q",
+		"json.incorrect_type_at":          "el cuerpo contiene un tipo JSON incorrecto (en el carácter %d)",
+		"json.empty":                      "el cuerpo no debe estar vacío",
+		"json.unknown_key":                "el cuerpo contiene la clave desconocida %s",
+		"json.too_large":                  "el cuerpo no debe superar los %d bytes",
+		"json.multiple_values":            "el cuerpo solo debe contener un único valor JSON",
+		"graphql.query_missing":           "el cuerpo debe contener una consulta GraphQL",
+		"import.format":                   "el formato debe ser uno de %s",
+		"import.empty":                    "el archivo no debe estar vacío",
+		"import.csv":                      "no se ha podido leer el CSV: %s",
+		"import.unknown_column":           "columna desconocida %q",
+		"import.missing_column":           "falta la columna obligatoria %q",
+		"import.column_count":             "debe tener %s columnas, como la fila de encabezado",
+		"import.line_too_long":            "la línea %d es demasiado larga",
+	},
+	"de": {
+		"validation.required":             "muss angegeben werden",
+		"validation.integer":              "muss eine ganze Zahl sein",
+		"validation.boolean":              "muss true oder false sein",
+		"validation.min":                  "muss mindestens %s sein",
+		"validation.max":                  "darf höchstens %s sein",
+		"validation.between":              "muss zwischen %s und %s liegen",
+		"validation.min_length":           "muss mindestens %s Bytes lang sein",
+		"validation.max_length":           "darf nicht länger als %s Bytes sein",
+		"validation.between_length":       "muss zwischen %s und %s Bytes lang sein",
+		"validation.min_items":            "muss mindestens %s Einträge enthalten",
+		"validation.max_items":            "darf nicht mehr als %s Einträge enthalten",
+		"validation.between_items":        "muss zwischen %s und %s Einträge enthalten",
+		"validation.min_length_one":       "muss mindestens %s Byte lang sein",
+		"validation.max_length_one":       "darf nicht länger als %s Byte sein",
+		"validation.min_items_one":        "muss mindestens %s Eintrag enthalten",
+		"validation.max_items_one":        "darf nicht mehr als %s Eintrag enthalten",
+		"validation.oneof":                "muss einer der folgenden Werte sein: %s",
+		"validation.unique":               "darf keine doppelten Werte enthalten",
+		"validation.email":                "muss eine gültige E-Mail-Adresse sein",
+		"validation.regexp":               "entspricht nicht dem erforderlichen Format",
+		"validation.year.future":          "darf nicht in der Zukunft liegen",
+		"validation.page.min":             "muss größer als null sein",
+		"validation.page.max":             "darf höchstens 10 Millionen betragen",
+		"validation.page_size.min":        "muss größer als null sein",
+		"validation.page_size.max":        "darf höchstens 100 betragen",
+		"validation.sort.invalid":         "ungültiger Sortierwert",
+		"validation.fields":               "muss eine kommagetrennte Liste von Feldern aus folgenden sein: %s",
+		"validation.revision_not_found":   "für diese Version existiert keine Revision",
+		"validation.runtime_format":       "muss eine Laufzeit wie \"135 mins\", \"2h 15m\" oder \"PT2H15M\" sein",
+		"validation.url":                  "muss eine absolute http- oder https-URL sein",
+		"validation.url_private":          "darf nicht auf einer Loopback-, Link-Local- oder privaten Adresse liegen",
+		"error.server":                    "beim Server ist ein Problem aufgetreten, Ihre Anfrage konnte nicht verarbeitet werden",
+		"error.not_found":                 "die angeforderte Ressource wurde nicht gefunden",
+		"error.movie_not_found":           "der angeforderte Film wurde nicht gefunden",
+		"error.forbidden":                 "dafür müssen Sie Administrator sein",
+		"error.method_not_allowed":        "die Methode %s wird für diese Ressource nicht unterstützt",
+		"error.edit_conflict":             "der Datensatz konnte wegen eines Bearbeitungskonflikts nicht aktualisiert werden, bitte versuchen Sie es erneut",
+		"error.precondition_failed":       "der Datensatz wurde seit Ihrem letzten Abruf geändert, bitte rufen Sie ihn erneut ab und versuchen Sie es noch einmal",
+		"error.unsupported_media_type":    "der Inhaltstyp %q wird für diese Anfrage nicht unterstützt",
+		"error.not_acceptable":            "keines der angeforderten Antwortformate ist verfügbar, versuchen Sie eines von: %s",
+		"error.patch_test_failed":         "eine test-Operation im Patch ist fehlgeschlagen, der Film wurde möglicherweise seit Ihrem letzten Abruf geändert",
+		"error.invalid_patch":             "der Patch konnte nicht angewendet werden: %s",
+		"error.validation_failed":         "ein oder mehrere Felder sind ungültig",
+		"error.import_invalid_rows":       "eine oder mehrere Zeilen sind ungültig, daher wurde nichts importiert",
+		"error.import_duplicates":         "eine oder mehrere Zeilen sind Duplikate, daher wurde nichts importiert",
+		"error.batch_rolled_back":         "nicht angewendet, da ein anderer Vorgang im atomaren Stapel fehlgeschlagen ist",
+		"error.idempotency_key_too_long":  "der Idempotency-Key-Header darf nicht länger als %d Zeichen sein",
+		"error.idempotency_key_reused":    "dieser Idempotency-Key wurde bereits für eine andere Anfrage verwendet",
+		"error.idempotency_key_in_flight": "eine Anfrage mit diesem Idempotency-Key wird noch verarbeitet, bitte versuchen Sie es später erneut",
+		"json.badly_formed_at":            "der Body enthält fehlerhaftes JSON (bei Zeichen %d)",
+		"json.badly_formed":               "der Body enthält fehlerhaftes JSON",
+		"json.incorrect_type_field":       "der Body enthält einen falschen JSON-Typ für das Feld %q",
+		"json.incorrect_type_at":          "der Body enthält einen falschen JSON-Typ (bei Zeichen %d)",
+		"json.empty":                      "der Body darf nicht leer sein",
+		"json.unknown_key":                "der Body enthält den unbekannten Schlüssel %s",
+		"json.too_large":                  "der Body darf nicht größer als %d Bytes sein",
+		"json.multiple_values":            "der Body darf nur einen einzigen JSON-Wert enthalten",
+		"graphql.query_missing":           "der Body muss eine GraphQL-Abfrage enthalten",
+		"import.format":                   "das Format muss eines von %s sein",
+		"import.empty":                    "die Datei darf nicht leer sein",
+		"import.csv":                      "die CSV-Datei konnte nicht gelesen werden: %s",
+		"import.unknown_column":           "unbekannte Spalte %q",
+		"import.missing_column":           "die Pflichtspalte %q fehlt",
+		"import.column_count":             "muss wie die Kopfzeile %s Spalten haben",
+		"import.line_too_long":            "Zeile %d ist zu lang",
+	},
+}
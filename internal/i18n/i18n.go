@@ -0,0 +1,97 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/accept"
+)
+
+//Fallback is the language used when the client doesn't ask for one we support, or a message is missing from a catalog.
+const Fallback = "en"
+
+//Message is an error which carries a catalog key and its arguments, so it can be translated
+//into the client's language when the response is written. Its Error() method returns the English text.
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+//Error creates a new translatable error from a catalog key.
+func Error(key string, args ...interface{}) *Message {
+	return &Message{Key: key, Args: args}
+}
+
+func (m *Message) Error() string {
+	return T(Fallback, m.Key, m.Args...)
+}
+
+//Translate returns the message in the given language.
+func (m *Message) Translate(lang string) string {
+	return T(lang, m.Key, m.Args...)
+}
+
+//T looks up a message key in the catalog for a language and formats it with any arguments. If the language doesn't
+//have the key we fall back to English, and if English doesn't either we return the key itself.
+//Keys built with Key() have their embedded arguments placed before any passed in.
+func T(lang, key string, args ...interface{}) string {
+	if strings.Contains(key, argSeparator) {
+		var embedded []interface{}
+		key, embedded = splitKey(key)
+		args = append(embedded, args...)
+	}
+
+	format, ok := catalog[lang][key]
+	if !ok {
+		format, ok = catalog[Fallback][key]
+		if !ok {
+			return key
+		}
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+//Supported reports whether there is a catalog for a language.
+func Supported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+//Negotiate picks the best supported language for an Accept-Language header value such as "de-CH, de;q=0.9, en;q=0.5".
+//Languages are tried in order of their q-value, a region-specific tag matches its base language, and anything we
+//can't satisfy falls back to English.
+func Negotiate(header string) string {
+	var prefs []accept.Preference
+
+	for _, pref := range accept.Parse(header) {
+		if pref.Q > 0 {
+			prefs = append(prefs, pref)
+		}
+	}
+
+	//A stable sort keeps the client's order for languages with the same q-value
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].Q > prefs[j].Q })
+
+	for _, p := range prefs {
+		if p.Value == "*" {
+			return Fallback
+		}
+
+		if Supported(p.Value) {
+			return p.Value
+		}
+
+		base, _, _ := strings.Cut(p.Value, "-")
+		if Supported(base) {
+			return base
+		}
+	}
+
+	return Fallback
+}
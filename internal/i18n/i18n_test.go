@@ -0,0 +1,91 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	//Add a message which only has an English translation, so the fallback can be tested
+	catalog[Fallback]["test.english_only"] = "only in %s"
+	defer delete(catalog[Fallback], "test.english_only")
+
+	tests := []struct {
+		name string
+		lang string
+		key  string
+		args []interface{}
+		want string
+	}{
+		{"English", "en", "error.forbidden", nil, "you need to be an administrator to do this"},
+		{"Spanish", "es", "error.forbidden", nil, "necesita ser administrador para hacer esto"},
+		{"German", "de", "error.forbidden", nil, "dafür müssen Sie Administrator sein"},
+		{"arguments", "de", "validation.max_length", []interface{}{"500"}, "darf nicht länger als 500 Bytes sein"},
+		{"key with arguments", "es", Key("validation.max_length", "500"), nil, "no debe superar los 500 bytes"},
+		{"unsupported language", "fr", "error.forbidden", nil, "you need to be an administrator to do this"},
+		{"missing translation", "de", "test.english_only", []interface{}{"English"}, "only in English"},
+		{"unknown key", "de", "test.unknown", nil, "test.unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	err := Error("validation.max_length", "500")
+
+	if err.Error() != "must not be more than 500 bytes long" {
+		t.Errorf("got %q", err.Error())
+	}
+	if got := err.Translate("es"); got != "no debe superar los 500 bytes" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"ES", "es"},
+		{"de-CH", "de"},
+		{"fr, es;q=0.5", "es"},
+		{"en;q=0.5, de;q=0.8", "de"},
+		{"de;q=0.8, es;q=0.8", "de"},
+		{"de;q=0, es;q=0.1", "es"},
+		{"*, de;q=0.5", "en"},
+		{"fr, ja", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := Negotiate(tt.header); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+//TestCatalogsMatch checks every language translates every English message, with the same number of arguments.
+func TestCatalogsMatch(t *testing.T) {
+	for lang, messages := range catalog {
+		for key, english := range catalog[Fallback] {
+			translated, ok := messages[key]
+			if !ok {
+				t.Errorf("%s has no translation for %q", lang, key)
+				continue
+			}
+
+			if strings.Count(translated, "%") != strings.Count(english, "%") {
+				t.Errorf("%s translation of %q has different arguments: %q", lang, key, translated)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package i18n
+
+import (
+	"strings"
+)
+
+//argSeparator separates a message key from its arguments in a key built by Key().
+const argSeparator = "|"
+
+//Key builds a message key which carries string arguments, such as "validation.max_length|500". This lets code that can
+//only store plain strings, like the validator's error map, record a parameterised message and still have it translated.
+func Key(key string, args ...string) string {
+	if len(args) == 0 {
+		return key
+	}
+
+	return key + argSeparator + strings.Join(args, argSeparator)
+}
+
+//splitKey separates a key built by Key() into the catalog key and its arguments.
+func splitKey(key string) (string, []interface{}) {
+	parts := strings.Split(key, argSeparator)
+
+	args := make([]interface{}, 0, len(parts)-1)
+	for _, arg := range parts[1:] {
+		args = append(args, arg)
+	}
+
+	return parts[0], args
+}
@@ -0,0 +1,396 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//Define the errors returned when a patch can't be applied. Callers can use errors.Is() to decide on a response:
+//a malformed patch is the client's mistake, a failed test means the document has changed, and anything else means
+//the patch was well-formed but doesn't fit the document (such as removing a path which doesn't exist).
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrTestFailed   = errors.New("test operation failed")
+	ErrCannotApply  = errors.New("patch cannot be applied")
+)
+
+//MergePatch applies an RFC 7396 JSON Merge Patch to a JSON document. Members of the patch replace those in the
+//document, objects are merged recursively, and null values remove members.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+//Operation is a single RFC 6902 JSON Patch operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+//Apply applies an RFC 6902 JSON Patch to a JSON document. The operations are applied in order and the patch is atomic:
+//if any operation fails, including a "test", an error is returned and the document is not changed.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&ops)
+	if err == nil {
+		err = expectEOF(dec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		target, err = applyOperation(target, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func applyOperation(doc interface{}, op Operation) (interface{}, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: missing path", ErrInvalidPatch)
+	}
+
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: missing value", ErrInvalidPatch)
+		}
+
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			doc, _, err = remove(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, fmt.Errorf("%w: value at %s does not match", ErrTestFailed, *op.Path)
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: missing from", ErrInvalidPatch)
+		}
+
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+
+		if op.Op == "move" {
+			if *op.Path != *op.From && strings.HasPrefix(*op.Path, *op.From+"/") {
+				return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrCannotApply)
+			}
+			doc, value, err = remove(doc, from)
+		} else {
+			value, err = get(doc, from)
+			if err == nil {
+				value, err = deepCopy(value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return add(doc, path, value)
+	}
+
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+//add sets the value at a path. For arrays the value is inserted at the index, or appended for "-".
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return mutate(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[token] = value
+			return p, nil
+		case []interface{}:
+			i := len(p)
+			if token != "-" {
+				var err error
+				i, err = arrayIndex(token, len(p)+1)
+				if err != nil {
+					return nil, err
+				}
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		}
+		return nil, fmt.Errorf("%w: cannot add to a scalar value", ErrCannotApply)
+	})
+}
+
+//remove deletes the value at a path, returning the new document and the removed value.
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	var removed interface{}
+
+	doc, err := mutate(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			value, ok := p[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrCannotApply, token)
+			}
+			removed = value
+			delete(p, token)
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(p))
+			if err != nil {
+				return nil, err
+			}
+			removed = p[i]
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%w: cannot remove from a scalar value", ErrCannotApply)
+	})
+
+	return doc, removed, err
+}
+
+//get returns the value at a path.
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			value, ok := d[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrCannotApply, token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(d))
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("%w: cannot index into a scalar value", ErrCannotApply)
+		}
+	}
+
+	return doc, nil
+}
+
+//mutate walks to the parent of the last token in the path and calls fn to change it. As changing an array can
+//reallocate it, each container on the way back up is updated with its new child.
+func mutate(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	child, err := get(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+
+	child, err = mutate(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		d[path[0]] = child
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(d))
+		d[i] = child
+	}
+
+	return doc, nil
+}
+
+//parsePointer splits an RFC 6901 JSON pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: %q is not a JSON pointer", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+//arrayIndex parses an array index token, which must be a number without leading zeros, less than the limit.
+func arrayIndex(token string, limit int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrCannotApply, token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= limit {
+		return 0, fmt.Errorf("%w: array index %q is out of range", ErrCannotApply, token)
+	}
+
+	return i, nil
+}
+
+//equal compares two decoded JSON values. Numbers are compared by value, so 1 and 1.0 are equal.
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	default:
+		return a == b
+	}
+}
+
+//deepCopy copies a decoded JSON value, so a copied value can't be changed through the original.
+func deepCopy(value interface{}) (interface{}, error) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return decode(js)
+}
+
+//decode decodes a JSON document, keeping numbers as json.Number so integers survive the round trip exactly.
+func decode(js []byte) (interface{}, error) {
+	var value interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	err := dec.Decode(&value)
+	if err == nil {
+		err = expectEOF(dec)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+//expectEOF returns an error if there is anything other than whitespace after the value the decoder has just read,
+//like a second value, so that a body such as "{} {}" isn't taken as just its first value.
+func expectEOF(dec *json.Decoder) error {
+	err := dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("must only contain a single JSON value")
+	}
+	return nil
+}
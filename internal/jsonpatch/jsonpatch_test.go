@@ -0,0 +1,165 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testDoc = `{"id":1,"title":"Alien","genres":["horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"}}`
+
+//assertJSON fails the test unless got and want are the same JSON value.
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("got invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("bad want %s: %v", want, err)
+	}
+
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			"add member",
+			`[{"op":"add","path":"/year","value":1979}]`,
+			`{"id":1,"title":"Alien","genres":["horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"},"year":1979}`,
+		},
+		{
+			"append to array",
+			`[{"op":"add","path":"/genres/-","value":"thriller"}]`,
+			`{"id":1,"title":"Alien","genres":["horror","sci-fi","thriller"],"version":3,"cast":{"lead":"Weaver"}}`,
+		},
+		{
+			"insert into array",
+			`[{"op":"add","path":"/genres/0","value":"classic"}]`,
+			`{"id":1,"title":"Alien","genres":["classic","horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"}}`,
+		},
+		{
+			"remove from array",
+			`[{"op":"remove","path":"/genres/0"}]`,
+			`{"id":1,"title":"Alien","genres":["sci-fi"],"version":3,"cast":{"lead":"Weaver"}}`,
+		},
+		{
+			"test then replace",
+			`[{"op":"test","path":"/version","value":3},{"op":"replace","path":"/title","value":"Aliens"}]`,
+			`{"id":1,"title":"Aliens","genres":["horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"}}`,
+		},
+		{
+			"move",
+			`[{"op":"move","from":"/cast/lead","path":"/lead"}]`,
+			`{"id":1,"title":"Alien","genres":["horror","sci-fi"],"version":3,"cast":{},"lead":"Weaver"}`,
+		},
+		{
+			"copy is independent",
+			`[{"op":"copy","from":"/genres","path":"/tags"},{"op":"add","path":"/tags/-","value":"space"}]`,
+			`{"id":1,"title":"Alien","genres":["horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"},"tags":["horror","sci-fi","space"]}`,
+		},
+		{
+			"escaped pointer",
+			`[{"op":"add","path":"/a~1b~0c","value":true}]`,
+			`{"id":1,"title":"Alien","genres":["horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"},"a/b~c":true}`,
+		},
+		{
+			"replace whole document",
+			`[{"op":"replace","path":"","value":{"id":2}}]`,
+			`{"id":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(testDoc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  error
+	}{
+		{"not an array", `{"op":"add"}`, ErrInvalidPatch},
+		{"trailing garbage", `[{"op":"remove","path":"/year"}] garbage`, ErrInvalidPatch},
+		{"two arrays", `[] []`, ErrInvalidPatch},
+		{"unknown op", `[{"op":"rename","path":"/title"}]`, ErrInvalidPatch},
+		{"missing path", `[{"op":"remove"}]`, ErrInvalidPatch},
+		{"missing value", `[{"op":"add","path":"/year"}]`, ErrInvalidPatch},
+		{"missing from", `[{"op":"copy","path":"/year"}]`, ErrInvalidPatch},
+		{"bad pointer", `[{"op":"remove","path":"title"}]`, ErrInvalidPatch},
+		{"failed test", `[{"op":"test","path":"/version","value":2}]`, ErrTestFailed},
+		{"missing member", `[{"op":"remove","path":"/year"}]`, ErrCannotApply},
+		{"index out of range", `[{"op":"add","path":"/genres/3","value":"x"}]`, ErrCannotApply},
+		{"leading zero index", `[{"op":"remove","path":"/genres/01"}]`, ErrCannotApply},
+		{"into a scalar", `[{"op":"add","path":"/title/x","value":1}]`, ErrCannotApply},
+		{"move into child", `[{"op":"move","from":"/cast","path":"/cast/old"}]`, ErrCannotApply},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply([]byte(testDoc), []byte(tt.patch))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyIsAtomic(t *testing.T) {
+	doc := []byte(testDoc)
+
+	_, err := Apply(doc, []byte(`[{"op":"replace","path":"/title","value":"Aliens"},{"op":"remove","path":"/year"}]`))
+	if !errors.Is(err, ErrCannotApply) {
+		t.Fatalf("got %v, want ErrCannotApply", err)
+	}
+
+	assertJSON(t, doc, testDoc)
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"title":"Aliens"}`, `{"id":1,"title":"Aliens","genres":["horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"}}`},
+		{"remove member", `{"title":null}`, `{"id":1,"genres":["horror","sci-fi"],"version":3,"cast":{"lead":"Weaver"}}`},
+		{"arrays are replaced", `{"genres":["action"]}`, `{"id":1,"title":"Alien","genres":["action"],"version":3,"cast":{"lead":"Weaver"}}`},
+		{"objects are merged", `{"cast":{"lead":null,"director":"Scott"}}`, `{"id":1,"title":"Alien","genres":["horror","sci-fi"],"version":3,"cast":{"director":"Scott"}}`},
+		{"non-object replaces document", `["x"]`, `["x"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(testDoc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+
+	if _, err := MergePatch([]byte(testDoc), []byte(`{"title":`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("malformed merge patch got %v, want ErrInvalidPatch", err)
+	}
+	if _, err := MergePatch([]byte(testDoc), []byte(`{} {"title":"Aliens"}`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("merge patch with two values got %v, want ErrInvalidPatch", err)
+	}
+}
@@ -0,0 +1,60 @@
+//Package movieio reads and writes movies in the bulk formats used for imports and exports: CSV with a header row,
+//and NDJSON (one JSON object per line).
+package movieio
+
+import (
+	"strings"
+)
+
+//Format is a bulk file format.
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+)
+
+//Formats lists the supported formats, for validating client input.
+var Formats = []string{string(CSV), string(NDJSON)}
+
+//MediaTypes lists the media types we accept for uploads.
+var MediaTypes = []string{"text/csv", "application/x-ndjson", "application/jsonl"}
+
+//mediaTypes maps the media types we accept to their format.
+var mediaTypes = map[string]Format{
+	"text/csv":             CSV,
+	"application/x-ndjson": NDJSON,
+	"application/jsonl":    NDJSON,
+}
+
+//FormatForMediaType returns the format for a Content-Type's media type.
+func FormatForMediaType(mediaType string) (Format, bool) {
+	format, ok := mediaTypes[strings.ToLower(mediaType)]
+	return format, ok
+}
+
+//MediaType returns the media type to send a format with.
+func (f Format) MediaType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+//FormatForFilename guesses the format from a file's extension, defaulting to CSV.
+func FormatForFilename(name string) Format {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ".ndjson") || strings.HasSuffix(name, ".jsonl") {
+		return NDJSON
+	}
+	return CSV
+}
+
+//Columns are the CSV columns, in the order they are written. Reading accepts them in any order, and only title,
+//year, runtime and genres are required.
+var Columns = []string{"id", "external_id", "title", "year", "runtime", "genres", "version"}
+
+//GenreSeparator separates the genres in a CSV cell, as commas are awkward in spreadsheets.
+const GenreSeparator = "|"
@@ -0,0 +1,241 @@
+package movieio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/data"
+	"firstAPI.jweaver11.net/internal/i18n"
+	"firstAPI.jweaver11.net/internal/validator"
+)
+
+//maxLineLength is the longest NDJSON line we read.
+const maxLineLength = 1_048_576
+
+//RowError lists the problems with one row. Row is the line number in the file, counting a CSV header as line 1, and
+//the messages are i18n catalog keys, keyed by field.
+type RowError struct {
+	Row    int
+	Errors map[string]string
+}
+
+//ReadAll reads and validates every movie in a file. Rows which fail validation are returned as row errors rather than
+//movies, so a whole file can be checked in one go. The error is only for problems with the file as a whole, like an
+//unknown CSV column, and is an i18n message where possible.
+func ReadAll(r io.Reader, format Format) ([]data.ImportRow, []RowError, error) {
+	switch format {
+	case CSV:
+		return readCSV(r)
+	case NDJSON:
+		return readNDJSON(r)
+	default:
+		return nil, nil, i18n.Error("import.format", strings.Join(Formats, ", "))
+	}
+}
+
+//readCSV reads movies from CSV with a header row. The id and version columns written by exports are accepted and
+//ignored, so an export can be imported into another database.
+func readCSV(r io.Reader) ([]data.ImportRow, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, i18n.Error("import.empty")
+		}
+		return nil, nil, csvError(err)
+	}
+
+	//Map each column name to its position
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if !validator.In(name, Columns...) {
+			return nil, nil, i18n.Error("import.unknown_column", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, i18n.Error("import.missing_column", name)
+		}
+	}
+
+	var rows []data.ImportRow
+	var rowErrors []RowError
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, csvError(err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		v := validator.New()
+
+		if len(record) != len(header) {
+			v.AddError("row", i18n.Key("import.column_count", strconv.Itoa(len(header))))
+			rowErrors = append(rowErrors, RowError{Row: line, Errors: v.Errors})
+			continue
+		}
+
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		movie := &data.Movie{
+			Title:      cell("title"),
+			ExternalID: cell("external_id"),
+		}
+
+		//Empty cells are left as zero values, for ValidateMovie() to report as missing
+		if s := cell("year"); s != "" {
+			year, err := strconv.ParseInt(s, 10, 32)
+			if err != nil {
+				v.AddError("year", "validation.integer")
+			}
+			movie.Year = int32(year)
+		}
+
+		if s := cell("runtime"); s != "" {
+			runtime, err := data.ParseRuntime(s)
+			if err != nil {
+				v.AddError("runtime", "validation.runtime_format")
+			}
+			movie.Runtime = runtime
+		}
+
+		for _, genre := range strings.Split(cell("genres"), GenreSeparator) {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				movie.Genres = append(movie.Genres, genre)
+			}
+		}
+
+		rows, rowErrors = addRow(rows, rowErrors, line, movie, v)
+	}
+
+	return rows, rowErrors, nil
+}
+
+//csvError returns an i18n message for a CSV syntax error. Other errors, from reading the file itself, are returned as they are.
+func csvError(err error) error {
+	var parseError *csv.ParseError
+	if errors.As(err, &parseError) {
+		return i18n.Error("import.csv", err.Error())
+	}
+	return err
+}
+
+//ndjsonMovie is one line of an NDJSON file. As with CSV, the id and version written by exports are ignored.
+type ndjsonMovie struct {
+	ID         int64        `json:"id"`
+	ExternalID string       `json:"external_id"`
+	Title      string       `json:"title"`
+	Year       int32        `json:"year"`
+	Runtime    data.Runtime `json:"runtime"`
+	Genres     []string     `json:"genres"`
+	Version    int32        `json:"version"`
+}
+
+//readNDJSON reads movies from NDJSON, skipping blank lines.
+func readNDJSON(r io.Reader) ([]data.ImportRow, []RowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var rows []data.ImportRow
+	var rowErrors []RowError
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		v := validator.New()
+
+		var input ndjsonMovie
+
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+
+		err := dec.Decode(&input)
+		if err == nil && dec.More() {
+			err = errors.New("multiple values")
+		}
+		if err != nil {
+			field, key := decodeErrorKey(err)
+			v.AddError(field, key)
+			rowErrors = append(rowErrors, RowError{Row: line, Errors: v.Errors})
+			continue
+		}
+
+		movie := &data.Movie{
+			ExternalID: input.ExternalID,
+			Title:      input.Title,
+			Year:       input.Year,
+			Runtime:    input.Runtime,
+			Genres:     input.Genres,
+		}
+
+		rows, rowErrors = addRow(rows, rowErrors, line, movie, v)
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, nil, i18n.Error("import.line_too_long", line+1)
+		}
+		return nil, nil, err
+	}
+
+	if line == 0 {
+		return nil, nil, i18n.Error("import.empty")
+	}
+
+	return rows, rowErrors, nil
+}
+
+//decodeErrorKey returns the field and i18n key to report for a line which couldn't be decoded.
+func decodeErrorKey(err error) (string, string) {
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, data.ErrInvalidRuntimeFormat), errors.Is(err, data.ErrNegativeRuntime), errors.Is(err, data.ErrRuntimeOverflow):
+		return "runtime", "validation.runtime_format"
+	case errors.As(err, &typeError) && typeError.Field != "":
+		return typeError.Field, i18n.Key("json.incorrect_type_field", typeError.Field)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "row", i18n.Key("json.unknown_key", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return "row", "json.badly_formed"
+	}
+}
+
+//addRow validates a movie, adding it to the rows if it's valid and to the row errors if not. Any errors already in
+//v, from reading the row, are kept.
+func addRow(rows []data.ImportRow, rowErrors []RowError, line int, movie *data.Movie, v *validator.Validator) ([]data.ImportRow, []RowError) {
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return rows, append(rowErrors, RowError{Row: line, Errors: v.Errors})
+	}
+
+	return append(rows, data.ImportRow{Row: line, Movie: movie}), rowErrors
+}
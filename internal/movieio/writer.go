@@ -0,0 +1,69 @@
+package movieio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"firstAPI.jweaver11.net/internal/data"
+)
+
+//Writer writes movies in a bulk format. Output is buffered, so Flush() must be called when done.
+type Writer struct {
+	format Format
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+//NewWriter returns a writer for the format. For CSV, the header row is written straight away, so even an empty export
+//has one.
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	mw := &Writer{format: format, buf: bufio.NewWriter(w)}
+
+	switch format {
+	case CSV:
+		mw.csv = csv.NewWriter(mw.buf)
+		if err := mw.csv.Write(Columns); err != nil {
+			return nil, err
+		}
+	default:
+		mw.json = json.NewEncoder(mw.buf)
+	}
+
+	return mw, nil
+}
+
+//Write writes a movie. In CSV the runtime is an integer number of minutes and the genres are joined with
+//GenreSeparator, while NDJSON lines match the movie's JSON in API responses.
+func (mw *Writer) Write(movie *data.Movie) error {
+	if mw.csv != nil {
+		return mw.csv.Write([]string{
+			strconv.FormatInt(movie.ID, 10),
+			movie.ExternalID,
+			movie.Title,
+			strconv.FormatInt(int64(movie.Year), 10),
+			strconv.FormatInt(int64(movie.Runtime), 10),
+			strings.Join(movie.Genres, GenreSeparator),
+			strconv.FormatInt(int64(movie.Version), 10),
+		})
+	}
+
+	//Encode() ends each value with a newline, which is just what NDJSON needs
+	return mw.json.Encode(movie)
+}
+
+//Flush writes any buffered output to the underlying writer.
+func (mw *Writer) Flush() error {
+	if mw.csv != nil {
+		mw.csv.Flush()
+		if err := mw.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	return mw.buf.Flush()
+}
@@ -0,0 +1,246 @@
+//Package moviepb holds the messages of the movies.v1 gRPC API defined in movies.proto. They are written by hand, as
+//the encoding is simple enough not to need generated code, so any change to movies.proto must be made here as well.
+package moviepb
+
+import "firstAPI.jweaver11.net/internal/rpc"
+
+//The full names of the MovieService methods, as used in the paths of calls.
+const (
+	MovieServiceCreateMovie = "/movies.v1.MovieService/CreateMovie"
+	MovieServiceGetMovie    = "/movies.v1.MovieService/GetMovie"
+	MovieServiceUpdateMovie = "/movies.v1.MovieService/UpdateMovie"
+	MovieServiceDeleteMovie = "/movies.v1.MovieService/DeleteMovie"
+	MovieServiceListMovies  = "/movies.v1.MovieService/ListMovies"
+)
+
+type Movie struct {
+	ID         int64
+	Title      string
+	Year       int32
+	Runtime    int32
+	Genres     []string
+	Version    int32
+	ExternalID string
+}
+
+func (m *Movie) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	e.String(2, m.Title)
+	e.Int32(3, m.Year)
+	e.Int32(4, m.Runtime)
+	e.Strings(5, m.Genres)
+	e.Int32(6, m.Version)
+	e.String(7, m.ExternalID)
+	return e.Bytes()
+}
+
+func (m *Movie) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.ID = f.Int64()
+		case 2:
+			m.Title = f.String()
+		case 3:
+			m.Year = f.Int32()
+		case 4:
+			m.Runtime = f.Int32()
+		case 5:
+			m.Genres = append(m.Genres, f.String())
+		case 6:
+			m.Version = f.Int32()
+		case 7:
+			m.ExternalID = f.String()
+		}
+		return nil
+	})
+}
+
+type CreateMovieRequest struct {
+	Title   string
+	Year    int32
+	Runtime int32
+	Genres  []string
+}
+
+func (m *CreateMovieRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.String(1, m.Title)
+	e.Int32(2, m.Year)
+	e.Int32(3, m.Runtime)
+	e.Strings(4, m.Genres)
+	return e.Bytes()
+}
+
+func (m *CreateMovieRequest) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.Title = f.String()
+		case 2:
+			m.Year = f.Int32()
+		case 3:
+			m.Runtime = f.Int32()
+		case 4:
+			m.Genres = append(m.Genres, f.String())
+		}
+		return nil
+	})
+}
+
+type GetMovieRequest struct {
+	ID int64
+}
+
+func (m *GetMovieRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	return e.Bytes()
+}
+
+func (m *GetMovieRequest) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error {
+		if f.Num == 1 {
+			m.ID = f.Int64()
+		}
+		return nil
+	})
+}
+
+//UpdateMovieRequest changes only the fields which are set, which are the ones which aren't nil.
+type UpdateMovieRequest struct {
+	ID      int64
+	Version *int32
+	Title   *string
+	Year    *int32
+	Runtime *int32
+	Genres  *Genres
+}
+
+func (m *UpdateMovieRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	e.OptionalInt32(2, m.Version)
+	e.OptionalString(3, m.Title)
+	e.OptionalInt32(4, m.Year)
+	e.OptionalInt32(5, m.Runtime)
+	if m.Genres != nil {
+		e.Message(6, m.Genres)
+	}
+	return e.Bytes()
+}
+
+func (m *UpdateMovieRequest) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.ID = f.Int64()
+		case 2:
+			m.Version = int32Ptr(f.Int32())
+		case 3:
+			s := f.String()
+			m.Title = &s
+		case 4:
+			m.Year = int32Ptr(f.Int32())
+		case 5:
+			m.Runtime = int32Ptr(f.Int32())
+		case 6:
+			//A message field may be split over several occurrences, which are merged
+			if m.Genres == nil {
+				m.Genres = &Genres{}
+			}
+			return m.Genres.UnmarshalProto(f.Bytes())
+		}
+		return nil
+	})
+}
+
+type Genres struct {
+	Values []string
+}
+
+func (m *Genres) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Strings(1, m.Values)
+	return e.Bytes()
+}
+
+func (m *Genres) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error {
+		if f.Num == 1 {
+			m.Values = append(m.Values, f.String())
+		}
+		return nil
+	})
+}
+
+type DeleteMovieRequest struct {
+	ID      int64
+	Version *int32
+}
+
+func (m *DeleteMovieRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	e.OptionalInt32(2, m.Version)
+	return e.Bytes()
+}
+
+func (m *DeleteMovieRequest) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.ID = f.Int64()
+		case 2:
+			m.Version = int32Ptr(f.Int32())
+		}
+		return nil
+	})
+}
+
+type DeleteMovieResponse struct{}
+
+func (m *DeleteMovieResponse) MarshalProto() []byte {
+	return nil
+}
+
+func (m *DeleteMovieResponse) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error { return nil })
+}
+
+type ListMoviesRequest struct {
+	Title   string
+	Genres  []string
+	Sort    string
+	Deleted bool
+}
+
+func (m *ListMoviesRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.String(1, m.Title)
+	e.Strings(2, m.Genres)
+	e.String(3, m.Sort)
+	e.Bool(4, m.Deleted)
+	return e.Bytes()
+}
+
+func (m *ListMoviesRequest) UnmarshalProto(b []byte) error {
+	return rpc.DecodeFields(b, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.Title = f.String()
+		case 2:
+			m.Genres = append(m.Genres, f.String())
+		case 3:
+			m.Sort = f.String()
+		case 4:
+			m.Deleted = f.Bool()
+		}
+		return nil
+	})
+}
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
@@ -0,0 +1,240 @@
+//Package rpc is a small gRPC server, built on net/http's HTTP/2 support, for services whose messages are encoded by
+//hand with the Encoder and DecodeFields in this package. It supports unary and server streaming methods, deadlines
+//from the grpc-timeout header, and rich error details in the grpc-status-details-bin trailer. Messages must not be
+//compressed. See https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md for the protocol.
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//DefaultMaxMessageSize is the largest request message a Server accepts unless it's told otherwise, which is the same
+//as the standard gRPC libraries.
+const DefaultMaxMessageSize = 4 << 20
+
+//Handler handles a call to a method. A unary method receives one message and sends one, and a server streaming method
+//receives one message and sends any number. Returning a *Status fails the call with its code. Any other error is sent as
+//Unknown, so handlers should map errors to codes themselves.
+type Handler func(call *Call) error
+
+//Server serves gRPC calls over HTTP. It's an http.Handler, so it's served by an http.Server, which must speak HTTP/2.
+type Server struct {
+	MaxMessageSize int
+	methods        map[string]Handler
+}
+
+//NewServer returns a server with no methods.
+func NewServer() *Server {
+	return &Server{MaxMessageSize: DefaultMaxMessageSize, methods: make(map[string]Handler)}
+}
+
+//Handle registers the handler for a method, given by its full name, like "/movies.v1.MovieService/GetMovie".
+func (s *Server) Handle(method string, h Handler) {
+	s.methods[method] = h
+}
+
+//ServeHTTP runs a call. The response always has a 200 OK status, and the call's own status goes in the trailers.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "gRPC requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/grpc" && !strings.HasPrefix(contentType, "application/grpc+proto") {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+
+	ctx := r.Context()
+
+	if timeout := r.Header.Get("Grpc-Timeout"); timeout != "" {
+		d, err := parseTimeout(timeout)
+		if err != nil {
+			writeStatus(w, Errorf(Internal, "malformed grpc-timeout: %v", err))
+			return
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	call := &Call{
+		Request: r.WithContext(ctx),
+		w:       w,
+		maxSize: s.MaxMessageSize,
+	}
+
+	handler, ok := s.methods[r.URL.Path]
+	if !ok {
+		writeStatus(w, Errorf(Unimplemented, "unknown method %s", r.URL.Path))
+		return
+	}
+
+	err := handler(call)
+
+	//Report a call which ran out of time or was cancelled as such, rather than as whatever error that caused
+	if err != nil && ctx.Err() != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			err = Errorf(DeadlineExceeded, "deadline exceeded")
+		default:
+			err = Errorf(Canceled, "call cancelled")
+		}
+	}
+
+	writeStatus(w, err)
+}
+
+//Call is a single call to a method.
+type Call struct {
+	//Request is the HTTP request carrying the call. Its headers are the call's metadata, and its context is cancelled
+	//when the client goes away or the deadline passes.
+	Request *http.Request
+
+	w       http.ResponseWriter
+	maxSize int
+}
+
+//Context returns the call's context.
+func (c *Call) Context() context.Context {
+	return c.Request.Context()
+}
+
+//Recv reads the request message into m. Its errors are a *Status, so handlers can return them as they are.
+func (c *Call) Recv(m Message) error {
+	var header [5]byte
+
+	if _, err := io.ReadFull(c.Request.Body, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Errorf(InvalidArgument, "missing request message")
+		}
+		return Errorf(Internal, "reading request: %v", err)
+	}
+
+	if header[0] != 0 {
+		return Errorf(Unimplemented, "compressed messages are not supported")
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if int64(size) > int64(c.maxSize) {
+		return Errorf(ResourceExhausted, "request message larger than max (%d vs. %d)", size, c.maxSize)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.Request.Body, body); err != nil {
+		return Errorf(Internal, "reading request: %v", err)
+	}
+
+	if err := m.UnmarshalProto(body); err != nil {
+		return Errorf(Internal, "decoding request: %v", err)
+	}
+
+	return nil
+}
+
+//Send writes a response message, and flushes it to the client straight away.
+func (c *Call) Send(m Message) error {
+	if err := c.Context().Err(); err != nil {
+		return err
+	}
+
+	body := m.MarshalProto()
+
+	frame := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+	frame = append(frame, body...)
+
+	if _, err := c.w.Write(frame); err != nil {
+		return err
+	}
+
+	if f, ok := c.w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
+
+//writeStatus writes the call's status in the trailers.
+func writeStatus(w http.ResponseWriter, err error) {
+	status := &Status{Code: OK}
+
+	if err != nil {
+		var s *Status
+		if errors.As(err, &s) {
+			status = s
+		} else {
+			status = &Status{Code: Unknown, Message: err.Error()}
+		}
+	}
+
+	h := w.Header()
+	h.Set(http.TrailerPrefix+"Grpc-Status", strconv.FormatUint(uint64(status.Code), 10))
+
+	if status.Message != "" {
+		h.Set(http.TrailerPrefix+"Grpc-Message", encodeMessage(status.Message))
+	}
+
+	if len(status.Details) > 0 {
+		h.Set(http.TrailerPrefix+"Grpc-Status-Details-Bin", base64.RawStdEncoding.EncodeToString(status.MarshalProto()))
+	}
+}
+
+//encodeMessage percent-encodes a status message for the grpc-message trailer, which can only hold printable ASCII.
+func encodeMessage(msg string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+//parseTimeout parses a grpc-timeout header, like "100m" for 100 milliseconds.
+func parseTimeout(s string) (time.Duration, error) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, fmt.Errorf("%q is not a valid timeout", s)
+	}
+
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a valid timeout", s)
+	}
+
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, fmt.Errorf("%q has an unknown unit", s)
+	}
+
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		s     string
+		want  time.Duration
+		valid bool
+	}{
+		{"100m", 100 * time.Millisecond, true},
+		{"1H", time.Hour, true},
+		{"5S", 5 * time.Second, true},
+		{"250u", 250 * time.Microsecond, true},
+		{"99999999n", 99999999 * time.Nanosecond, true},
+		{"m", 0, false},
+		{"1000000000S", 0, false},
+		{"10x", 0, false},
+		{"-1S", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseTimeout(tt.s)
+			if (err == nil) != tt.valid || got != tt.want {
+				t.Errorf("got %v, %v, want %v (valid %t)", got, err, tt.want, tt.valid)
+			}
+		})
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	got := encodeMessage("100% válido\n")
+	if want := "100%25 v%C3%A1lido%0A"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
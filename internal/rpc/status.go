@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//Code is a gRPC status code. See https://grpc.github.io/grpc/core/md_doc_statuscodes.html.
+type Code uint32
+
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = [...]string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound", "AlreadyExists", "PermissionDenied",
+	"ResourceExhausted", "FailedPrecondition", "Aborted", "OutOfRange", "Unimplemented", "Internal", "Unavailable",
+	"DataLoss", "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+//Status is the outcome of a call, sent to the client in the response trailers. It's the error handlers return to fail
+//a call with a particular code, and any other error is sent as Unknown. Details carry extra, typed information, such as
+//the field violations in a BadRequest.
+type Status struct {
+	Code    Code
+	Message string
+	Details []Any
+}
+
+//Errorf returns a Status with the given code and a formatted message.
+func Errorf(code Code, format string, args ...interface{}) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Status) Error() string {
+	return "rpc error: code = " + s.Code.String() + " desc = " + s.Message
+}
+
+//MarshalProto encodes the status as a google.rpc.Status message, as sent in the grpc-status-details-bin trailer.
+func (s *Status) MarshalProto() []byte {
+	var e Encoder
+	e.Int32(1, int32(s.Code))
+	e.String(2, s.Message)
+	for i := range s.Details {
+		e.Message(3, &s.Details[i])
+	}
+	return e.Bytes()
+}
+
+//UnmarshalProto decodes a google.rpc.Status message.
+func (s *Status) UnmarshalProto(b []byte) error {
+	return DecodeFields(b, func(f Field) error {
+		switch f.Num {
+		case 1:
+			s.Code = Code(f.Int32())
+		case 2:
+			s.Message = f.String()
+		case 3:
+			var detail Any
+			if err := detail.UnmarshalProto(f.Bytes()); err != nil {
+				return err
+			}
+			s.Details = append(s.Details, detail)
+		}
+		return nil
+	})
+}
+
+//Any is a google.protobuf.Any: a message along with the URL of its type.
+type Any struct {
+	TypeURL string
+	Value   []byte
+}
+
+func (a *Any) MarshalProto() []byte {
+	var e Encoder
+	e.String(1, a.TypeURL)
+	e.BytesField(2, a.Value)
+	return e.Bytes()
+}
+
+func (a *Any) UnmarshalProto(b []byte) error {
+	return DecodeFields(b, func(f Field) error {
+		switch f.Num {
+		case 1:
+			a.TypeURL = f.String()
+		case 2:
+			a.Value = append([]byte(nil), f.Bytes()...)
+		}
+		return nil
+	})
+}
+
+//FieldViolation is one invalid field in a request. Field is a path to the field, like "genres[2]".
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+//BadRequest returns a google.rpc.BadRequest detail listing the invalid fields in a request, to go with an
+//InvalidArgument status. Clients built with the standard gRPC libraries can read it from the status details.
+func BadRequest(violations []FieldViolation) Any {
+	var e Encoder
+	for _, v := range violations {
+		var fv Encoder
+		fv.String(1, v.Field)
+		fv.String(2, v.Description)
+		e.bytes(1, fv.Bytes())
+	}
+
+	return Any{TypeURL: "type.googleapis.com/google.rpc.BadRequest", Value: e.Bytes()}
+}
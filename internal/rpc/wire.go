@@ -0,0 +1,243 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+//This file is a small protobuf wire format encoder and decoder, enough for messages made of integers, bools, strings,
+//bytes, repeated fields and nested messages. See https://protobuf.dev/programming-guides/encoding/.
+
+//Message is a protobuf message.
+type Message interface {
+	MarshalProto() []byte
+	UnmarshalProto(b []byte) error
+}
+
+//WireType is the type of a field on the wire, which says how its value is encoded.
+type WireType int
+
+const (
+	WireVarint  WireType = 0
+	WireFixed64 WireType = 1
+	WireBytes   WireType = 2
+	WireFixed32 WireType = 5
+)
+
+//ErrMalformed is returned when a message can't be decoded.
+var ErrMalformed = errors.New("rpc: malformed protobuf message")
+
+//Encoder appends fields to a message. Following proto3, the plain methods leave out fields with their default value,
+//while the Optional methods write any value which is set, as proto3 "optional" fields do.
+type Encoder struct {
+	buf []byte
+}
+
+//Bytes returns the encoded message.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+func (e *Encoder) tag(field int, wt WireType) {
+	e.buf = appendUvarint(e.buf, uint64(field)<<3|uint64(wt))
+}
+
+func (e *Encoder) varint(field int, v uint64) {
+	e.tag(field, WireVarint)
+	e.buf = appendUvarint(e.buf, v)
+}
+
+func (e *Encoder) bytes(field int, b []byte) {
+	e.tag(field, WireBytes)
+	e.buf = appendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+//Int64 writes an int64 field. Negative values take ten bytes, as protobuf's int64 does.
+func (e *Encoder) Int64(field int, v int64) {
+	if v != 0 {
+		e.varint(field, uint64(v))
+	}
+}
+
+//Int32 writes an int32 field, which is sign extended to 64 bits on the wire.
+func (e *Encoder) Int32(field int, v int32) {
+	if v != 0 {
+		e.varint(field, uint64(int64(v)))
+	}
+}
+
+//Uint32 writes a uint32 field.
+func (e *Encoder) Uint32(field int, v uint32) {
+	if v != 0 {
+		e.varint(field, uint64(v))
+	}
+}
+
+//Bool writes a bool field.
+func (e *Encoder) Bool(field int, v bool) {
+	if v {
+		e.varint(field, 1)
+	}
+}
+
+//String writes a string field.
+func (e *Encoder) String(field int, v string) {
+	if v != "" {
+		e.bytes(field, []byte(v))
+	}
+}
+
+//BytesField writes a bytes field.
+func (e *Encoder) BytesField(field int, v []byte) {
+	if len(v) > 0 {
+		e.bytes(field, v)
+	}
+}
+
+//Strings writes a repeated string field, one value at a time.
+func (e *Encoder) Strings(field int, v []string) {
+	for _, s := range v {
+		e.bytes(field, []byte(s))
+	}
+}
+
+//Message writes a nested message field. Message fields always have presence, so it's written even if it's empty.
+func (e *Encoder) Message(field int, m Message) {
+	e.bytes(field, m.MarshalProto())
+}
+
+//OptionalInt32 writes an optional int32 field, if it's set.
+func (e *Encoder) OptionalInt32(field int, v *int32) {
+	if v != nil {
+		e.varint(field, uint64(int64(*v)))
+	}
+}
+
+//OptionalString writes an optional string field, if it's set.
+func (e *Encoder) OptionalString(field int, v *string) {
+	if v != nil {
+		e.bytes(field, []byte(*v))
+	}
+}
+
+//appendUvarint appends v to b as a varint.
+func appendUvarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+//Field is a field read from a message. The accessors return the zero value if the field was encoded with a different
+//wire type to the one they expect.
+type Field struct {
+	Num  int
+	Type WireType
+
+	varint uint64
+	data   []byte
+}
+
+//Int64 returns the value of an int64 field.
+func (f Field) Int64() int64 {
+	if f.Type != WireVarint {
+		return 0
+	}
+	return int64(f.varint)
+}
+
+//Int32 returns the value of an int32 field, truncating it to 32 bits as protobuf does.
+func (f Field) Int32() int32 {
+	if f.Type != WireVarint {
+		return 0
+	}
+	return int32(f.varint)
+}
+
+//Uint32 returns the value of a uint32 field.
+func (f Field) Uint32() uint32 {
+	if f.Type != WireVarint || f.varint > math.MaxUint32 {
+		return 0
+	}
+	return uint32(f.varint)
+}
+
+//Bool returns the value of a bool field.
+func (f Field) Bool() bool {
+	return f.Type == WireVarint && f.varint != 0
+}
+
+//String returns the value of a string field.
+func (f Field) String() string {
+	if f.Type != WireBytes {
+		return ""
+	}
+	return string(f.data)
+}
+
+//Bytes returns the value of a bytes or message field. It shares memory with the message being decoded.
+func (f Field) Bytes() []byte {
+	if f.Type != WireBytes {
+		return nil
+	}
+	return f.data
+}
+
+//DecodeFields calls fn for each field in a message, in the order they were written. Repeated fields are seen once
+//per value, and fields fn doesn't know about can simply be ignored.
+func DecodeFields(b []byte, fn func(f Field) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+
+		f := Field{Num: int(key >> 3), Type: WireType(key & 7)}
+		if f.Num <= 0 || key>>3 > math.MaxInt32 {
+			return ErrMalformed
+		}
+
+		switch f.Type {
+		case WireVarint:
+			f.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return ErrMalformed
+			}
+			b = b[n:]
+
+		case WireFixed64:
+			if len(b) < 8 {
+				return ErrMalformed
+			}
+			f.data, b = b[:8], b[8:]
+
+		case WireFixed32:
+			if len(b) < 4 {
+				return ErrMalformed
+			}
+			f.data, b = b[:4], b[4:]
+
+		case WireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return ErrMalformed
+			}
+			b = b[n:]
+			f.data, b = b[:size], b[size:]
+
+		default:
+			//Groups are deprecated, and nothing we read uses them
+			return ErrMalformed
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
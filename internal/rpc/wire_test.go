@@ -0,0 +1,178 @@
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEncoderRoundTrip(t *testing.T) {
+	var e Encoder
+	e.Int64(1, -5)
+	e.Int32(2, math.MaxInt32)
+	e.Uint32(3, math.MaxUint32)
+	e.Bool(4, true)
+	e.String(5, "héllo")
+	e.BytesField(6, []byte{0, 1, 2})
+	e.Strings(7, []string{"a", "", "c"})
+	e.Message(8, &Any{TypeURL: "inner"})
+
+	var got struct {
+		i64     int64
+		i32     int32
+		u32     uint32
+		b       bool
+		s       string
+		bs      []byte
+		strs    []string
+		message Any
+	}
+
+	err := DecodeFields(e.Bytes(), func(f Field) error {
+		switch f.Num {
+		case 1:
+			got.i64 = f.Int64()
+		case 2:
+			got.i32 = f.Int32()
+		case 3:
+			got.u32 = f.Uint32()
+		case 4:
+			got.b = f.Bool()
+		case 5:
+			got.s = f.String()
+		case 6:
+			got.bs = f.Bytes()
+		case 7:
+			got.strs = append(got.strs, f.String())
+		case 8:
+			return got.message.UnmarshalProto(f.Bytes())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.i64 != -5 || got.i32 != math.MaxInt32 || got.u32 != math.MaxUint32 || !got.b || got.s != "héllo" {
+		t.Errorf("got scalars %d %d %d %t %q", got.i64, got.i32, got.u32, got.b, got.s)
+	}
+	if !bytes.Equal(got.bs, []byte{0, 1, 2}) {
+		t.Errorf("got bytes %v", got.bs)
+	}
+	if !reflect.DeepEqual(got.strs, []string{"a", "", "c"}) {
+		t.Errorf("got strings %q", got.strs)
+	}
+	if got.message.TypeURL != "inner" {
+		t.Errorf("got message %+v", got.message)
+	}
+}
+
+func TestEncoderDefaults(t *testing.T) {
+	var e Encoder
+	e.Int64(1, 0)
+	e.Int32(2, 0)
+	e.Bool(3, false)
+	e.String(4, "")
+	e.OptionalInt32(5, nil)
+	e.OptionalString(6, nil)
+
+	if len(e.Bytes()) != 0 {
+		t.Fatalf("default values were written: %v", e.Bytes())
+	}
+
+	zero, empty := int32(0), ""
+	e.OptionalInt32(5, &zero)
+	e.OptionalString(6, &empty)
+
+	var nums []int
+	DecodeFields(e.Bytes(), func(f Field) error {
+		nums = append(nums, f.Num)
+		return nil
+	})
+
+	if !reflect.DeepEqual(nums, []int{5, 6}) {
+		t.Errorf("got fields %v, want optional fields 5 and 6 set to their default values", nums)
+	}
+}
+
+func TestDecodeFieldsMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"truncated key", []byte{0x80}},
+		{"field zero", []byte{0x00, 0x01}},
+		{"truncated varint", []byte{0x08, 0x80}},
+		{"truncated bytes", []byte{0x12, 0x05, 'a'}},
+		{"truncated fixed64", []byte{0x09, 1, 2, 3}},
+		{"truncated fixed32", []byte{0x0d, 1, 2}},
+		{"group", []byte{0x0b}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DecodeFields(tt.b, func(f Field) error { return nil })
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("got %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestFieldWrongWireType(t *testing.T) {
+	var e Encoder
+	e.String(1, "x")
+
+	DecodeFields(e.Bytes(), func(f Field) error {
+		if f.Int64() != 0 || f.Bool() {
+			t.Errorf("a string field read as a number gave %d, %t", f.Int64(), f.Bool())
+		}
+		return nil
+	})
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	want := Errorf(InvalidArgument, "validation failed")
+	want.Details = append(want.Details, BadRequest([]FieldViolation{
+		{Field: "title", Description: "must be provided"},
+		{Field: "genres[1]", Description: "must not contain duplicate values"},
+	}))
+
+	var got Status
+	if err := got.UnmarshalProto(want.MarshalProto()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Code != InvalidArgument || got.Message != "validation failed" || len(got.Details) != 1 {
+		t.Fatalf("got %+v", got)
+	}
+	if got.Details[0].TypeURL != "type.googleapis.com/google.rpc.BadRequest" {
+		t.Errorf("got detail type %q", got.Details[0].TypeURL)
+	}
+
+	var violations []FieldViolation
+
+	err := DecodeFields(got.Details[0].Value, func(f Field) error {
+		var v FieldViolation
+		err := DecodeFields(f.Bytes(), func(f Field) error {
+			switch f.Num {
+			case 1:
+				v.Field = f.String()
+			case 2:
+				v.Description = f.String()
+			}
+			return nil
+		})
+		violations = append(violations, v)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(violations, []FieldViolation{{"title", "must be provided"}, {"genres[1]", "must not contain duplicate values"}}) {
+		t.Errorf("got violations %+v", violations)
+	}
+}
@@ -0,0 +1,483 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"firstAPI.jweaver11.net/internal/i18n"
+)
+
+//RuleFunc reports whether a value passes a rule. The param is whatever followed the "=" in the tag, if anything.
+type RuleFunc func(value reflect.Value, param string) bool
+
+//customRules holds the rules added with RegisterRule(), keyed by name.
+var customRules = struct {
+	sync.RWMutex
+	rules map[string]customRule
+}{rules: make(map[string]customRule)}
+
+type customRule struct {
+	fn         RuleFunc
+	messageKey string
+}
+
+//RegisterRule adds a custom rule which can be used in validate tags alongside the built-in ones. The messageKey is
+//the i18n catalog key recorded when the rule fails. Registering a rule with the same name as a built-in one overrides it.
+//Rules must be registered before any struct using them is validated, as tags are checked when they are first parsed.
+func RegisterRule(name, messageKey string, fn RuleFunc) {
+	customRules.Lock()
+	defer customRules.Unlock()
+
+	customRules.rules[name] = customRule{fn: fn, messageKey: messageKey}
+}
+
+//rule is a single parsed entry from a validate tag, such as "max=500".
+type rule struct {
+	name  string
+	param string
+	low   float64        //the parsed parameter of min, or the lower bound of between
+	high  float64        //the parsed parameter of max, or the upper bound of between
+	rx    *regexp.Regexp //compiled pattern for the regexp rule, so we only compile it once
+}
+
+//fieldRules holds the parsed tag for a single struct field.
+type fieldRules struct {
+	index     int
+	key       string //the JSON name of the field, used as the key in the errors map
+	rules     []rule
+	dive      bool
+	diveRules []rule //rules following "dive", which apply to each element of a slice
+}
+
+//structCache maps a reflect.Type to its parsed []fieldRules, so each struct type is only inspected once.
+var structCache sync.Map
+
+//ValidateStruct checks each field of a struct (or pointer to a struct) against the rules in its validate tag, and adds
+//any failures to the Validator. Fields are keyed by their JSON name. Rules are comma-separated, for example:
+//
+//	Title  string   `json:"title" validate:"required,max=500"`
+//	Genres []string `json:"genres" validate:"required,min=1,max=5,unique,dive,required"`
+//
+//The built-in rules are required, omitempty, min, max, between (e.g. between=1..5), oneof (space-separated values),
+//unique, email and regexp. Rules after dive are applied to each element of a slice, with errors recorded against the
+//element's JSON pointer such as "/genres/2". Diving into a struct, or a slice of structs, validates them using their
+//own tags. As regexp patterns live inside
+//a comma-separated tag, they can't contain commas.
+func ValidateStruct(v *Validator, x interface{}) {
+	value := reflect.ValueOf(x)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validator: ValidateStruct called with non-struct type %s", value.Type()))
+	}
+
+	for _, f := range cachedFieldRules(value.Type()) {
+		field := value.Field(f.index)
+
+		if !applyRules(v, f.key, field, f.rules) {
+			continue
+		}
+
+		if !f.dive {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < field.Len(); i++ {
+				pointer := Pointer(f.key, i)
+				applyRules(v, pointer, field.Index(i), f.diveRules)
+				validateNested(v, pointer, field.Index(i))
+			}
+		default:
+			validateNested(v, Pointer(f.key), field)
+		}
+	}
+}
+
+//validateNested validates a struct value found by dive on its own, then merges its errors in under the pointer.
+func validateNested(v *Validator, pointer string, value reflect.Value) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	child := New()
+	ValidateStruct(child, value.Interface())
+	v.Merge(child.Prefix(pointer))
+}
+
+//applyRules runs the rules against a value, adding an error for each failure. It returns false if an
+//omitempty rule stopped validation early.
+func applyRules(v *Validator, key string, value reflect.Value, rules []rule) bool {
+	for _, r := range rules {
+		if r.name == "omitempty" {
+			if value.IsZero() {
+				return false
+			}
+			continue
+		}
+
+		ok, messageKey := check(r, value)
+		if !ok {
+			v.AddError(key, messageKey)
+		}
+	}
+
+	return true
+}
+
+//check runs a single rule, returning whether it passed and the message key to record if it didn't.
+func check(r rule, value reflect.Value) (bool, string) {
+	customRules.RLock()
+	custom, found := customRules.rules[r.name]
+	customRules.RUnlock()
+
+	if found {
+		return custom.fn(value, r.param), custom.messageKey
+	}
+
+	switch r.name {
+	case "required":
+		return !value.IsZero(), "validation.required"
+
+	case "min":
+		n, suffix := measure(value)
+		return n >= r.low, i18n.Key("validation.min"+plural(suffix, r.low), r.param)
+
+	case "max":
+		n, suffix := measure(value)
+		return n <= r.high, i18n.Key("validation.max"+plural(suffix, r.high), r.param)
+
+	case "between":
+		low, high, _ := strings.Cut(r.param, "..")
+		n, suffix := measure(value)
+		return n >= r.low && n <= r.high, i18n.Key("validation.between"+suffix, low, high)
+
+	case "oneof":
+		options := strings.Fields(r.param)
+		return In(fmt.Sprint(value.Interface()), options...), i18n.Key("validation.oneof", strings.Join(options, ", "))
+
+	case "unique":
+		return uniqueValues(value), "validation.unique"
+
+	case "email":
+		return Matches(value.String(), EmailRX), "validation.email"
+
+	case "regexp":
+		return r.rx.MatchString(value.String()), "validation.regexp"
+	}
+
+	//Rule names are checked when the tag is parsed, so this can only happen if a custom rule is being relied on
+	//which was registered after the struct was first validated
+	panic(fmt.Sprintf("validator: unknown rule %q", r.name))
+}
+
+//measure returns the number a size rule compares against: the value itself for numbers, and the length for strings,
+//slices and maps. The suffix selects the matching message, such as "must contain at least 2 items".
+func measure(value reflect.Value) (float64, string) {
+	suffix, _ := sizeSuffix(value.Kind())
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), suffix
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), suffix
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), suffix
+	default:
+		return float64(value.Len()), suffix
+	}
+}
+
+//sizeSuffix returns the message suffix for the size rules on a kind of value, and false if they can't be used on it.
+func sizeSuffix(kind reflect.Kind) (string, bool) {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "", true
+	case reflect.String:
+		return "_length", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "_items", true
+	}
+
+	return "", false
+}
+
+//plural picks the singular form of a length or items message when the limit is 1, so we say "at least 1 item" rather
+//than "at least 1 items".
+func plural(suffix string, limit float64) string {
+	if suffix != "" && limit == 1 {
+		return suffix + "_one"
+	}
+
+	return suffix
+}
+
+//uniqueValues returns true if all elements of a slice are unique.
+func uniqueValues(value reflect.Value) bool {
+	seen := make(map[interface{}]bool, value.Len())
+
+	for i := 0; i < value.Len(); i++ {
+		element := value.Index(i).Interface()
+		if seen[element] {
+			return false
+		}
+		seen[element] = true
+	}
+
+	return true
+}
+
+//cachedFieldRules returns the parsed rules for a struct type, parsing and caching them the first time it is seen. A bad
+//tag is a programming error, so it panics, but CheckTags() lets the tags be checked at startup or in a test instead.
+func cachedFieldRules(t reflect.Type) []fieldRules {
+	fields, err := loadFieldRules(t)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return fields
+}
+
+//loadFieldRules returns the parsed rules for a struct type from the cache, or parses and caches them. Types with a bad
+//tag aren't cached, so every use of them fails.
+func loadFieldRules(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := structCache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	fields, err := parseFieldRules(t)
+	if err != nil {
+		return nil, err
+	}
+
+	structCache.Store(t, fields)
+
+	return fields, nil
+}
+
+//parseFieldRules parses the validate tag of each field of a struct type, checking that every rule exists, that its
+//parameter is valid, and that it can be used on the field's type.
+func parseFieldRules(t reflect.Type) ([]fieldRules, error) {
+	var fields []fieldRules
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok || tag == "" || tag == "-" {
+			continue
+		}
+
+		f := fieldRules{index: i, key: jsonName(sf)}
+
+		target := &f.rules
+		ruleType := sf.Type
+
+		for _, entry := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(entry), "=")
+			if name == "dive" {
+				if f.dive {
+					return nil, fmt.Errorf("validator: %s.%s: dive can only be used once", t, sf.Name)
+				}
+
+				f.dive = true
+				target = &f.diveRules
+
+				//Rules after dive apply to each element of a slice or array, or to the value itself otherwise
+				if ruleType.Kind() == reflect.Slice || ruleType.Kind() == reflect.Array {
+					ruleType = ruleType.Elem()
+				}
+				continue
+			}
+
+			r, err := parseRule(name, param, ruleType)
+			if err != nil {
+				return nil, fmt.Errorf("validator: %s.%s: %w", t, sf.Name, err)
+			}
+
+			*target = append(*target, r)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+//parseRule parses a single rule from a tag, for a value of the given type.
+func parseRule(name, param string, t reflect.Type) (rule, error) {
+	r := rule{name: name, param: param}
+
+	customRules.RLock()
+	_, custom := customRules.rules[name]
+	customRules.RUnlock()
+
+	if custom {
+		return r, nil
+	}
+
+	var err error
+
+	switch name {
+	case "required", "omitempty":
+
+	case "min", "max":
+		r.low, err = parseNumber(name, param)
+		r.high = r.low
+		if err == nil {
+			err = checkSizeType(name, t)
+		}
+
+	case "between":
+		low, high, found := strings.Cut(param, "..")
+		if !found {
+			return r, fmt.Errorf("invalid between parameter %q, it should look like 1..5", param)
+		}
+
+		r.low, err = parseNumber(name, low)
+		if err == nil {
+			r.high, err = parseNumber(name, high)
+		}
+		if err == nil && r.low > r.high {
+			err = fmt.Errorf("invalid between parameter %q, the lower bound is above the upper one", param)
+		}
+		if err == nil {
+			err = checkSizeType(name, t)
+		}
+
+	case "oneof":
+		if len(strings.Fields(param)) == 0 {
+			err = fmt.Errorf("oneof needs at least one value")
+		}
+
+	case "unique":
+		if (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) || !t.Elem().Comparable() {
+			err = fmt.Errorf("unique can't be used on %s", t)
+		}
+
+	case "email":
+		if t.Kind() != reflect.String {
+			err = fmt.Errorf("email can't be used on %s", t)
+		}
+
+	case "regexp":
+		if t.Kind() != reflect.String {
+			return r, fmt.Errorf("regexp can't be used on %s", t)
+		}
+
+		r.rx, err = regexp.Compile(param)
+
+	default:
+		err = fmt.Errorf("unknown rule %q", name)
+	}
+
+	return r, err
+}
+
+//parseNumber parses the numeric parameter of a size rule.
+func parseNumber(name, param string) (float64, error) {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s parameter %q, it should be a number", name, param)
+	}
+
+	return f, nil
+}
+
+//checkSizeType returns an error if a size rule can't be used on a type.
+func checkSizeType(name string, t reflect.Type) error {
+	if _, ok := sizeSuffix(t.Kind()); !ok {
+		return fmt.Errorf("%s can't be used on %s", name, t)
+	}
+
+	return nil
+}
+
+//CheckTags parses the validate tags of each struct (or pointer to a struct), and of the structs they dive into, and
+//returns an error describing the first bad one. Parsed tags are cached, so calling it at startup also saves parsing
+//them on the first request.
+func CheckTags(xs ...interface{}) error {
+	seen := make(map[reflect.Type]bool)
+
+	for _, x := range xs {
+		err := checkType(reflect.TypeOf(x), seen)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//checkType checks the tags of a struct type and every struct type it dives into, visiting each type once.
+func checkType(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("validator: CheckTags called with non-struct type %s", t)
+	}
+
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	fields, err := loadFieldRules(t)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if !f.dive {
+			continue
+		}
+
+		element := t.Field(f.index).Type
+		if element.Kind() == reflect.Slice || element.Kind() == reflect.Array {
+			element = element.Elem()
+		}
+		for element.Kind() == reflect.Ptr {
+			element = element.Elem()
+		}
+
+		if element.Kind() == reflect.Struct {
+			err := checkType(element, seen)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//jsonName returns the name a field is encoded with in JSON, falling back to the Go field name.
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+
+	return name
+}
@@ -0,0 +1,172 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"firstAPI.jweaver11.net/internal/i18n"
+)
+
+type testItem struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type testInput struct {
+	Title  string      `json:"title" validate:"required,max=10"`
+	Year   int32       `json:"year" validate:"between=1888..2100"`
+	Genres []string    `json:"genres" validate:"required,min=1,max=3,unique,dive,required"`
+	Tags   []string    `json:"tags" validate:"omitempty,dive,oneof=a b"`
+	Email  string      `json:"email" validate:"omitempty,email"`
+	Code   string      `json:"code" validate:"omitempty,regexp=^[A-Z]{3}$"`
+	Items  []*testItem `json:"items" validate:"dive"`
+}
+
+func validInput() testInput {
+	return testInput{Title: "Casablanca", Year: 1942, Genres: []string{"drama"}}
+}
+
+func TestValidateStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(in *testInput)
+		want   map[string]string //pointer to English message
+	}{
+		{"valid", func(in *testInput) {}, map[string]string{}},
+		{"required", func(in *testInput) { in.Title = "" }, map[string]string{"/title": "must be provided"}},
+		{"max length", func(in *testInput) { in.Title = "Casablanca!" }, map[string]string{"/title": "must not be more than 10 bytes long"}},
+		{"between", func(in *testInput) { in.Year = 1800 }, map[string]string{"/year": "must be between 1888 and 2100"}},
+		{"min items is singular", func(in *testInput) { in.Genres = []string{} }, map[string]string{"/genres": "must contain at least 1 item"}},
+		{"max items", func(in *testInput) { in.Genres = []string{"a", "b", "c", "d"} }, map[string]string{"/genres": "must not contain more than 3 items"}},
+		{"unique", func(in *testInput) { in.Genres = []string{"a", "a"} }, map[string]string{"/genres": "must not contain duplicate values"}},
+		{"dive", func(in *testInput) { in.Genres = []string{"a", ""} }, map[string]string{"/genres/1": "must be provided"}},
+		{"oneof", func(in *testInput) { in.Tags = []string{"a", "c"} }, map[string]string{"/tags/1": "must be one of a, b"}},
+		{"email", func(in *testInput) { in.Email = "nope" }, map[string]string{"/email": "must be a valid email address"}},
+		{"regexp", func(in *testInput) { in.Code = "abc" }, map[string]string{"/code": "must match the required format"}},
+		{"nested struct", func(in *testInput) { in.Items = []*testItem{{Name: "x"}, {}} }, map[string]string{"/items/1/name": "must be provided"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := validInput()
+			tt.modify(&in)
+
+			v := New()
+			ValidateStruct(v, &in)
+
+			got := make(map[string]string)
+			for _, fe := range v.FieldErrors() {
+				got[fe.Pointer] = i18n.T("en", fe.Message)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStructCollectsEveryFailure(t *testing.T) {
+	in := testInput{Genres: []string{"", ""}}
+
+	v := New()
+	ValidateStruct(v, &in)
+
+	want := []FieldError{
+		{Pointer: "/title", Message: "validation.required"},
+		{Pointer: "/year", Message: i18n.Key("validation.between", "1888", "2100")},
+		{Pointer: "/genres", Message: "validation.unique"},
+		{Pointer: "/genres/0", Message: "validation.required"},
+		{Pointer: "/genres/1", Message: "validation.required"},
+	}
+
+	if !reflect.DeepEqual(v.FieldErrors(), want) {
+		t.Errorf("got %v, want %v", v.FieldErrors(), want)
+	}
+
+	if v.Errors["genres"] != "validation.unique" {
+		t.Errorf("Errors[genres] = %q, want the first message for the field", v.Errors["genres"])
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	tests := []struct {
+		name string
+		x    interface{}
+		err  string
+	}{
+		{"valid", testInput{}, ""},
+		{"unknown rule", struct {
+			A string `validate:"requird"`
+		}{}, `unknown rule "requird"`},
+		{"bad number", struct {
+			A string `validate:"max=ten"`
+		}{}, `invalid max parameter "ten"`},
+		{"bad range", struct {
+			A int `validate:"between=5"`
+		}{}, `invalid between parameter "5"`},
+		{"inverted range", struct {
+			A int `validate:"between=5..1"`
+		}{}, "lower bound is above the upper one"},
+		{"size rule on bool", struct {
+			A bool `validate:"min=1"`
+		}{}, "min can't be used on bool"},
+		{"unique on string", struct {
+			A string `validate:"unique"`
+		}{}, "unique can't be used on string"},
+		{"bad regexp", struct {
+			A string `validate:"regexp=("`
+		}{}, "missing closing )"},
+		{"bad nested struct", struct {
+			A []struct {
+				B string `validate:"nope"`
+			} `validate:"dive"`
+		}{}, `unknown rule "nope"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTags(tt.x)
+
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	RegisterRule("even", "validation.even", func(value reflect.Value, param string) bool {
+		return value.Int()%2 == 0
+	})
+
+	type input struct {
+		N int `json:"n" validate:"even"`
+	}
+
+	v := New()
+	ValidateStruct(v, input{N: 3})
+
+	if v.Errors["n"] != "validation.even" {
+		t.Errorf("got %v, want the custom rule's message key", v.Errors)
+	}
+}
+
+func BenchmarkValidateStruct(b *testing.B) {
+	in := validInput()
+	in.Tags = []string{"a", "b"}
+	in.Items = []*testItem{{Name: "x"}, {Name: "y"}}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		v := New()
+		ValidateStruct(v, &in)
+		if !v.Valid() {
+			b.Fatal(v.Errors)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package validator
 
 import (
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 //Declare a regular expresssion for sanity checking the format of email addresses.
@@ -9,9 +11,23 @@ var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 )
 
+//FieldError is a single validation failure. Pointer is an RFC 6901 JSON pointer to the field, such as "/genres/2".
+type FieldError struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+//Field returns the top-level field the error belongs to, which is its key in the Errors map.
+func (fe FieldError) Field() string {
+	return topLevelField(fe.Pointer)
+}
+
 //Define a new Validator type which contains a map of a validation errors.
+//Errors holds the first message for each top-level field, which is the shape our responses have always used.
+//Every failure, including nested and repeated ones, is also recorded and available from FieldErrors().
 type Validator struct {
 	Errors map[string]string
+	all    []FieldError
 }
 
 //New is a helper which creates a new Validator instance with an empty errors map.
@@ -24,10 +40,23 @@ func (v *Validator) Valid() bool {
 	return len(v.Errors) == 0
 }
 
-//AddError adds error message to the map (so long as no entry already exists for the given key).
+//AddError records an error message for a field. The key can be a plain field name like "title", or a JSON pointer
+//like "/genres/2" for nested and indexed fields. Every distinct message is kept, but the Errors map only gets the first
+//message for each top-level field (so long as no entry already exists for it).
 func (v *Validator) AddError(key, message string) {
-	if _, exists := v.Errors[key]; !exists {
-		v.Errors[key] = message
+	pointer := toPointer(key)
+
+	for _, fe := range v.all {
+		if fe.Pointer == pointer && fe.Message == message {
+			return
+		}
+	}
+
+	v.all = append(v.all, FieldError{Pointer: pointer, Message: message})
+
+	field := topLevelField(pointer)
+	if _, exists := v.Errors[field]; !exists {
+		v.Errors[field] = message
 	}
 }
 
@@ -38,6 +67,87 @@ func (v *Validator) Check(ok bool, key, message string) {
 	}
 }
 
+//FieldErrors returns every recorded error, in the order they were added.
+func (v *Validator) FieldErrors() []FieldError {
+	return v.all
+}
+
+//Merge adds all the errors from another validator to this one. Combined with Prefix(), this lets nested values
+//be validated on their own and then attached under their parent, for example:
+//
+//	child := validator.New()
+//	ValidateAddress(child, input.Address)
+//	v.Merge(child.Prefix("/address"))
+func (v *Validator) Merge(other *Validator) {
+	for _, fe := range other.all {
+		v.AddError(fe.Pointer, fe.Message)
+	}
+}
+
+//Prefix returns a copy of the validator with a JSON pointer prefix added to every error. The prefix may be a
+//pointer like "/items/3" or a plain field name.
+func (v *Validator) Prefix(prefix string) *Validator {
+	prefix = toPointer(prefix)
+
+	prefixed := New()
+	for _, fe := range v.all {
+		prefixed.AddError(prefix+fe.Pointer, fe.Message)
+	}
+
+	return prefixed
+}
+
+//Pointer builds an RFC 6901 JSON pointer from its reference tokens, escaping "~" and "/" as required.
+//Tokens can be strings (object members) or ints (array indexes), e.g. Pointer("genres", 2) returns "/genres/2".
+func Pointer(tokens ...interface{}) string {
+	var b strings.Builder
+
+	for _, token := range tokens {
+		b.WriteByte('/')
+
+		switch t := token.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(t))
+		case string:
+			b.WriteString(escapeToken(t))
+		default:
+			panic("validator: JSON pointer tokens must be strings or ints")
+		}
+	}
+
+	return b.String()
+}
+
+//toPointer turns a plain field name into a single-token JSON pointer. Keys which are already pointers are unchanged.
+func toPointer(key string) string {
+	if strings.HasPrefix(key, "/") {
+		return key
+	}
+	return Pointer(key)
+}
+
+//topLevelField returns the first token of a JSON pointer, unescaped, which is the key used in the Errors map.
+func topLevelField(pointer string) string {
+	token := strings.TrimPrefix(pointer, "/")
+	if i := strings.IndexByte(token, '/'); i >= 0 {
+		token = token[:i]
+	}
+
+	return tokenUnescaper.Replace(token)
+}
+
+//tokenEscaper and tokenUnescaper convert reference tokens to and from their escaped form. Building a replacer is costly,
+//so they are only built once.
+var (
+	tokenEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	tokenUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+//escapeToken escapes a reference token as RFC 6901 requires. "~" must be escaped first.
+func escapeToken(token string) string {
+	return tokenEscaper.Replace(token)
+}
+
 //In returns true if a specific value is in a list of strings
 func In(value string, list ...string) bool {
 	for i := range list {
@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPointer(t *testing.T) {
+	tests := []struct {
+		tokens []interface{}
+		want   string
+	}{
+		{nil, ""},
+		{[]interface{}{"title"}, "/title"},
+		{[]interface{}{"genres", 2}, "/genres/2"},
+		{[]interface{}{"a/b", "c~d"}, "/a~1b/c~0d"},
+		{[]interface{}{""}, "/"},
+	}
+
+	for _, tt := range tests {
+		if got := Pointer(tt.tokens...); got != tt.want {
+			t.Errorf("Pointer(%v) = %q, want %q", tt.tokens, got, tt.want)
+		}
+	}
+}
+
+func TestAddError(t *testing.T) {
+	v := New()
+	v.AddError("/genres/2", "must be provided")
+	v.AddError("genres", "must not contain duplicate values")
+	v.AddError("/genres/2", "must be provided")
+	v.AddError("title", "must be provided")
+	v.AddError("/a~1b/0", "must be provided")
+
+	//Errors keeps the first message for each top-level field, and FieldErrors keeps every distinct one
+	wantErrors := map[string]string{
+		"genres": "must be provided",
+		"title":  "must be provided",
+		"a/b":    "must be provided",
+	}
+	wantAll := []FieldError{
+		{Pointer: "/genres/2", Message: "must be provided"},
+		{Pointer: "/genres", Message: "must not contain duplicate values"},
+		{Pointer: "/title", Message: "must be provided"},
+		{Pointer: "/a~1b/0", Message: "must be provided"},
+	}
+
+	if !reflect.DeepEqual(v.Errors, wantErrors) {
+		t.Errorf("got Errors %v, want %v", v.Errors, wantErrors)
+	}
+	if !reflect.DeepEqual(v.FieldErrors(), wantAll) {
+		t.Errorf("got FieldErrors %v, want %v", v.FieldErrors(), wantAll)
+	}
+	if v.Valid() {
+		t.Error("got Valid() true")
+	}
+}
+
+func TestMergePrefix(t *testing.T) {
+	child := New()
+	child.AddError("title", "must be provided")
+	child.AddError("/genres/0", "must be provided")
+
+	v := New()
+	v.AddError("atomic", "must be true or false")
+	v.Merge(child.Prefix("/operations/3"))
+
+	want := []FieldError{
+		{Pointer: "/atomic", Message: "must be true or false"},
+		{Pointer: "/operations/3/title", Message: "must be provided"},
+		{Pointer: "/operations/3/genres/0", Message: "must be provided"},
+	}
+
+	if !reflect.DeepEqual(v.FieldErrors(), want) {
+		t.Errorf("got %v, want %v", v.FieldErrors(), want)
+	}
+	if v.Errors["operations"] != "must be provided" {
+		t.Errorf("got %v", v.Errors)
+	}
+}
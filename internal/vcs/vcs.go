@@ -0,0 +1,82 @@
+package vcs
+
+import (
+	"runtime/debug"
+	"strconv"
+)
+
+//These variables can be overridden at build time with -ldflags, for example:
+//go build -ldflags="-X firstAPI.jweaver11.net/internal/vcs.version=1.2.0" ./cmd/api
+//Anything left empty falls back to the information embedded by the Go toolchain.
+var (
+	version  string
+	revision string
+	time     string
+	modified string
+)
+
+//Info holds the version and version control metadata for the running binary.
+type Info struct {
+	Version  string `json:"version"`
+	Revision string `json:"revision,omitempty"`
+	Time     string `json:"time,omitempty"`
+	Modified bool   `json:"modified"`
+}
+
+//Get returns the build information, preferring -ldflags overrides over the values from runtime/debug.ReadBuildInfo().
+func Get() Info {
+	info := Info{
+		Version:  version,
+		Revision: revision,
+		Time:     time,
+	}
+
+	if b, err := strconv.ParseBool(modified); err == nil {
+		info.Modified = b
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Revision == "" {
+					info.Revision = s.Value
+				}
+			case "vcs.time":
+				if info.Time == "" {
+					info.Time = s.Value
+				}
+			case "vcs.modified":
+				if modified == "" {
+					info.Modified = s.Value == "true"
+				}
+			}
+		}
+	}
+
+	//If we still don't have a version, build one from the revision so deployed binaries can always be told apart
+	if info.Version == "" {
+		info.Version = "devel"
+		if info.Revision != "" {
+			info.Version += "-" + shortRevision(info.Revision)
+			if info.Modified {
+				info.Version += "-dirty"
+			}
+		}
+	}
+
+	return info
+}
+
+//shortRevision trims a commit hash to the familiar 12 character form.
+func shortRevision(revision string) string {
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
@@ -0,0 +1,34 @@
+package vcs
+
+import "testing"
+
+//Test binaries aren't stamped with version control settings, so only the -ldflags overrides decide the result
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		revision string
+		modified string
+		want     Info
+	}{
+		{"nothing set", "", "", "", Info{Version: "devel"}},
+		{"version", "1.2.0", "", "", Info{Version: "1.2.0"}},
+		{"revision", "", "0123456789abcdef", "", Info{Version: "devel-0123456789ab", Revision: "0123456789abcdef"}},
+		{"modified revision", "", "0123456789abcdef", "true", Info{Version: "devel-0123456789ab-dirty", Revision: "0123456789abcdef", Modified: true}},
+		{"short revision", "", "abc", "false", Info{Version: "devel-abc", Revision: "abc"}},
+		{"version and revision", "1.2.0", "abc", "true", Info{Version: "1.2.0", Revision: "abc", Modified: true}},
+		{"bad modified", "", "abc", "perhaps", Info{Version: "devel-abc", Revision: "abc"}},
+	}
+
+	defer func(v, r, m string) { version, revision, modified = v, r, m }(version, revision, modified)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, revision, modified = tt.version, tt.revision, tt.modified
+
+			if got := Get(); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
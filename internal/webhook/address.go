@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//ErrPrivateAddress is returned for a receiver on a loopback, link-local or private address. Webhook URLs are chosen by
+//API clients, so without this check they could have the server make requests to itself, or to other machines on its
+//network which aren't meant to be reachable from outside, like a cloud provider's metadata service.
+var ErrPrivateAddress = errors.New("webhook: receiver is on a loopback, link-local or private address")
+
+//sharedAddressSpace is the carrier-grade NAT range, which is private in practice although net.IP.IsPrivate() doesn't
+//count it.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+//PrivateIP reports whether webhooks mustn't be sent to an IP address: a loopback, link-local, private, unspecified or
+//multicast one.
+func PrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() ||
+		ip.IsUnspecified() || ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+//CheckHost returns ErrPrivateAddress if a receiver's host is, or resolves to, an address PrivateIP() rejects. A host
+//which can't be resolved passes, as it may not have been set up yet, and the address is checked again whenever a
+//webhook is sent (see NewClient).
+func CheckHost(ctx context.Context, host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if PrivateIP(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+
+	for _, addr := range addrs {
+		if PrivateIP(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+//NewClient returns an http.Client for a Sender. Unless allowPrivate is set, it refuses to connect to any address
+//PrivateIP() rejects. The check is made on the address actually dialled, after DNS resolution, so a receiver can't get
+//around it by pointing its host name at a private address once its subscription has been accepted. Proxies from the
+//environment aren't used, as the proxy's address would be checked rather than the receiver's.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || PrivateIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
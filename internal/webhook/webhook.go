@@ -0,0 +1,192 @@
+//Package webhook sends signed webhook requests. Each request carries a signature header made with HMAC-SHA256 over the
+//timestamp and the body, so receivers can check it came from us, and reject old requests replayed by someone else.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//The headers sent with every webhook request.
+const (
+	HeaderSignature = "Webhook-Signature" //"t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">"
+	HeaderID        = "Webhook-Id"        //the delivery's ID, which stays the same across retries so receivers can skip duplicates
+	HeaderEvent     = "Webhook-Event"     //the event type, like "movie.created"
+)
+
+//maxResponseBody is how much of a receiver's response is kept for inspection.
+const maxResponseBody = 1024
+
+//Errors returned by Verify.
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrTooOld           = errors.New("webhook: timestamp outside tolerance")
+)
+
+//NewSecret returns a random secret for signing a subscription's requests.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+//Sign returns the signature header for a body sent at the given time.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+//Verify checks a signature header against the body, for receivers. Signatures made more than tolerance from now are
+//rejected, so a captured request can't be replayed later.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var sigs []string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrTooOld
+	}
+
+	expected := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//Request is one webhook to send.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID string
+	Event      string
+	Body       []byte
+}
+
+//Result is what happened when a webhook was sent. Err is set if the request couldn't be made, or the receiver didn't
+//reply with a 2xx status.
+type Result struct {
+	StatusCode   int
+	ResponseBody string
+	Duration     time.Duration
+	Err          error
+}
+
+//OK reports whether the receiver accepted the webhook.
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+//Sender sends webhooks with its Client, which should have a timeout and, outside of development, come from NewClient(),
+//so that webhooks can't be sent to private addresses. Now is used for the signature's timestamp, and defaults to
+//time.Now.
+type Sender struct {
+	Client    *http.Client
+	UserAgent string
+	Now       func() time.Time
+}
+
+//Send POSTs a webhook and reports how it went. Redirects aren't followed, as the receiver's URL should be exact.
+func (s *Sender) Send(ctx context.Context, req Request) Result {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	start := time.Now()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", s.UserAgent)
+	httpReq.Header.Set(HeaderID, req.DeliveryID)
+	httpReq.Header.Set(HeaderEvent, req.Event)
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, now(), req.Body))
+
+	client := *s.Client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return Result{Duration: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+
+	//Drain a little more, so the connection can be reused if the receiver sent a bit more than we keep
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result := Result{
+		StatusCode:   resp.StatusCode,
+		ResponseBody: string(body),
+		Duration:     time.Since(start),
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Err = fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+
+	return result
+}
+
+//Backoff returns how long to wait before the next attempt, after the given number of failed attempts. The wait
+//doubles with each attempt from base up to max, and is randomly spread by up to a fifth either way, so that many
+//deliveries which failed together don't all retry at the same moment.
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	d := float64(base) * math.Pow(2, float64(attempts-1))
+	if d > float64(max) {
+		d = float64(max)
+	}
+
+	d *= 0.8 + 0.4*mathrand.Float64()
+
+	return time.Duration(d)
+}
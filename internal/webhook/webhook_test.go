@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"movie":{"id":1}}`)
+	sent := time.Unix(1_700_000_000, 0)
+
+	header := Sign(secret, sent, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"valid", secret, header, body, sent.Add(time.Minute), nil},
+		{"rotated secret", secret, "t=1700000000,v1=00," + header[len("t=1700000000,"):], body, sent, nil},
+		{"wrong secret", "whsec_other", header, body, sent, ErrInvalidSignature},
+		{"changed body", secret, header, []byte(`{"movie":{"id":2}}`), sent, ErrInvalidSignature},
+		{"no timestamp", secret, header[len("t=1700000000,"):], body, sent, ErrInvalidSignature},
+		{"too old", secret, header, body, sent.Add(6 * time.Minute), ErrTooOld},
+		{"from the future", secret, header, body, sent.Add(-6 * time.Minute), ErrTooOld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, tt.now); err != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, time.Minute
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			got := Backoff(tt.attempts, base, max)
+
+			low, high := time.Duration(float64(tt.want)*0.8), time.Duration(float64(tt.want)*1.2)
+			if got < low || got > high {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.attempts, got, low, high)
+			}
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	secret := "whsec_test"
+	now := time.Now()
+
+	var status int
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if err := Verify(secret, r.Header.Get(HeaderSignature), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("receiver couldn't verify the signature: %v", err)
+		}
+		if r.Header.Get(HeaderID) != "42" || r.Header.Get(HeaderEvent) != "movie.created" || r.Header.Get("User-Agent") != "test" {
+			t.Errorf("got headers %v", r.Header)
+		}
+
+		if status == http.StatusFound {
+			http.Redirect(w, r, "/elsewhere", status)
+			return
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte("received"))
+	}))
+	defer receiver.Close()
+
+	sender := &Sender{Client: NewClient(time.Second, true), UserAgent: "test", Now: func() time.Time { return now }}
+
+	tests := []struct {
+		status int
+		ok     bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusNoContent, true},
+		{http.StatusFound, false},
+		{http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		status = tt.status
+
+		result := sender.Send(context.Background(), Request{
+			URL:        receiver.URL,
+			Secret:     secret,
+			DeliveryID: "42",
+			Event:      "movie.created",
+			Body:       []byte(`{"movie":{"id":1}}`),
+		})
+
+		if result.OK() != tt.ok || result.StatusCode != tt.status {
+			t.Errorf("status %d: got OK %t and status %d, error %v", tt.status, result.OK(), result.StatusCode, result.Err)
+		}
+		if tt.status == http.StatusInternalServerError && result.ResponseBody != "received" {
+			t.Errorf("got response body %q, want it kept for inspection", result.ResponseBody)
+		}
+	}
+}
+
+func TestNewClientRejectsPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the receiver on a loopback address was sent a webhook")
+	}))
+	defer receiver.Close()
+
+	sender := &Sender{Client: NewClient(time.Second, false)}
+
+	result := sender.Send(context.Background(), Request{URL: receiver.URL, Body: []byte("{}")})
+	if !errors.Is(result.Err, ErrPrivateAddress) {
+		t.Errorf("got %v, want ErrPrivateAddress", result.Err)
+	}
+}
+
+func TestPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+
+	for _, tt := range tests {
+		if got := PrivateIP(net.ParseIP(tt.ip)); got != tt.private {
+			t.Errorf("PrivateIP(%s) = %t, want %t", tt.ip, got, tt.private)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want error
+	}{
+		{"localhost", ErrPrivateAddress},
+		{"api.localhost", ErrPrivateAddress},
+		{"127.0.0.1", ErrPrivateAddress},
+		{"::1", ErrPrivateAddress},
+		{"169.254.169.254", ErrPrivateAddress},
+		{"93.184.216.34", nil},
+		{"unresolvable.invalid", nil},
+	}
+
+	for _, tt := range tests {
+		if err := CheckHost(context.Background(), tt.host); err != tt.want {
+			t.Errorf("CheckHost(%q) = %v, want %v", tt.host, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL,
+    version integer NOT NULL,
+    operation text NOT NULL,
+    before jsonb,
+    after jsonb,
+    actor text NOT NULL,
+    request_id text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS movie_revisions_movie_id_version_idx ON movie_revisions (movie_id, version);
\ No newline at end of file
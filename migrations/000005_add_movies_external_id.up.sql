@@ -0,0 +1,2 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS external_id text;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_external_id_idx ON movies (external_id);
\ No newline at end of file
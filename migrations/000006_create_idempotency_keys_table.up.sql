@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key text PRIMARY KEY,
+    request_hash text NOT NULL,
+    status integer,
+    header jsonb,
+    body bytea,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS movies_notify_change ON movies;
+DROP FUNCTION IF EXISTS notify_movie_change();
+DROP SEQUENCE IF EXISTS movie_changes_id_seq;
\ No newline at end of file
@@ -0,0 +1,69 @@
+CREATE SEQUENCE IF NOT EXISTS movie_changes_id_seq;
+
+CREATE OR REPLACE FUNCTION notify_movie_change() RETURNS trigger AS $$
+DECLARE
+    change_id bigint;
+    change_type text;
+    movie movies%ROWTYPE;
+    payload text;
+BEGIN
+    -- Soft deleting a movie is a delete, and restoring one is a create. Changes to soft deleted movies aren't seen
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        change_type := 'created';
+        movie := NEW;
+    ELSIF TG_OP = 'UPDATE' THEN
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_type := 'deleted';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_type := 'created';
+        ELSIF NEW.deleted_at IS NULL THEN
+            change_type := 'updated';
+        ELSE
+            RETURN NULL;
+        END IF;
+        movie := NEW;
+    ELSE
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        change_type := 'deleted';
+        movie := OLD;
+    END IF;
+
+    change_id := nextval('movie_changes_id_seq');
+
+    payload := json_build_object(
+        'id', change_id,
+        'type', change_type,
+        'movie', json_build_object(
+            'id', movie.id,
+            'title', movie.title,
+            'year', movie.year,
+            'runtime', movie.runtime,
+            'genres', movie.genres,
+            'version', movie.version,
+            'external_id', movie.external_id
+        )
+    )::text;
+
+    -- NOTIFY payloads must be under 8000 bytes, so a very large movie is sent without its fields
+    IF octet_length(payload) >= 8000 THEN
+        payload := json_build_object(
+            'id', change_id,
+            'type', change_type,
+            'movie', json_build_object('id', movie.id, 'version', movie.version)
+        )::text;
+    END IF;
+
+    PERFORM pg_notify('movie_changes', payload);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER movies_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON movies
+    FOR EACH ROW EXECUTE FUNCTION notify_movie_change();
\ No newline at end of file
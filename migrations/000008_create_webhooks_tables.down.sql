@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_outbox;
+DROP TABLE IF EXISTS webhook_subscriptions;
\ No newline at end of file
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id bigserial PRIMARY KEY,
+    url text NOT NULL,
+    secret text NOT NULL,
+    events text[] NOT NULL DEFAULT '{}',
+    active boolean NOT NULL DEFAULT true,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+    id bigserial PRIMARY KEY,
+    event_type text NOT NULL,
+    movie_id bigint NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id bigserial PRIMARY KEY,
+    subscription_id bigint NOT NULL REFERENCES webhook_subscriptions ON DELETE CASCADE,
+    event_id bigint NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id, id);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id bigserial PRIMARY KEY,
+    delivery_id bigint NOT NULL REFERENCES webhook_deliveries ON DELETE CASCADE,
+    attempt integer NOT NULL,
+    status_code integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    response_body text NOT NULL DEFAULT '',
+    duration_ms integer NOT NULL,
+    attempted_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_id_idx ON webhook_delivery_attempts (delivery_id, id);
\ No newline at end of file
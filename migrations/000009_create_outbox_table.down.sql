@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+    id bigserial PRIMARY KEY,
+    event_type text NOT NULL,
+    movie_id bigint NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+DROP INDEX IF EXISTS webhook_deliveries_subscription_event_idx;
+DROP TABLE IF EXISTS event_checkpoints;
+DROP TABLE IF EXISTS outbox;
+DROP FUNCTION IF EXISTS sequence_outbox_event();
\ No newline at end of file
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    position bigint UNIQUE,
+    aggregate_type text NOT NULL,
+    aggregate_id bigint NOT NULL,
+    aggregate_version integer NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    occurred_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE SEQUENCE IF NOT EXISTS outbox_position_seq OWNED BY outbox.position;
+
+-- An event's position is given out as its transaction commits, one committing transaction at a time, so positions are
+-- in commit order. A reader which has seen a position has seen every position before it, and two changes to the same
+-- movie are always in the order they were made, as the second waits on the first's row lock
+CREATE OR REPLACE FUNCTION sequence_outbox_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('outbox_position'));
+    UPDATE outbox SET position = nextval('outbox_position_seq') WHERE id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER outbox_sequence_event
+    AFTER INSERT ON outbox
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION sequence_outbox_event();
+
+CREATE TABLE IF NOT EXISTS event_checkpoints (
+    handler text PRIMARY KEY,
+    position bigint NOT NULL DEFAULT 0,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- Webhooks are now fanned out by a handler on the event relay, which may see an event more than once
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_subscription_event_idx ON webhook_deliveries (subscription_id, event_id);
+
+-- Events keep their IDs, and new ones are numbered after every event a delivery was made for, so that the relay's
+-- deliveries can't clash with older ones on the unique index above and be skipped
+INSERT INTO outbox (id, aggregate_type, aggregate_id, aggregate_version, event_type, payload, occurred_at)
+SELECT id, 'movie', movie_id, (payload->'movie'->>'version')::integer, event_type, payload, created_at
+FROM webhook_outbox
+ORDER BY id;
+
+SELECT setval(pg_get_serial_sequence('outbox', 'id'), GREATEST(
+    (SELECT COALESCE(max(id), 0) FROM outbox),
+    (SELECT COALESCE(max(event_id), 0) FROM webhook_deliveries)
+) + 1, false);
+
+DROP TABLE IF EXISTS webhook_outbox;
\ No newline at end of file